@@ -96,6 +96,11 @@ type (
 		Rparen token.Pos
 	}
 
+	// EmptyExpr is an omitted argument, as in SORT(A1:B5,,-1).
+	EmptyExpr struct {
+		Comma token.Pos // position of the following ',' or ')'
+	}
+
 	CallExpr struct {
 		Fun    Expr
 		Lparen token.Pos
@@ -105,7 +110,7 @@ type (
 
 	UnaryExpr struct {
 		OpPos token.Pos
-		Op    token.Token
+		Op    token.Token // the postfix SPILL follows X
 		X     Expr
 	}
 
@@ -116,6 +121,14 @@ type (
 		Y     Expr
 	}
 
+	// ArrayLit is an array constant such as {1,2;3,4}. Rows holds the
+	// elements row by row; all rows have the same length.
+	ArrayLit struct {
+		Lbrace token.Pos
+		Rows   [][]Expr
+		Rbrace token.Pos
+	}
+
 	/*FuncType struct {
 		Func   token.Pos
 		Params *FieldList
@@ -126,9 +139,10 @@ func (x *BadExpr) Pos() token.Pos    { return x.From }
 func (x *Ident) Pos() token.Pos      { return x.NamePos }
 func (x *BasicLit) Pos() token.Pos   { return x.ValuePos }
 func (x *ParenExpr) Pos() token.Pos  { return x.Lparen }
+func (x *EmptyExpr) Pos() token.Pos  { return x.Comma }
 func (x *CallExpr) Pos() token.Pos   { return x.Fun.Pos() }
-func (x *UnaryExpr) Pos() token.Pos  { return x.OpPos }
 func (x *BinaryExpr) Pos() token.Pos { return x.X.Pos() }
+func (x *ArrayLit) Pos() token.Pos   { return x.Lbrace }
 /*func (x *FuncType) Pos() token.Pos {
 	if x.Func.IsValid() || x.Params == nil {
 		return x.Func
@@ -140,16 +154,34 @@ func (x *BadExpr) End() token.Pos    { return x.To }
 func (x *Ident) End() token.Pos      { return token.Pos(int(x.NamePos) + len(x.Name)) }
 func (x *BasicLit) End() token.Pos   { return token.Pos(int(x.ValuePos) + len(x.Value)) }
 func (x *ParenExpr) End() token.Pos  { return x.Rparen + 1 }
+func (x *EmptyExpr) End() token.Pos  { return x.Comma }
 func (x *CallExpr) End() token.Pos   { return x.Rparen + 1 }
-func (x *UnaryExpr) End() token.Pos  { return x.X.End() }
 func (x *BinaryExpr) End() token.Pos { return x.Y.End() }
+func (x *ArrayLit) End() token.Pos   { return x.Rbrace + 1 }
 //func (x *FuncType) End() token.Pos   { return x.Params.End() }
 
+// The postfix SPILL operator follows its operand.
+func (x *UnaryExpr) Pos() token.Pos {
+	if x.Op == token.SPILL {
+		return x.X.Pos()
+	}
+	return x.OpPos
+}
+
+func (x *UnaryExpr) End() token.Pos {
+	if x.Op == token.SPILL {
+		return x.OpPos + 1
+	}
+	return x.X.End()
+}
+
 func (*BadExpr) exprNode()    {}
 func (*Ident) exprNode()      {}
 func (*BasicLit) exprNode()   {}
 func (*ParenExpr) exprNode()  {}
+func (*EmptyExpr) exprNode()  {}
 func (*CallExpr) exprNode()   {}
 func (*UnaryExpr) exprNode()  {}
 func (*BinaryExpr) exprNode() {}
+func (*ArrayLit) exprNode()   {}
 //func (*FuncType) exprNode()   {}
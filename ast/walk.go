@@ -33,7 +33,7 @@ func Walk(v Visitor, node Node) {
 			Walk(v, f)
 		}
 
-	case *BadExpr, *Ident, *BasicLit:
+	case *BadExpr, *Ident, *BasicLit, *EmptyExpr:
 
 	case *ParenExpr:
 		Walk(v, n.X)
@@ -49,6 +49,11 @@ func Walk(v Visitor, node Node) {
 		Walk(v, n.X)
 		Walk(v, n.Y)
 
+	case *ArrayLit:
+		for _, row := range n.Rows {
+			walkExprList(v, row)
+		}
+
 	/*case *FuncType:
 		if n.Params != nil {
 			Walk(v, n.Params)
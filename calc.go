@@ -4,6 +4,9 @@ import (
 	"bufio"
 	"fmt"
 	"github.com/ajz01/calc/ast"
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/format"
+	"github.com/ajz01/calc/funcs"
 	"github.com/ajz01/calc/parser"
 	"os"
 )
@@ -14,6 +17,7 @@ func Calc(sym string) (ast.Expr, error) {
 }
 
 func main() {
+	e := &eval.Evaluator{Funcs: funcs.Default()}
 	r := bufio.NewReader(os.Stdin)
 	for {
 		fmt.Printf("Enter formula or (quit)\n")
@@ -25,9 +29,19 @@ func main() {
 		if arg == "quit\n" {
 			break
 		}
-		_, err = Calc(arg)
+		x, err := Calc(arg)
 		if err != nil {
 			fmt.Printf("Error ParseBytes(%s)\n", arg)
+			continue
 		}
+		fmt.Println(display(e.Eval(x)))
+	}
+}
+
+// display formats a result the way a cell with the General format shows it.
+func display(v eval.Value) string {
+	if n, ok := v.(eval.Number); ok {
+		return format.Value(float64(n))
 	}
+	return v.String()
 }
@@ -0,0 +1,172 @@
+package eval
+
+import "strings"
+
+// NewArray returns an array of the given size with all elements nil.
+func NewArray(rows, cols int) *Array {
+	return &Array{Rows: rows, Cols: cols, Values: make([]Value, rows*cols)}
+}
+
+// At returns the element in row i and column j, counted from 0.
+func (a *Array) At(i, j int) Value { return a.Values[i*a.Cols+j] }
+
+// Set sets the element in row i and column j.
+func (a *Array) Set(i, j int, v Value) { a.Values[i*a.Cols+j] = v }
+
+// Slice returns the part of a of the given size with its top left
+// element at row i and column j.
+func (a *Array) Slice(i, j, rows, cols int) *Array {
+	s := NewArray(rows, cols)
+	for r := 0; r < rows; r++ {
+		copy(s.Values[r*cols:(r+1)*cols], a.Values[(i+r)*a.Cols+j:])
+	}
+	return s
+}
+
+// String formats a as an array constant such as {1,2;"a",TRUE}.
+func (a *Array) String() string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i < a.Rows; i++ {
+		if i > 0 {
+			b.WriteByte(';')
+		}
+		for j := 0; j < a.Cols; j++ {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			v := a.At(i, j)
+			if t, ok := v.(Text); ok {
+				b.WriteString(`"` + strings.Replace(string(t), `"`, `""`, -1) + `"`)
+			} else {
+				b.WriteString(v.String())
+			}
+		}
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// elem returns the element of a in row i and column j after stretching a
+// single row or column. Elements outside a are #N/A.
+func (a *Array) elem(i, j int) Value {
+	if a.Rows == 1 {
+		i = 0
+	}
+	if a.Cols == 1 {
+		j = 0
+	}
+	if i >= a.Rows || j >= a.Cols {
+		return ErrNA
+	}
+	return a.At(i, j)
+}
+
+// Broadcast calls f element by element for the arrays among args and
+// returns the results as an array. The result has as many rows and
+// columns as the largest array. An array with a single row or column is
+// stretched to that size, while missing elements of smaller arrays are
+// #N/A. Arguments that are not arrays are passed to every call.
+func Broadcast(args []Value, f func(args []Value) Value) *Array {
+	rows, cols := 1, 1
+	for _, v := range args {
+		if a, ok := v.(*Array); ok {
+			if a.Rows > rows {
+				rows = a.Rows
+			}
+			if a.Cols > cols {
+				cols = a.Cols
+			}
+		}
+	}
+	r := NewArray(rows, cols)
+	elems := make([]Value, len(args))
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			for k, v := range args {
+				elems[k] = v
+				if a, ok := v.(*Array); ok {
+					elems[k] = a.elem(i, j)
+				}
+			}
+			r.Set(i, j, f(elems))
+		}
+	}
+	return r
+}
+
+// Array returns v as an array if it is an array or a reference to more
+// than one cell.
+func (e *Evaluator) Array(v Value) (*Array, bool) {
+	switch x := Force(v).(type) {
+	case *Array:
+		return x, true
+	case *Ref:
+		if x.Range.From == x.Range.To {
+			return nil, false
+		}
+		a := NewArray(x.Range.Rows(), x.Range.Cols())
+		copy(a.Values, e.Values(x))
+		return a, true
+	}
+	return nil, false
+}
+
+// operand resolves an operand of an operator: ranges become arrays and
+// references to a single cell the cell's value.
+func (e *Evaluator) operand(v Value) Value {
+	if a, ok := e.Array(v); ok {
+		return a
+	}
+	return e.Deref(v)
+}
+
+// scalar returns the single value of an element of a lifted call: the
+// value of a cell reference or the first element of an array.
+func (e *Evaluator) scalar(v Value) Value {
+	if a, ok := e.Array(v); ok {
+		return a.At(0, 0)
+	}
+	return e.Deref(v)
+}
+
+// liftCall calls f element by element when arrays or ranges are passed
+// for scalar parameters, which are all parameters of type Number, Text,
+// Logical or Err except the repeated parameter of a variadic function.
+// Lazy arguments are then evaluated and lifted as well if they are
+// arrays. It reports false if no argument needs lifting.
+func (e *Evaluator) liftCall(f *Func, args []Value) (Value, bool) {
+	var lifted []int
+	for i, v := range args {
+		p, _ := f.Sig.Param(i)
+		if p.Lazy || !p.Type.IsScalar() || f.Sig.Variadic && i >= len(f.Sig.Params)-1 {
+			continue
+		}
+		if a, ok := e.Array(v); ok {
+			args[i] = a
+			lifted = append(lifted, i)
+		}
+	}
+	if len(lifted) == 0 {
+		return nil, false
+	}
+	for i, v := range args {
+		if p, _ := f.Sig.Param(i); p.Lazy {
+			if a, ok := e.Array(v); ok {
+				args[i] = a
+				lifted = append(lifted, i)
+			}
+		}
+	}
+	vals := make([]Value, len(lifted))
+	for k, i := range lifted {
+		vals[k] = args[i]
+	}
+	return Broadcast(vals, func(vals []Value) Value {
+		a := append([]Value(nil), args...)
+		for k, i := range lifted {
+			a[i] = vals[k]
+		}
+		return e.scalar(f.Call(e, a))
+	}), true
+}
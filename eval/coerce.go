@@ -0,0 +1,235 @@
+package eval
+
+import (
+	"github.com/ajz01/calc/token"
+	"github.com/ajz01/calc/types"
+	"math"
+	"strings"
+)
+
+// ToNumber converts a scalar value to a number.
+func ToNumber(v Value) (float64, error) {
+	switch x := v.(type) {
+	case Number:
+		return float64(x), nil
+	case Complex:
+		if imag(x) != 0 {
+			return 0, ErrValue
+		}
+		return real(x), nil
+	case Bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	case Blank:
+		return 0, nil
+	case Text:
+		f, ok := ParseNumber(string(x))
+		if !ok {
+			return 0, ErrValue
+		}
+		return f, nil
+	case Error:
+		return 0, x
+	}
+	return 0, ErrValue
+}
+
+// ToText converts a scalar value to text.
+func ToText(v Value) (string, error) {
+	switch x := v.(type) {
+	case Error:
+		return "", x
+	case *Ref:
+		return "", ErrValue
+	}
+	return v.String(), nil
+}
+
+// ToBool converts a scalar value to a logical value.
+func ToBool(v Value) (bool, error) {
+	switch x := v.(type) {
+	case Bool:
+		return bool(x), nil
+	case Number:
+		return x != 0, nil
+	case Blank:
+		return false, nil
+	case Text:
+		switch strings.ToUpper(string(x)) {
+		case "TRUE":
+			return true, nil
+		case "FALSE":
+			return false, nil
+		}
+		return false, ErrValue
+	case Error:
+		return false, x
+	}
+	return false, ErrValue
+}
+
+// ParseNumber converts numeric text such as "12", " -1.5e3 ", "1,234.5",
+// "$12", "50%" or "(7)" to a number, as types.ParseNumber does.
+func ParseNumber(s string) (float64, bool) {
+	return types.ParseNumber(s)
+}
+
+// Unary applies the unary operator op to the scalar value x. Unary plus
+// returns x unchanged.
+func Unary(op token.Token, x Value) Value {
+	if op == token.ADD {
+		return x
+	}
+	if c, ok := x.(Complex); ok && op == token.SUB {
+		return -c
+	}
+	f, err := ToNumber(x)
+	if err != nil {
+		return ErrorValue(err)
+	}
+	if op == token.SUB {
+		return Number(-f)
+	}
+	return ErrValue
+}
+
+// Binary applies the binary operator op to the scalar values x and y.
+func Binary(op token.Token, x, y Value) Value {
+	if err, ok := x.(Error); ok {
+		return err
+	}
+
+	switch op {
+	case token.EQL, token.NOT, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		if err, ok := y.(Error); ok {
+			return err
+		}
+	}
+	switch op {
+	case token.EQL:
+		return Bool(Compare(x, y) == 0)
+	case token.NOT:
+		return Bool(Compare(x, y) != 0)
+	case token.LSS:
+		return Bool(Compare(x, y) < 0)
+	case token.LEQ:
+		return Bool(Compare(x, y) <= 0)
+	case token.GTR:
+		return Bool(Compare(x, y) > 0)
+	case token.GEQ:
+		return Bool(Compare(x, y) >= 0)
+	case token.CONCAT:
+		a, err := ToText(x)
+		if err != nil {
+			return ErrorValue(err)
+		}
+		b, err := ToText(y)
+		if err != nil {
+			return ErrorValue(err)
+		}
+		return Text(a + b)
+	}
+
+	if _, ok := x.(Complex); ok {
+		return complexArith(op, x, y)
+	}
+	if _, ok := y.(Complex); ok {
+		return complexArith(op, x, y)
+	}
+
+	a, err := ToNumber(x)
+	if err != nil {
+		return ErrorValue(err)
+	}
+	b, err := ToNumber(y)
+	if err != nil {
+		return ErrorValue(err)
+	}
+	var r float64
+	switch op {
+	case token.ADD:
+		r = a + b
+	case token.SUB:
+		r = a - b
+	case token.MUL:
+		r = a * b
+	case token.QUO:
+		if b == 0 {
+			return ErrDiv0
+		}
+		r = a / b
+	case token.EXP:
+		if a == 0 && b <= 0 {
+			if b == 0 {
+				return ErrNum
+			}
+			return ErrDiv0
+		}
+		r = math.Pow(a, b)
+	default:
+		return ErrValue
+	}
+	return NumberOrError(r)
+}
+
+// Compare orders scalar values the way spreadsheet comparison operators
+// do: numbers before text before logical values, with text compared
+// case-insensitively. Blank compares as the zero value of the other
+// operand's type.
+func Compare(x, y Value) int {
+	if _, ok := x.(Blank); ok {
+		x = zero(y)
+	}
+	if _, ok := y.(Blank); ok {
+		y = zero(x)
+	}
+	rx, ry := rank(x), rank(y)
+	if rx != ry {
+		return rx - ry
+	}
+	switch a := x.(type) {
+	case Number:
+		if b, ok := y.(Complex); ok {
+			return compareComplex(complex(float64(a), 0), complex128(b))
+		}
+		return cmpFloat(float64(a), float64(y.(Number)))
+	case Complex:
+		b, _ := complexOperand(y)
+		return compareComplex(complex128(a), b)
+	case Text:
+		return strings.Compare(strings.ToLower(string(a)), strings.ToLower(string(y.(Text))))
+	case Bool:
+		switch b := y.(Bool); {
+		case a == b:
+			return 0
+		case bool(b):
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func rank(v Value) int {
+	switch v.(type) {
+	case Number, Complex, Blank:
+		return 0
+	case Text:
+		return 1
+	case Bool:
+		return 2
+	}
+	return 3
+}
+
+func zero(v Value) Value {
+	switch v.(type) {
+	case Text:
+		return Text("")
+	case Bool:
+		return Bool(false)
+	}
+	return Number(0)
+}
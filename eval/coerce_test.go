@@ -0,0 +1,134 @@
+package eval
+
+import (
+	"github.com/ajz01/calc/token"
+	"testing"
+)
+
+func TestToNumber(t *testing.T) {
+	tests := []struct {
+		v    Value
+		want float64
+		err  error
+	}{
+		{Number(2.5), 2.5, nil},
+		{Bool(true), 1, nil},
+		{Bool(false), 0, nil},
+		{Blank{}, 0, nil},
+		{Text("3"), 3, nil},
+		{Text(" -1.5e3 "), -1500, nil},
+		{Text("1,234.5"), 1234.5, nil},
+		{Text("$12"), 12, nil},
+		{Text("-$12"), -12, nil},
+		{Text("50%"), 0.5, nil},
+		{Text("(7)"), -7, nil},
+		{Text(".5"), 0.5, nil},
+		{Text(""), 0, ErrValue},
+		{Text("abc"), 0, ErrValue},
+		{Text("Inf"), 0, ErrValue},
+		{Text("NaN"), 0, ErrValue},
+		{Text("0x10"), 0, ErrValue},
+		{Text("1_000"), 0, ErrValue},
+		{Text("1.5,0"), 0, ErrValue},
+		{Text("1e999"), 0, ErrValue},
+		{ErrNA, 0, ErrNA},
+		{&Ref{}, 0, ErrValue},
+	}
+	for _, test := range tests {
+		got, err := ToNumber(test.v)
+		if got != test.want || err != test.err {
+			t.Errorf("ToNumber(%#v) = %v, %v want %v, %v", test.v, got, err, test.want, test.err)
+		}
+	}
+}
+
+func TestToText(t *testing.T) {
+	tests := []struct {
+		v    Value
+		want string
+		err  error
+	}{
+		{Number(3.5), "3.5", nil},
+		{Number(0.1 + 0.2), "0.3", nil},
+		{Number(1e20), "1E+20", nil},
+		{Bool(true), "TRUE", nil},
+		{Blank{}, "", nil},
+		{Text("a"), "a", nil},
+		{ErrDiv0, "", ErrDiv0},
+	}
+	for _, test := range tests {
+		got, err := ToText(test.v)
+		if got != test.want || err != test.err {
+			t.Errorf("ToText(%#v) = %q, %v want %q, %v", test.v, got, err, test.want, test.err)
+		}
+	}
+}
+
+func TestToBool(t *testing.T) {
+	tests := []struct {
+		v    Value
+		want bool
+		err  error
+	}{
+		{Number(-2), true, nil},
+		{Number(0), false, nil},
+		{Text("true"), true, nil},
+		{Text("False"), false, nil},
+		{Text("1"), false, ErrValue},
+		{Blank{}, false, nil},
+		{ErrNum, false, ErrNum},
+	}
+	for _, test := range tests {
+		got, err := ToBool(test.v)
+		if got != test.want || err != test.err {
+			t.Errorf("ToBool(%#v) = %v, %v want %v, %v", test.v, got, err, test.want, test.err)
+		}
+	}
+}
+
+func TestOperators(t *testing.T) {
+	tests := []struct {
+		op   token.Token
+		x, y Value
+		want Value
+	}{
+		{token.ADD, Text("3"), Number(1), Number(4)},
+		{token.ADD, Bool(true), Number(1), Number(2)},
+		{token.MUL, Blank{}, Number(5), Number(0)},
+		{token.SUB, Text("abc"), Number(1), ErrValue},
+		{token.ADD, Text("x"), ErrNA, ErrValue},
+		{token.ADD, ErrNA, Text("x"), ErrNA},
+		{token.ADD, ErrNA, ErrDiv0, ErrNA},
+		{token.ADD, Number(1), ErrDiv0, ErrDiv0},
+		{token.EQL, Text("abc"), Text("ABC"), Bool(true)},
+		{token.NOT, Text("abc"), Text("ABD"), Bool(true)},
+		{token.EQL, Text("3"), Number(3), Bool(false)},
+		{token.LSS, Number(1e99), Text("a"), Bool(true)},
+		{token.LSS, Text("z"), Bool(false), Bool(true)},
+		{token.EQL, Blank{}, Number(0), Bool(true)},
+		{token.EQL, Blank{}, Text(""), Bool(true)},
+		{token.EQL, Blank{}, Bool(false), Bool(true)},
+		{token.LSS, Text("a"), ErrNA, ErrNA},
+		{token.CONCAT, Number(1), Number(2.5), Text("12.5")},
+		{token.CONCAT, Bool(true), Blank{}, Text("TRUE")},
+		{token.CONCAT, Text("a"), ErrRef, ErrRef},
+		{token.QUO, Number(1), Blank{}, ErrDiv0},
+		{token.EXP, Number(0), Number(0), ErrNum},
+		{token.EXP, Number(-8), Number(1.0 / 3), ErrNum},
+		{token.MUL, Number(1e200), Number(1e200), ErrNum},
+	}
+	for _, test := range tests {
+		if got := Binary(test.op, test.x, test.y); got != test.want {
+			t.Errorf("Binary(%v, %#v, %#v) = %v want %v", test.op, test.x, test.y, got, test.want)
+		}
+	}
+	if got := Unary(token.SUB, Text("3")); got != Number(-3) {
+		t.Errorf(`Unary(-, "3") = %v want -3`, got)
+	}
+	if got := Unary(token.ADD, Text("abc")); got != Text("abc") {
+		t.Errorf(`Unary(+, "abc") = %v want abc`, got)
+	}
+	if got := Unary(token.SUB, Bool(true)); got != Number(-1) {
+		t.Errorf("Unary(-, TRUE) = %v want -1", got)
+	}
+}
@@ -0,0 +1,197 @@
+package eval
+
+import (
+	"github.com/ajz01/calc/token"
+	"math"
+	"math/cmplx"
+	"strconv"
+	"strings"
+)
+
+// String formats x the way spreadsheets write complex numbers: the real
+// part is left out if it is zero and a coefficient of 1 is left out of
+// the imaginary part, as in 3+4i, 2.5, -i or 3-i.
+func (x Complex) String() string {
+	re, im := real(x), imag(x)
+	if im == 0 {
+		return Number(re).String()
+	}
+	var s string
+	if re != 0 {
+		s = Number(re).String()
+		if im > 0 {
+			s += "+"
+		}
+	}
+	switch im {
+	case 1:
+	case -1:
+		s += "-"
+	default:
+		s += Number(im).String()
+	}
+	return s + "i"
+}
+
+// ParseComplex parses text of the form x, yi or x+yi, where the suffix
+// may also be j and y may be left out as in 3-i.
+func ParseComplex(s string) (complex128, bool) {
+	if s == "" {
+		return 0, false
+	}
+	last := s[len(s)-1]
+	if last != 'i' && last != 'j' {
+		f, err := strconv.ParseFloat(s, 64)
+		return complex(f, 0), err == nil
+	}
+	s = s[:len(s)-1]
+	// the imaginary part starts at the last sign not part of an exponent
+	i := strings.LastIndexAny(s, "+-")
+	for i > 0 && (s[i-1] == 'e' || s[i-1] == 'E') {
+		i = strings.LastIndexAny(s[:i-1], "+-")
+	}
+	if i < 0 {
+		i = 0
+	}
+	var re float64
+	if i > 0 {
+		var err error
+		if re, err = strconv.ParseFloat(s[:i], 64); err != nil {
+			return 0, false
+		}
+	}
+	var im float64
+	switch t := s[i:]; t {
+	case "", "+":
+		im = 1
+	case "-":
+		im = -1
+	default:
+		var err error
+		if im, err = strconv.ParseFloat(t, 64); err != nil {
+			return 0, false
+		}
+	}
+	return complex(re, im), true
+}
+
+// ToComplex converts a scalar value to a complex number. Text is parsed
+// with ParseComplex and reports #NUM! if it is not a complex number.
+func ToComplex(v Value) (complex128, error) {
+	switch x := v.(type) {
+	case Complex:
+		return complex128(x), nil
+	case Number:
+		return complex(float64(x), 0), nil
+	case Blank:
+		return 0, nil
+	case Text:
+		c, ok := ParseComplex(strings.TrimSpace(string(x)))
+		if !ok {
+			return 0, ErrNum
+		}
+		return c, nil
+	case Error:
+		return 0, x
+	}
+	return 0, ErrValue
+}
+
+// Complex converts v to a complex number, resolving single cell
+// references.
+func (e *Evaluator) Complex(v Value) (complex128, error) {
+	return ToComplex(e.Deref(v))
+}
+
+// ComplexOrError returns c as a Complex, or #NUM! if either part is not
+// finite.
+func ComplexOrError(c complex128) Value {
+	if cmplx.IsNaN(c) || cmplx.IsInf(c) {
+		return ErrNum
+	}
+	return Complex(c)
+}
+
+// complexArith applies an arithmetic operator to operands of which at
+// least one is complex. The other operand converts as a number.
+func complexArith(op token.Token, x, y Value) Value {
+	a, err := complexOperand(x)
+	if err != nil {
+		return ErrorValue(err)
+	}
+	b, err := complexOperand(y)
+	if err != nil {
+		return ErrorValue(err)
+	}
+	var r complex128
+	switch op {
+	case token.ADD:
+		r = a + b
+	case token.SUB:
+		r = a - b
+	case token.MUL:
+		r = a * b
+	case token.QUO:
+		if b == 0 {
+			return ErrDiv0
+		}
+		r = a / b
+	case token.EXP:
+		if a == 0 {
+			if real(b) > 0 {
+				return Complex(0)
+			}
+			return ErrNum
+		}
+		r = ComplexPow(a, b)
+	default:
+		return ErrValue
+	}
+	return ComplexOrError(r)
+}
+
+// ComplexPow returns a**b. Integer powers are computed by repeated
+// multiplication, so that for example i**2 is exactly -1.
+func ComplexPow(a, b complex128) complex128 {
+	n := real(b)
+	if imag(b) != 0 || n != math.Trunc(n) || math.Abs(n) > 64 {
+		return cmplx.Pow(a, b)
+	}
+	r := complex(1, 0)
+	for p, k := a, int(math.Abs(n)); k > 0; k >>= 1 {
+		if k&1 != 0 {
+			r *= p
+		}
+		p *= p
+	}
+	if n < 0 {
+		return 1 / r
+	}
+	return r
+}
+
+func complexOperand(v Value) (complex128, error) {
+	if c, ok := v.(Complex); ok {
+		return complex128(c), nil
+	}
+	f, err := ToNumber(v)
+	return complex(f, 0), err
+}
+
+// compareComplex orders complex numbers by real and then imaginary part.
+func compareComplex(a, b complex128) int {
+	if real(a) != real(b) {
+		return cmpFloat(real(a), real(b))
+	}
+	return cmpFloat(imag(a), imag(b))
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,34 @@
+// Package eval evaluates formula expressions against a Context of cell
+// values.
+//
+// # Coercion
+//
+// Operators and functions convert their operands with the same rules as
+// spreadsheets. ToNumber, ToText and ToBool implement the conversions
+// and Unary and Binary the operators:
+//
+//	value      to number           to text            to logical
+//	Number     itself              15 digits, "3.5"   FALSE if zero
+//	Text       ParseNumber         itself             "TRUE", "FALSE"
+//	Bool       1 or 0              "TRUE", "FALSE"    itself
+//	Blank      0                   ""                 FALSE
+//	Error      the error           the error          the error
+//
+// Conversions not in the table, such as "abc" to a number or a range to
+// text, fail with #VALUE!. Numeric text may have spaces around it,
+// thousands separators, a $ sign, a trailing % or parentheses for a
+// negative number, so "3"+1 is 4 and "50%"*2 is 1; Go number syntax such
+// as "0x10" or "Inf" is not numeric text.
+//
+// The arithmetic operators + - * / ^ convert both operands to numbers,
+// & converts both to text, and the comparison operators = <> < <= > >=
+// do not convert at all: they order numbers before text before logical
+// values, so "3"=3 is FALSE and 1E+99<"a" and "z"<FALSE are TRUE. Text
+// compares case-insensitively and Blank compares as 0, "" or FALSE
+// depending on the other operand.
+//
+// Errors propagate from left to right: the result of an operator is the
+// first error met while converting its operands in order. "x"+#N/A is
+// #VALUE! because "x" fails to convert before #N/A is reached, while
+// #N/A+"x" is #N/A. Results that are not finite numbers are #NUM!.
+package eval
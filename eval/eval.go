@@ -0,0 +1,396 @@
+package eval
+
+import (
+	"github.com/ajz01/calc/ast"
+	"github.com/ajz01/calc/ref"
+	"github.com/ajz01/calc/token"
+	"math"
+	"math/bits"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Context gives the evaluator access to cell values. Value returns nil
+// or Blank for empty cells.
+type Context interface {
+	Value(sheet string, c ref.Cell) Value
+}
+
+// Evaluator evaluates formulas. Sheet and Cell locate the formula being
+// evaluated; unqualified references are resolved against Sheet.
+type Evaluator struct {
+	Context Context
+	Funcs   *Registry
+	Sheet   string
+	Cell    ref.Cell
+
+	// Date1904 selects the 1904 date system for serial dates.
+	Date1904 bool
+	// R1C1 reads references in R1C1 notation, relative to Cell, for
+	// formulas parsed with parser.R1C1.
+	R1C1 bool
+	// Clock returns the current time for functions such as NOW; if nil
+	// time.Now is used.
+	Clock func() time.Time
+	// Rand returns random numbers in [0, 1) for functions such as
+	// RANDARRAY; if nil math/rand is used.
+	Rand func() float64
+
+	scope *scope // names bound by LET and LAMBDA
+	names *scope // defined names being evaluated
+	depth int    // lambda calls being evaluated
+}
+
+// Now returns the current time from the evaluator's clock.
+func (e *Evaluator) Now() time.Time {
+	if e.Clock != nil {
+		return e.Clock()
+	}
+	return time.Now()
+}
+
+// Random returns a random number in [0, 1) from the evaluator's source.
+func (e *Evaluator) Random() float64 {
+	if e.Rand != nil {
+		return e.Rand()
+	}
+	return rand.Float64()
+}
+
+// Eval evaluates x. References to a single cell are resolved to the
+// cell's value, and references to more cells to the array of their
+// values. A lambda that is not called has no value and evaluates to
+// #CALC!.
+func (e *Evaluator) Eval(x ast.Expr) Value {
+	v := Force(e.eval(x))
+	if r, ok := v.(*Ref); ok && r.Range.From != r.Range.To {
+		v = &Array{Rows: r.Range.Rows(), Cols: r.Range.Cols(), Values: e.Values(r)}
+	}
+	v = e.Deref(v)
+	if _, ok := v.(*Lambda); ok {
+		return ErrCalc
+	}
+	return v
+}
+
+func (e *Evaluator) eval(x ast.Expr) Value {
+	switch n := x.(type) {
+	case *ast.BasicLit:
+		return e.lit(n)
+
+	case *ast.Ident:
+		if v, ok := e.lookup(n.Name); ok {
+			return v
+		}
+		return e.name(n.Name)
+
+	case *ast.ParenExpr:
+		return e.eval(n.X)
+
+	case *ast.UnaryExpr:
+		switch n.Op {
+		case token.SPILL:
+			return e.spillRef(e.eval(n.X))
+		case token.AT:
+			return e.intersect(e.eval(n.X))
+		}
+		return e.unary(n.Op, e.eval(n.X))
+
+	case *ast.BinaryExpr:
+		return e.binary(n.Op, e.eval(n.X), e.eval(n.Y))
+
+	case *ast.EmptyExpr:
+		return Blank{}
+
+	case *ast.CallExpr:
+		return e.call(n)
+
+	case *ast.ArrayLit:
+		return e.array(n)
+	}
+	return ErrValue
+}
+
+func (e *Evaluator) array(n *ast.ArrayLit) Value {
+	a := NewArray(len(n.Rows), len(n.Rows[0]))
+	for i, row := range n.Rows {
+		for j, x := range row {
+			a.Set(i, j, e.Eval(x))
+		}
+	}
+	return a
+}
+
+func (e *Evaluator) lit(n *ast.BasicLit) Value {
+	switch n.Kind {
+	case token.INT, token.FLOAT:
+		f, err := parseNumber(n.Value)
+		if err != nil {
+			return ErrNum
+		}
+		return Number(f)
+	case token.IMAG:
+		f, err := parseNumber(strings.TrimSuffix(n.Value, "i"))
+		if err != nil {
+			return ErrNum
+		}
+		return Complex(complex(0, f))
+	case token.STRING:
+		return Text(unquote(n.Value))
+	case token.BOOL:
+		return Bool(strings.EqualFold(n.Value, "TRUE"))
+	case token.ERR, token.ERREF:
+		return Error(strings.ToUpper(n.Value))
+	case token.REF, token.RNG:
+		parse := ref.Parse
+		if e.R1C1 {
+			parse = func(s string) (ref.Ref, error) { return ref.ParseR1C1(s, e.Cell) }
+		}
+		r, err := parse(n.Value)
+		if err != nil {
+			return ErrRef
+		}
+		return &Ref{Sheet: r.Sheet, Range: r.Range}
+	}
+	return ErrValue
+}
+
+func (e *Evaluator) call(n *ast.CallExpr) Value {
+	var f *Func
+	name, ok := e.funcName(n.Fun)
+	if ok && e.Funcs != nil {
+		f, _ = e.Funcs.Func(name)
+	}
+	if f == nil {
+		// a lambda, possibly held by a defined name
+		return e.callValue(n.Fun, n.Args)
+	}
+	if len(n.Args) < f.Sig.MinArgs() {
+		return ErrValue
+	}
+	if max := f.Sig.MaxArgs(); max >= 0 && len(n.Args) > max {
+		return ErrValue
+	}
+	args := make([]Value, len(n.Args))
+	for i, a := range n.Args {
+		if p, _ := f.Sig.Param(i); p.Lazy {
+			args[i] = e.Lazy(a)
+			continue
+		}
+		args[i] = e.eval(a)
+	}
+	if v, ok := e.liftCall(f, args); ok {
+		return v
+	}
+	return f.Call(e, args)
+}
+
+// Thunk is the unevaluated argument of a lazy parameter. It is evaluated
+// at most once, on first use.
+type Thunk struct {
+	X ast.Expr
+	e *Evaluator
+	v Value
+}
+
+// Value evaluates the argument. References are returned unresolved.
+func (t *Thunk) Value() Value {
+	if t.v == nil {
+		t.v = t.e.eval(t.X)
+	}
+	return t.v
+}
+
+func (t *Thunk) String() string { return t.Value().String() }
+func (*Thunk) value()           {}
+
+// Force evaluates v if it is a Thunk.
+func Force(v Value) Value {
+	if t, ok := v.(*Thunk); ok {
+		return t.Value()
+	}
+	return v
+}
+
+func (e *Evaluator) unary(op token.Token, x Value) Value {
+	x = e.operand(x)
+	if _, ok := x.(*Array); ok {
+		return Broadcast([]Value{x}, func(v []Value) Value { return Unary(op, v[0]) })
+	}
+	return Unary(op, x)
+}
+
+func (e *Evaluator) binary(op token.Token, x, y Value) Value {
+	x, y = e.operand(x), e.operand(y)
+	_, ax := x.(*Array)
+	_, ay := y.(*Array)
+	if ax || ay {
+		return Broadcast([]Value{x, y}, func(v []Value) Value { return Binary(op, v[0], v[1]) })
+	}
+	return Binary(op, x, y)
+}
+
+// NumberOrError returns f as a Number, or #NUM! if f is not finite.
+func NumberOrError(f float64) Value {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return ErrNum
+	}
+	return Number(f)
+}
+
+// Deref resolves a reference to a single cell to the cell's value and an
+// array of one element to the element. Other references evaluate to
+// #VALUE!; other values are returned unchanged.
+func (e *Evaluator) Deref(v Value) Value {
+	v = Force(v)
+	if a, ok := v.(*Array); ok && len(a.Values) == 1 {
+		return a.Values[0]
+	}
+	r, ok := v.(*Ref)
+	if !ok {
+		return v
+	}
+	if r.Range.From != r.Range.To {
+		return ErrValue
+	}
+	return e.cell(r.Sheet, r.Range.From)
+}
+
+func (e *Evaluator) cell(sheet string, c ref.Cell) Value {
+	if e.Context == nil {
+		return Blank{}
+	}
+	if sheet == "" {
+		sheet = e.Sheet
+	}
+	if v := e.Context.Value(sheet, c); v != nil {
+		return v
+	}
+	return Blank{}
+}
+
+// Values returns the values of the cells referenced by v, or the elements
+// of an array, in row-major order. Other values are returned as a single
+// element slice.
+func (e *Evaluator) Values(v Value) []Value {
+	v = Force(v)
+	if a, ok := v.(*Array); ok {
+		return a.Values
+	}
+	r, ok := v.(*Ref)
+	if !ok {
+		return []Value{v}
+	}
+	vals := make([]Value, 0, r.Range.Rows()*r.Range.Cols())
+	for row := r.Range.From.Row; row <= r.Range.To.Row; row++ {
+		for col := r.Range.From.Col; col <= r.Range.To.Col; col++ {
+			vals = append(vals, e.cell(r.Sheet, ref.Cell{Row: row, Col: col}))
+		}
+	}
+	return vals
+}
+
+// Number converts v to a number, resolving single cell references.
+func (e *Evaluator) Number(v Value) (float64, error) {
+	return ToNumber(e.Deref(v))
+}
+
+// Text converts v to text, resolving single cell references.
+func (e *Evaluator) Text(v Value) (string, error) {
+	return ToText(e.Deref(v))
+}
+
+// Bool converts v to a logical value, resolving single cell references.
+func (e *Evaluator) Bool(v Value) (bool, error) {
+	return ToBool(e.Deref(v))
+}
+
+// Numbers collects the numbers in args following the rules of aggregate
+// functions such as SUM: inside references and arrays only numbers count
+// and text, logical values and blanks are skipped, while direct arguments
+// are converted and fail if they are not numeric. Errors propagate.
+func (e *Evaluator) Numbers(args []Value) ([]float64, error) {
+	var nums []float64
+	for _, a := range args {
+		a = Force(a)
+		switch a.(type) {
+		case *Ref, *Array:
+			for _, v := range e.Values(a) {
+				switch x := v.(type) {
+				case Number:
+					nums = append(nums, float64(x))
+				case Error:
+					return nil, x
+				}
+			}
+			continue
+		}
+		if _, ok := a.(Blank); ok {
+			continue
+		}
+		f, err := ToNumber(a)
+		if err != nil {
+			return nil, err
+		}
+		nums = append(nums, f)
+	}
+	return nums, nil
+}
+
+// parseNumber parses a number literal as returned by the scanner.
+// Hexadecimal, octal and binary integers follow ParseBase, so 0xFFFFFFFFFF
+// is -1 like HEX2DEC("FFFFFFFFFF").
+func parseNumber(lit string) (float64, error) {
+	lit = strings.Replace(lit, "_", "", -1)
+	if len(lit) > 2 && lit[0] == '0' {
+		base := 0
+		switch lit[1] | ('a' - 'A') {
+		case 'x':
+			base = 16
+		case 'o':
+			base = 8
+		case 'b':
+			base = 2
+		}
+		if base == 16 && strings.ContainsAny(lit, ".pP") {
+			return strconv.ParseFloat(lit, 64)
+		}
+		if base != 0 {
+			return ParseBase(lit[2:], base)
+		}
+	}
+	return strconv.ParseFloat(lit, 64)
+}
+
+// ParseBase parses s as an integer in base 2, 8 or 16 the way BIN2DEC,
+// OCT2DEC and HEX2DEC do: s has at most 10 digits, and with 10 digits
+// and the top bit set it is a negative number in two's complement. The
+// empty string is 0. Other input reports #NUM!.
+func ParseBase(s string, base int) (float64, error) {
+	if len(s) > 10 {
+		return 0, ErrNum
+	}
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(s, base, 64)
+	if err != nil || n < 0 {
+		return 0, ErrNum
+	}
+	size := uint(10 * bits.Len(uint(base-1)))
+	if n >= 1<<(size-1) {
+		n -= 1 << size
+	}
+	return float64(n), nil
+}
+
+// unquote strips the quotes of a string literal and collapses doubled
+// quotes.
+func unquote(lit string) string {
+	if len(lit) >= 2 && lit[0] == '"' && lit[len(lit)-1] == '"' {
+		lit = lit[1 : len(lit)-1]
+	}
+	return strings.Replace(lit, `""`, `"`, -1)
+}
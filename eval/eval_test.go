@@ -0,0 +1,327 @@
+package eval
+
+import (
+	"github.com/ajz01/calc/ast"
+	"github.com/ajz01/calc/parser"
+	"github.com/ajz01/calc/ref"
+	"github.com/ajz01/calc/types"
+	"strings"
+	"testing"
+)
+
+type cells map[string]Value
+
+func (c cells) Value(sheet string, cell ref.Cell) Value {
+	return c[cell.String()]
+}
+
+var testCells = cells{
+	"A1": Number(1),
+	"A2": Number(2),
+	"A3": Text("x"),
+	"B1": Text("3"),
+	"B2": ErrNA,
+}
+
+func testEval(t *testing.T, src string) Value {
+	x, err := parser.ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("ParseBytes(%q) %v", src, err)
+	}
+	e := &Evaluator{Context: testCells, Funcs: testFuncs()}
+	return e.Eval(x)
+}
+
+// testFuncs returns a registry with COUNTNUM, which counts the numbers
+// among its arguments.
+func testFuncs() *Registry {
+	funcs := NewRegistry()
+	funcs.Register(&Func{
+		Name: "COUNTNUM",
+		Sig:  types.Signature{Params: []types.Param{{Name: "value", Type: types.Any}}, Variadic: true, Result: types.Number},
+		Call: func(e *Evaluator, args []Value) Value {
+			nums, err := e.Numbers(args)
+			if err != nil {
+				return ErrorValue(err)
+			}
+			return Number(len(nums))
+		},
+	})
+	return funcs
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{"1+2*3", Number(7)},
+		{"(1+2)*3", Number(9)},
+		{"2^3^2", Number(64)},
+		{"-2^2", Number(4)},
+		{"10/4", Number(2.5)},
+		{"0x1F+0b11", Number(34)},
+		{"0xFFFFFFFFFF", Number(-1)},
+		{"0b1000000000+0o7", Number(-505)},
+		{"1_000", Number(1000)},
+		{`"abc"`, Text("abc")},
+		{"A1+A2", Number(3)},
+		{"B1*2", Number(6)},
+		{"$A$1+A$2*$A2", Number(5)},
+		{"C1+1", Number(1)},
+		{"A1<A2", Bool(true)},
+		{`A3="X"`, Bool(true)},
+		{"COUNTNUM(A1:B2)", ErrNA},
+		{"COUNTNUM(A1:A3,1)", Number(3)},
+		{"countnum(A1)", Number(1)},
+		{`"3"+1`, Number(4)},
+		{"TRUE+1", Number(2)},
+		{`1&2&"x"`, Text("12x")},
+		{`"a"&1+2`, Text("a3")},
+		{`1&2=12`, Bool(false)},
+		{`"abc"<>"ABC"`, Bool(false)},
+		{"C1&A3", Text("x")},
+		{"3+4i", Complex(3 + 4i)},
+		{"-(1-2.5i)", Complex(-1 + 2.5i)},
+		{"(1+2i)*(3-1i)", Complex(5 + 5i)},
+		{"2i/1i", Complex(2)},
+		{"1i^2", Complex(-1)},
+		{"3+4i=4i+3", Bool(true)},
+	}
+	for _, test := range tests {
+		if got := testEval(t, test.src); got != test.want {
+			t.Errorf("Eval(%q) = %v want %v", test.src, got, test.want)
+		}
+	}
+}
+
+func TestEvalR1C1(t *testing.T) {
+	for src, want := range map[string]Value{"R[-1]C+RC": Number(3), "R1C1*2": Number(2), "R[-3]C": ErrRef} {
+		x, err := parser.ParseMode([]byte(src), parser.R1C1)
+		if err != nil {
+			t.Fatalf("ParseMode(%q) %v", src, err)
+		}
+		e := &Evaluator{Context: testCells, R1C1: true, Cell: ref.Cell{Row: 2, Col: 1}}
+		if got := e.Eval(x); got != want {
+			t.Errorf("Eval(%q) = %v want %v", src, got, want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{"1/0", ErrDiv0},
+		{"0^-1", ErrDiv0},
+		{`"abc"*2`, ErrValue},
+		{"A3+1", ErrValue},
+		{"B2+A3", ErrNA},
+		{"A3+B2", ErrValue},
+		{"FOO(1)", ErrName},
+		{"name", ErrName},
+		{"1i/0", ErrDiv0},
+		{"0x10000000000", ErrNum},
+		{`"x"+1i`, ErrValue},
+		{"#N/A+1", ErrNA},
+		{"#calc!", ErrCalc},
+	}
+	for _, test := range tests {
+		if got := testEval(t, test.src); got != test.want {
+			t.Errorf("Eval(%q) = %v want %v", test.src, got, test.want)
+		}
+	}
+}
+
+func TestArray(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"{1,2,3}+1", "{2,3,4}"},
+		{"-{1;2}", "{-1;-2}"},
+		{"A1:A2*2", "{2;4}"},
+		{"{1,2;3,4}*{10,100}", "{10,200;30,400}"},
+		{"{1;2}&{\"a\",\"b\"}", `{"1a","1b";"2a","2b"}`},
+		{"{1,2,3}+{1,2}", "{2,4,#N/A}"},
+		{"{1,2}/{1,0}", "{1,#DIV/0!}"},
+		{"{TRUE,\"x\";1.5,#N/A}", `{TRUE,"x";1.5,#N/A}`},
+		{"COUNTNUM({1,\"a\";TRUE,2})", "2"},
+		{"{5}+1", "6"},
+	}
+	for _, test := range tests {
+		if got := testEval(t, test.src); got.String() != test.want {
+			t.Errorf("Eval(%q) = %v want %v", test.src, got, test.want)
+		}
+	}
+}
+
+// spillCells holds the array formula results of a sheet by anchor cell.
+type spillCells struct {
+	cells
+	spills map[ref.Cell]*Array
+}
+
+func (c spillCells) Value(sheet string, cell ref.Cell) Value {
+	for at, a := range c.spills {
+		r, v := Spill(at, a, func(x ref.Cell) bool { return c.cells[x.String()] != nil })
+		if r.Contains(cell) {
+			if cell == at {
+				return v
+			}
+			return a.At(cell.Row-at.Row, cell.Col-at.Col)
+		}
+	}
+	return c.cells.Value(sheet, cell)
+}
+
+func (c spillCells) SpillRange(sheet string, cell ref.Cell) (ref.Range, bool) {
+	a, ok := c.spills[cell]
+	if !ok {
+		return ref.Range{}, false
+	}
+	r, v := Spill(cell, a, func(x ref.Cell) bool { return c.cells[x.String()] != nil })
+	return r, v != ErrSpill
+}
+
+func TestSpill(t *testing.T) {
+	ctx := spillCells{
+		cells: cells{"A1": Number(1), "C3": Number(9)},
+		spills: map[ref.Cell]*Array{
+			{Row: 1, Col: 4}: {Rows: 3, Cols: 1, Values: []Value{Number(1), Number(2), Number(3)}},
+			{Row: 2, Col: 2}: {Rows: 2, Cols: 2, Values: []Value{Number(1), Number(2), Number(3), Number(4)}},
+		},
+	}
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"D1#*2", "{2;4;6}"},
+		{"D1#", "{1;2;3}"},
+		{"B2:C3", "{#SPILL!,;,9}"},
+		{"A1:A1", "1"},
+		{"COUNTNUM(D1#)", "3"},
+		{"D3", "3"},
+		{"B2", "#SPILL!"},
+		{"B2#", "#REF!"},
+		{"A1#", "#REF!"},
+		{"@D1:D3", "2"},
+		{"@A3:A5", "#VALUE!"},
+		{"@A1:C1", "#VALUE!"},
+		{"@{5,6}", "5"},
+		{"@A1", "1"},
+	}
+	for _, test := range tests {
+		x, err := parser.ParseBytes([]byte(test.src))
+		if err != nil {
+			t.Fatalf("ParseBytes(%q) %v", test.src, err)
+		}
+		e := &Evaluator{Context: ctx, Funcs: testFuncs(), Cell: ref.Cell{Row: 2, Col: 5}}
+		if got := e.Eval(x); got.String() != test.want {
+			t.Errorf("Eval(%q) = %v want %v", test.src, got, test.want)
+		}
+	}
+	if r, v := Spill(ref.Cell{Row: ref.MaxRow, Col: 1}, NewArray(2, 1), func(ref.Cell) bool { return false }); v != ErrSpill || r.From != r.To {
+		t.Errorf("Spill past the last row = %v, %v want #SPILL!", r, v)
+	}
+}
+
+// namedCells defines names by their source, for formulas on any sheet.
+type namedCells struct {
+	cells
+	names map[string]string
+}
+
+func (c namedCells) Name(sheet, name string) (ast.Expr, string, bool) {
+	src, ok := c.names[strings.ToUpper(name)]
+	if !ok {
+		return nil, "", false
+	}
+	x, err := parser.ParseBytes([]byte(src))
+	if err != nil {
+		panic(err)
+	}
+	return x, sheet, true
+}
+
+func TestNames(t *testing.T) {
+	ctx := namedCells{
+		cells: testCells,
+		names: map[string]string{
+			"ONE":    "A1",
+			"COLUMN": "A1:A2",
+			"RATE":   "0.5",
+			"TWICE":  "RATE*4",
+			"SELF":   "SELF+1",
+			"LOOP":   "LOOP2",
+			"LOOP2":  "LOOP",
+		},
+	}
+	tests := []struct {
+		src  string
+		want Value
+	}{
+		{"one+1", Number(2)},
+		{"COUNTNUM(Column)", Number(2)},
+		{"TWICE+RATE", Number(2.5)},
+		{"undefined", ErrName},
+		{"SELF", ErrCalc},
+		{"LOOP", ErrCalc},
+		{"RATE(1)", ErrValue},
+	}
+	for _, test := range tests {
+		x, err := parser.ParseBytes([]byte(test.src))
+		if err != nil {
+			t.Fatalf("ParseBytes(%q) %v", test.src, err)
+		}
+		e := &Evaluator{Context: ctx, Funcs: testFuncs()}
+		if got := e.Eval(x); got != test.want {
+			t.Errorf("Eval(%q) = %v want %v", test.src, got, test.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	ordered := []Value{Number(-1), Blank{}, Number(2), Text("a"), Text("B"), Bool(false), Bool(true)}
+	for i := 1; i < len(ordered); i++ {
+		if c := Compare(ordered[i-1], ordered[i]); c >= 0 {
+			t.Errorf("Compare(%v, %v) = %d want < 0", ordered[i-1], ordered[i], c)
+		}
+	}
+	if c := Compare(Number(1), Complex(1+1i)); c >= 0 {
+		t.Errorf("Compare(1, 1+i) = %d want < 0", c)
+	}
+	if c := Compare(Text("abc"), Text("ABC")); c != 0 {
+		t.Errorf("Compare(abc, ABC) = %d want 0", c)
+	}
+}
+
+func TestComplex(t *testing.T) {
+	tests := []struct {
+		s    string
+		want complex128
+		ok   bool
+	}{
+		{"3+4i", 3 + 4i, true},
+		{"3-4.5j", 3 - 4.5i, true},
+		{"-i", -1i, true},
+		{"2.5", 2.5, true},
+		{"1e-3-2E+2i", 0.001 - 200i, true},
+		{"i", 1i, true},
+		{"3+4", 0, false},
+		{"4ii", 0, false},
+		{"", 0, false},
+	}
+	for _, test := range tests {
+		c, ok := ParseComplex(test.s)
+		if ok != test.ok || ok && c != test.want {
+			t.Errorf("ParseComplex(%q) = %v, %v want %v, %v", test.s, c, ok, test.want, test.ok)
+		}
+	}
+	for c, want := range map[Complex]string{3 + 4i: "3+4i", 3 - 1i: "3-i", 1i: "i", -2.5i: "-2.5i", 7: "7"} {
+		if s := c.String(); s != want {
+			t.Errorf("Complex(%v).String() = %q want %q", complex128(c), s, want)
+		}
+	}
+}
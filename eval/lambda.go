@@ -0,0 +1,102 @@
+package eval
+
+import (
+	"github.com/ajz01/calc/ast"
+	"strings"
+)
+
+// Lambda is a function value created by LAMBDA. Calling it evaluates
+// Body with Params bound to the arguments, in the scope in which the
+// lambda was created.
+type Lambda struct {
+	Params []string
+	Body   ast.Expr
+	e      *Evaluator
+}
+
+// NewLambda returns a lambda that evaluates body in the scope of e.
+func (e *Evaluator) NewLambda(params []string, body ast.Expr) *Lambda {
+	return &Lambda{Params: params, Body: body, e: e}
+}
+
+// Call calls l with args, which must match its parameters in number.
+func (l *Lambda) Call(args []Value) Value {
+	if len(args) != len(l.Params) {
+		return ErrValue
+	}
+	if l.e.depth >= maxDepth {
+		return ErrNum
+	}
+	c := *l.e
+	c.names = nil // the body may call the name the lambda is defined as
+	c.depth++
+	s := &c
+	for i, name := range l.Params {
+		s = s.Bind(name, args[i])
+	}
+	return s.eval(l.Body)
+}
+
+func (l *Lambda) String() string { return "LAMBDA" }
+func (*Lambda) value()           {}
+
+// scope is a name bound by LET or LAMBDA and the scope it extends.
+type scope struct {
+	name  string // upper case
+	v     Value
+	outer *scope
+}
+
+// Bind returns an evaluator like e in which name refers to v. The name
+// shadows cell names and earlier bindings of the same name, ignoring
+// case.
+func (e *Evaluator) Bind(name string, v Value) *Evaluator {
+	s := *e
+	s.scope = &scope{name: strings.ToUpper(name), v: v, outer: e.scope}
+	return &s
+}
+
+func (e *Evaluator) lookup(name string) (Value, bool) {
+	name = strings.ToUpper(name)
+	for s := e.scope; s != nil; s = s.outer {
+		if s.name == name {
+			return Force(s.v), true
+		}
+	}
+	return nil, false
+}
+
+// Lazy returns x as a thunk to be evaluated by e on first use.
+func (e *Evaluator) Lazy(x ast.Expr) *Thunk {
+	return &Thunk{X: x, e: e}
+}
+
+// funcName returns the name of the function called by fun, or false if
+// fun is not a name or names a lambda bound by LET or LAMBDA.
+func (e *Evaluator) funcName(fun ast.Expr) (string, bool) {
+	id, ok := fun.(*ast.Ident)
+	if !ok {
+		return "", false
+	}
+	if _, local := e.lookup(id.Name); local {
+		return "", false
+	}
+	return id.Name, true
+}
+
+// callValue calls the lambda that fun evaluates to.
+func (e *Evaluator) callValue(fun ast.Expr, args []ast.Expr) Value {
+	fn := Force(e.eval(fun))
+	l, ok := fn.(*Lambda)
+	if !ok {
+		if err, ok := fn.(Error); ok {
+			return err
+		}
+		return ErrValue
+	}
+	vals := make([]Value, len(args))
+	for i, a := range args {
+		vals[i] = e.eval(a)
+	}
+	return l.Call(vals)
+}
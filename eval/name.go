@@ -0,0 +1,42 @@
+package eval
+
+import (
+	"github.com/ajz01/calc/ast"
+	"strings"
+)
+
+// Namer is implemented by contexts that define names for references,
+// constants and formulas. Name returns the definition of name as seen by
+// a formula on sheet, and the sheet that unqualified references in the
+// definition refer to, or false if the name is not defined.
+type Namer interface {
+	Name(sheet, name string) (x ast.Expr, on string, ok bool)
+}
+
+// maxDepth limits the nesting of lambda calls, so that a lambda that
+// calls itself through a defined name without end fails with #NUM!.
+const maxDepth = 1000
+
+// name evaluates the defined name. Names the context does not define are
+// #NAME?; a name whose definition needs its own value is #CALC!.
+func (e *Evaluator) name(name string) Value {
+	n, ok := e.Context.(Namer)
+	if !ok {
+		return ErrName
+	}
+	x, on, ok := n.Name(e.Sheet, name)
+	if !ok {
+		return ErrName
+	}
+	name = strings.ToUpper(name)
+	for s := e.names; s != nil; s = s.outer {
+		if s.name == name {
+			return ErrCalc
+		}
+	}
+	d := *e
+	d.Sheet, d.R1C1 = on, false
+	d.scope = nil
+	d.names = &scope{name: name, outer: e.names}
+	return d.eval(x)
+}
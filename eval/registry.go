@@ -0,0 +1,67 @@
+package eval
+
+import (
+	"github.com/ajz01/calc/types"
+	"sort"
+	"strings"
+)
+
+// Func is a built-in function. Call receives the evaluated arguments;
+// references are passed unresolved so that functions can distinguish
+// ranges from direct arguments.
+type Func struct {
+	Name string
+	Sig  types.Signature
+	Call func(e *Evaluator, args []Value) Value
+	// Serial marks functions that must not be called concurrently, such
+	// as those that draw random numbers or refer to cells that only
+	// become known when they are called. Formulas that call them are
+	// computed one at a time.
+	Serial bool
+	// Volatile marks functions whose results may change although their
+	// arguments do not, such as NOW and RAND. Formulas that call them
+	// are computed by every recalculation.
+	Volatile bool
+}
+
+// Registry maps function names to functions. Names are case-insensitive.
+type Registry struct {
+	funcs map[string]*Func
+}
+
+func NewRegistry() *Registry {
+	return &Registry{funcs: make(map[string]*Func)}
+}
+
+// Register adds funcs to the registry, replacing functions of the same
+// name.
+func (r *Registry) Register(funcs ...*Func) {
+	for _, f := range funcs {
+		r.funcs[strings.ToUpper(f.Name)] = f
+	}
+}
+
+// Func returns the function called name.
+func (r *Registry) Func(name string) (*Func, bool) {
+	f, ok := r.funcs[strings.ToUpper(name)]
+	return f, ok
+}
+
+// Lookup returns the signature of function name. It implements
+// types.Funcs.
+func (r *Registry) Lookup(name string) (*types.Signature, bool) {
+	if f, ok := r.Func(name); ok {
+		return &f.Sig, true
+	}
+	return nil, false
+}
+
+// Names returns the sorted names of all registered functions.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.funcs))
+	for name := range r.funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,86 @@
+package eval
+
+import "github.com/ajz01/calc/ref"
+
+// Spiller is implemented by contexts that spill array results into the
+// cells next to their formula. SpillRange returns the range covered by
+// the result of the formula in cell c, or false if c holds no spilled
+// array.
+type Spiller interface {
+	SpillRange(sheet string, c ref.Cell) (ref.Range, bool)
+}
+
+// Spill lays out the result v of the formula in cell c. An array spills
+// into the range below and to the right of c, which must fit on the
+// sheet and hold no other values: occupied reports whether a cell other
+// than c has a value or formula of its own. Spill returns the range the
+// result covers and the value of c, which is #SPILL! if the array is
+// blocked. Other values cover c alone.
+func Spill(c ref.Cell, v Value, occupied func(ref.Cell) bool) (ref.Range, Value) {
+	a, ok := v.(*Array)
+	if !ok {
+		return ref.Range{From: c, To: c}, v
+	}
+	r := ref.Range{From: c, To: ref.Cell{Row: c.Row + a.Rows - 1, Col: c.Col + a.Cols - 1}}
+	if !r.To.IsValid() {
+		return ref.Range{From: c, To: c}, ErrSpill
+	}
+	for row := r.From.Row; row <= r.To.Row; row++ {
+		for col := r.From.Col; col <= r.To.Col; col++ {
+			if x := (ref.Cell{Row: row, Col: col}); x != c && occupied(x) {
+				return ref.Range{From: c, To: c}, ErrSpill
+			}
+		}
+	}
+	return r, a.At(0, 0)
+}
+
+// spillRef resolves the spill reference x# to the range spilled by the
+// formula in the cell x refers to.
+func (e *Evaluator) spillRef(x Value) Value {
+	r, ok := Force(x).(*Ref)
+	if !ok {
+		if err, ok := Force(x).(Error); ok {
+			return err
+		}
+		return ErrRef
+	}
+	s, ok := e.Context.(Spiller)
+	if !ok || r.Range.From != r.Range.To {
+		return ErrRef
+	}
+	sheet := r.Sheet
+	if sheet == "" {
+		sheet = e.Sheet
+	}
+	rng, ok := s.SpillRange(sheet, r.Range.From)
+	if !ok {
+		return ErrRef
+	}
+	return &Ref{Sheet: r.Sheet, Range: rng}
+}
+
+// intersect returns the single value of x for the implicit intersection
+// @x: the cell of a range in the row or column of the formula, or the
+// first element of an array. It is #VALUE! if the formula lies outside
+// the range.
+func (e *Evaluator) intersect(x Value) Value {
+	x = Force(x)
+	switch v := x.(type) {
+	case *Array:
+		return v.At(0, 0)
+	case *Ref:
+		c, rng := e.Cell, v.Range
+		if rng.Rows() == 1 {
+			c.Row = rng.From.Row
+		}
+		if rng.Cols() == 1 {
+			c.Col = rng.From.Col
+		}
+		if !rng.Contains(c) {
+			return ErrValue
+		}
+		return e.cell(v.Sheet, c)
+	}
+	return x
+}
@@ -0,0 +1,110 @@
+package eval
+
+import (
+	"github.com/ajz01/calc/ref"
+	"strconv"
+)
+
+// Value is the result of evaluating a formula expression.
+type Value interface {
+	String() string
+	value()
+}
+
+type (
+	// Number is a numeric value. Spreadsheets keep all numbers,
+	// including dates and times, as float64.
+	Number float64
+
+	// Complex is a complex number such as 3+4i.
+	Complex complex128
+
+	// Text is a string value.
+	Text string
+
+	// Bool is a logical value.
+	Bool bool
+
+	// Error is a spreadsheet error value such as #DIV/0!.
+	Error string
+
+	// Blank is the value of an empty cell.
+	Blank struct{}
+
+	// Ref is an unresolved reference to a cell or range of cells.
+	Ref struct {
+		Sheet string
+		Range ref.Range
+	}
+
+	// Array is a rectangular array of scalar values, such as an array
+	// constant or the result of an operator applied to a range. Values
+	// holds the Rows*Cols elements row by row.
+	Array struct {
+		Rows, Cols int
+		Values     []Value
+	}
+)
+
+// Error values.
+const (
+	ErrNull  Error = "#NULL!"
+	ErrDiv0  Error = "#DIV/0!"
+	ErrValue Error = "#VALUE!"
+	ErrRef   Error = "#REF!"
+	ErrName  Error = "#NAME?"
+	ErrNum   Error = "#NUM!"
+	ErrNA    Error = "#N/A"
+
+	// ErrCalc reports a calculation that has no result, such as a FILTER
+	// that matches nothing.
+	ErrCalc Error = "#CALC!"
+	// ErrSpill reports an array result that cannot be spilled into the
+	// cells next to its formula.
+	ErrSpill Error = "#SPILL!"
+)
+
+func (x Number) String() string { return strconv.FormatFloat(float64(x), 'G', 15, 64) }
+func (x Text) String() string   { return string(x) }
+func (x Error) String() string  { return string(x) }
+func (x Blank) String() string  { return "" }
+
+func (x Bool) String() string {
+	if x {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+func (x *Ref) String() string {
+	if x.Sheet != "" {
+		return x.Sheet + "!" + x.Range.String()
+	}
+	return x.Range.String()
+}
+
+func (x Error) Error() string { return string(x) }
+
+func (Number) value()  {}
+func (Complex) value() {}
+func (Text) value()    {}
+func (Bool) value()    {}
+func (Error) value()   {}
+func (Blank) value()   {}
+func (*Ref) value()    {}
+func (*Array) value()  {}
+
+// ErrorValue returns err as a Value. Errors not produced by this package
+// become #VALUE!.
+func ErrorValue(err error) Value {
+	if e, ok := err.(Error); ok {
+		return e
+	}
+	return ErrValue
+}
+
+// IsError reports whether v is an error value.
+func IsError(v Value) bool {
+	_, ok := v.(Error)
+	return ok
+}
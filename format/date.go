@@ -0,0 +1,177 @@
+package format
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	epoch1900 = time.Date(1899, time.December, 31, 0, 0, 0, 0, time.UTC)
+	epoch1904 = time.Date(1904, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// Date returns the calendar date of serial day number days. In the 1900
+// date system day 1 is 1 January 1900 and day 60 is the nonexistent
+// 29 February 1900, kept for compatibility with Lotus 1-2-3. In the 1904
+// date system day 0 is 1 January 1904.
+func Date(days int, date1904 bool) (year int, month time.Month, day int) {
+	if date1904 {
+		return epoch1904.AddDate(0, 0, days).Date()
+	}
+	switch {
+	case days == 0:
+		return 1900, time.January, 0
+	case days == 60:
+		return 1900, time.February, 29
+	case days > 60:
+		days--
+	}
+	return epoch1900.AddDate(0, 0, days).Date()
+}
+
+// Serial returns the serial day number of a date. Months out of range
+// are normalized as by time.Date and days are counted from the first of
+// the month, so Serial(1900, 3, 0, false) is 60, the Lotus leap day.
+func Serial(year int, month time.Month, day int, date1904 bool) int {
+	// Unix seconds rather than time.Duration, which overflows after 292 years
+	t := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).Unix()
+	if date1904 {
+		return int((t-epoch1904.Unix())/86400) + day - 1
+	}
+	days := int((t - epoch1900.Unix()) / 86400)
+	if days >= 60 {
+		days++
+	}
+	return days + day - 1
+}
+
+// Weekday returns the day of the week of serial day number days. Days
+// before 1 March 1900 follow the 1900 date system, which treats 1900 as
+// a leap year.
+func Weekday(days int, date1904 bool) time.Weekday {
+	if date1904 {
+		days += 1462
+	}
+	return time.Weekday(((days+6)%7 + 7) % 7)
+}
+
+// Time converts a serial date and time to a time.Time in UTC.
+func Time(serial float64, date1904 bool) time.Time {
+	days := math.Floor(serial)
+	y, m, d := Date(int(days), date1904)
+	ms := math.Round((serial - days) * 86400e3)
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Add(time.Duration(ms) * time.Millisecond)
+}
+
+// FromTime converts t to a serial date and time, ignoring its location.
+func FromTime(t time.Time, date1904 bool) float64 {
+	y, m, d := t.Date()
+	secs := float64(t.Hour()*3600+t.Minute()*60+t.Second()) + float64(t.Nanosecond())/1e9
+	return float64(Serial(y, m, d, date1904)) + secs/86400
+}
+
+func (sec *section) formatDate(x float64, date1904 bool) (string, error) {
+	if x < 0 || x >= 2958466 {
+		return "", ErrRange
+	}
+	prec := 0
+	for _, it := range sec.items {
+		if it.kind == subsecond && it.n > prec {
+			prec = it.n
+		}
+	}
+	// round to the precision shown, at least whole seconds
+	p := math.Pow10(prec)
+	days := math.Floor(x)
+	secs := math.Round((x-days)*86400*p) / p
+	if secs >= 86400 {
+		days++
+		secs -= 86400
+	}
+	y, m, d := Date(int(days), date1904)
+	whole := int(secs)
+	h, mi, s := whole/3600, whole/60%60, whole%60
+
+	var b strings.Builder
+	for _, it := range sec.items {
+		switch it.kind {
+		case literal:
+			b.WriteString(it.text)
+		case year:
+			if it.n <= 2 {
+				b.WriteString(pad2(y % 100))
+			} else {
+				b.WriteString(strconv.Itoa(y))
+			}
+		case month:
+			switch it.n {
+			case 1:
+				b.WriteString(strconv.Itoa(int(m)))
+			case 2:
+				b.WriteString(pad2(int(m)))
+			case 3:
+				b.WriteString(m.String()[:3])
+			case 5:
+				b.WriteString(m.String()[:1])
+			default:
+				b.WriteString(m.String())
+			}
+		case day:
+			switch it.n {
+			case 1:
+				b.WriteString(strconv.Itoa(d))
+			case 2:
+				b.WriteString(pad2(d))
+			case 3:
+				b.WriteString(Weekday(int(days), date1904).String()[:3])
+			default:
+				b.WriteString(Weekday(int(days), date1904).String())
+			}
+		case hour:
+			hh := h
+			if sec.hour12 {
+				hh = (h+11)%12 + 1
+			}
+			b.WriteString(width(hh, it.n))
+		case minute:
+			b.WriteString(width(mi, it.n))
+		case second:
+			b.WriteString(width(s, it.n))
+		case subsecond:
+			f := strconv.FormatFloat(secs-float64(whole), 'f', it.n, 64)
+			b.WriteString(f[1:])
+		case elapsedHour:
+			b.WriteString(width(int(days)*24+h, it.n))
+		case elapsedMin:
+			b.WriteString(width((int(days)*24+h)*60+mi, it.n))
+		case elapsedSec:
+			b.WriteString(width(int(days)*86400+whole, it.n))
+		case ampm:
+			am, pm := "AM", "PM"
+			if len(it.text) == 3 {
+				am, pm = it.text[:1], it.text[2:]
+			} else if it.text[0] == 'a' {
+				am, pm = "am", "pm"
+			}
+			if h < 12 {
+				b.WriteString(am)
+			} else {
+				b.WriteString(pm)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+func pad2(n int) string { return width(n, 2) }
+
+// width formats n with at least w digits.
+func width(n, w int) string {
+	s := strconv.Itoa(n)
+	for len(s) < w {
+		s = "0" + s
+	}
+	return s
+}
@@ -0,0 +1,517 @@
+// Package format implements spreadsheet number format codes such as
+// "#,##0.00", "0%", "# ?/?", "$#,##0;[Red]($#,##0)", "yyyy-mm-dd hh:mm"
+// and "@".
+//
+// A format code has up to four sections separated by semicolons, used
+// for positive numbers, negative numbers, zero and text. Sections may
+// start with a color such as [Red] and a condition such as [>=100].
+package format
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrSyntax = errors.New("format: invalid format code")
+	ErrRange  = errors.New("format: date out of range")
+)
+
+type kind int
+
+const (
+	literal     kind = iota
+	digit            // 0 # ?
+	point            // .
+	comma            // ,
+	exponent         // E+ E-
+	textValue        // @
+	general          // General
+	year             // yy yyyy
+	month            // m mm mmm mmmm mmmmm
+	day              // d dd ddd dddd
+	hour             // h hh
+	minute           // m mm after h or before s
+	second           // s ss
+	subsecond        // .0 .00 .000 after s
+	ampm             // AM/PM A/P
+	elapsedHour      // [h]
+	elapsedMin       // [m]
+	elapsedSec       // [s]
+	numerator        // 0 # ? before the / of a fraction
+	denominator      // 0 # ? or a fixed number after the / of a fraction
+)
+
+type item struct {
+	kind kind
+	text string // literal text, placeholder or AM/PM code
+	n    int    // length of the code, e.g. 4 for yyyy
+}
+
+type condition struct {
+	op    string
+	value float64
+}
+
+func (c *condition) match(x float64) bool {
+	switch c.op {
+	case "<":
+		return x < c.value
+	case "<=":
+		return x <= c.value
+	case ">":
+		return x > c.value
+	case ">=":
+		return x >= c.value
+	case "<>":
+		return x != c.value
+	}
+	return x == c.value
+}
+
+type section struct {
+	items []item
+	color string
+	cond  *condition
+
+	date    bool // has date or time codes
+	text    bool // has @
+	general bool // is General
+
+	// number layout
+	percent    int  // number of % signs
+	scale      int  // number of thousands scaling commas
+	grouping   bool // thousands separators
+	fracDigits int  // digit placeholders after the point
+	exp        bool // scientific notation
+	hour12     bool // has AM/PM
+	fraction   bool // has a fraction such as # ?/?
+	slash      int  // index of the / of a fraction
+	denom      int  // fixed denominator, or 0 for placeholders
+}
+
+// Format is a parsed format code.
+type Format struct {
+	Code     string
+	sections []*section
+}
+
+// General is the format of cells without an explicit format.
+var General = &Format{Code: "General", sections: []*section{{general: true, items: []item{{kind: general}}}}}
+
+// Parse parses a format code.
+func Parse(code string) (*Format, error) {
+	if strings.EqualFold(code, "General") || code == "" {
+		return General, nil
+	}
+	f := &Format{Code: code}
+	for _, s := range split(code) {
+		sec, err := parseSection(s)
+		if err != nil {
+			return nil, err
+		}
+		f.sections = append(f.sections, sec)
+	}
+	if len(f.sections) > 4 {
+		return nil, ErrSyntax
+	}
+	return f, nil
+}
+
+// split splits code into sections at semicolons that are not quoted,
+// escaped or bracketed.
+func split(code string) []string {
+	var secs []string
+	quoted, bracket := false, false
+	start := 0
+	for i := 0; i < len(code); i++ {
+		switch ch := code[i]; {
+		case quoted:
+			quoted = ch != '"'
+		case bracket:
+			bracket = ch != ']'
+		case ch == '"':
+			quoted = true
+		case ch == '[':
+			bracket = true
+		case ch == '\\' || ch == '_' || ch == '*':
+			i++
+		case ch == ';':
+			secs = append(secs, code[start:i])
+			start = i + 1
+		}
+	}
+	return append(secs, code[start:])
+}
+
+func hasPrefixFold(rs []rune, prefix string) bool {
+	return len(rs) >= len(prefix) && strings.EqualFold(string(rs[:len(prefix)]), prefix)
+}
+
+var dateKinds = map[rune]kind{'y': year, 'm': month, 'd': day, 'h': hour, 's': second}
+
+func parseSection(s string) (*section, error) {
+	sec := &section{}
+	lit := func(text string) {
+		if n := len(sec.items); n > 0 && sec.items[n-1].kind == literal {
+			sec.items[n-1].text += text
+			return
+		}
+		sec.items = append(sec.items, item{kind: literal, text: text})
+	}
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		ch := rs[i]
+		switch {
+		case ch == '"':
+			j := i + 1
+			for j < len(rs) && rs[j] != '"' {
+				j++
+			}
+			if j == len(rs) {
+				return nil, ErrSyntax
+			}
+			lit(string(rs[i+1 : j]))
+			i = j + 1
+		case ch == '\\' || ch == '_' || ch == '*':
+			if i+1 < len(rs) {
+				switch ch {
+				case '\\':
+					lit(string(rs[i+1]))
+				case '_':
+					lit(" ")
+				}
+			}
+			i += 2
+		case ch == '[':
+			j := i + 1
+			for j < len(rs) && rs[j] != ']' {
+				j++
+			}
+			if j == len(rs) {
+				return nil, ErrSyntax
+			}
+			if err := sec.bracket(string(rs[i+1:j]), lit); err != nil {
+				return nil, err
+			}
+			i = j + 1
+		case ch == '0' || ch == '#' || ch == '?':
+			sec.items = append(sec.items, item{kind: digit, text: string(ch)})
+			i++
+		case ch == '.':
+			sec.items = append(sec.items, item{kind: point})
+			i++
+		case ch == ',':
+			sec.items = append(sec.items, item{kind: comma})
+			i++
+		case ch == '%':
+			sec.percent++
+			lit("%")
+			i++
+		case (ch == 'E' || ch == 'e') && i+1 < len(rs) && (rs[i+1] == '+' || rs[i+1] == '-'):
+			sec.items = append(sec.items, item{kind: exponent, text: "E" + string(rs[i+1])})
+			sec.exp = true
+			i += 2
+		case ch == '@':
+			sec.items = append(sec.items, item{kind: textValue})
+			sec.text = true
+			i++
+		case hasPrefixFold(rs[i:], "General"):
+			sec.items = append(sec.items, item{kind: general})
+			sec.general = true
+			i += len("General")
+		case hasPrefixFold(rs[i:], "AM/PM"):
+			sec.items = append(sec.items, item{kind: ampm, text: string(rs[i : i+5])})
+			sec.hour12, sec.date = true, true
+			i += 5
+		case hasPrefixFold(rs[i:], "A/P"):
+			sec.items = append(sec.items, item{kind: ampm, text: string(rs[i : i+3])})
+			sec.hour12, sec.date = true, true
+			i += 3
+		case dateKinds[unicode.ToLower(ch)] != 0:
+			j := i + 1
+			for j < len(rs) && unicode.ToLower(rs[j]) == unicode.ToLower(ch) {
+				j++
+			}
+			sec.items = append(sec.items, item{kind: dateKinds[unicode.ToLower(ch)], n: j - i})
+			sec.date = true
+			i = j
+		default:
+			lit(string(ch))
+			i++
+		}
+	}
+	if sec.date {
+		sec.resolveDate()
+	} else {
+		sec.resolveNumber()
+	}
+	return sec, nil
+}
+
+var colors = []string{"black", "blue", "cyan", "green", "magenta", "red", "white", "yellow"}
+
+// bracket interprets the contents of a [...] code.
+func (sec *section) bracket(s string, lit func(string)) error {
+	ls := strings.ToLower(s)
+	switch {
+	case ls == "":
+		return ErrSyntax
+	case strings.Trim(ls, "h") == "":
+		sec.items = append(sec.items, item{kind: elapsedHour, n: len(ls)})
+		sec.date = true
+	case strings.Trim(ls, "m") == "":
+		sec.items = append(sec.items, item{kind: elapsedMin, n: len(ls)})
+		sec.date = true
+	case strings.Trim(ls, "s") == "":
+		sec.items = append(sec.items, item{kind: elapsedSec, n: len(ls)})
+		sec.date = true
+	case strings.IndexByte("<>=", ls[0]) >= 0:
+		op := ls[:1]
+		if len(ls) > 1 && strings.IndexByte("<>=", ls[1]) >= 0 {
+			op = ls[:2]
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(ls[len(op):]), 64)
+		if err != nil {
+			return ErrSyntax
+		}
+		sec.cond = &condition{op: op, value: v}
+	case ls[0] == '$':
+		// currency and locale, e.g. [$€-407]
+		if i := strings.IndexByte(s, '-'); i >= 0 {
+			s = s[:i]
+		}
+		lit(s[1:])
+	case strings.HasPrefix(ls, "color"):
+		sec.color = s
+	default:
+		for _, c := range colors {
+			if ls == c {
+				sec.color = s
+			}
+		}
+	}
+	return nil
+}
+
+// resolveNumber classifies commas as thousands separators or scaling
+// and counts the decimal placeholders.
+func (sec *section) resolveNumber() {
+	if sec.resolveFraction() {
+		return
+	}
+	// first and last integer placeholders, last placeholder of the
+	// mantissa and end of the integer part
+	first, last, lastDigit, pt := -1, -1, -1, -1
+	exp := false
+	for i, it := range sec.items {
+		switch it.kind {
+		case digit:
+			if pt < 0 {
+				if first < 0 {
+					first = i
+				}
+				last = i
+			}
+			if !exp {
+				lastDigit = i
+			}
+		case point, exponent:
+			if pt < 0 {
+				pt = i
+			}
+			exp = exp || it.kind == exponent
+		}
+	}
+	items := sec.items[:0]
+	inFrac := false
+	for i, it := range sec.items {
+		switch it.kind {
+		case comma:
+			switch {
+			case first < i && i < last:
+				sec.grouping = true
+			case lastDigit >= 0 && i > lastDigit && onlyCommas(sec.items[lastDigit+1:i]):
+				sec.scale++
+			default:
+				items = append(items, item{kind: literal, text: ","})
+			}
+			continue
+		case point:
+			if inFrac || i > pt && pt >= 0 {
+				it = item{kind: literal, text: "."}
+			} else {
+				inFrac = true
+			}
+		case exponent:
+			inFrac = false
+		case digit:
+			if inFrac {
+				sec.fracDigits++
+			}
+		}
+		items = append(items, it)
+	}
+	sec.items = items
+}
+
+// resolveFraction finds a fraction: numerator placeholders, a / and
+// denominator placeholders or a fixed denominator such as the 8 of
+// "# ?/8". Placeholders before the numerator, separated from it by a
+// literal, show the whole part; without them the fraction is improper.
+func (sec *section) resolveFraction() bool {
+	for k, it := range sec.items {
+		if it.kind != literal || !strings.HasPrefix(it.text, "/") || k == 0 || sec.items[k-1].kind != digit {
+			continue
+		}
+		items := append([]item(nil), sec.items[:k]...)
+		for j := k - 1; j >= 0 && items[j].kind == digit; j-- {
+			items[j].kind = numerator
+		}
+		slash := len(items)
+		items = append(items, item{kind: literal, text: "/"})
+		rest, next := it.text[1:], sec.items[k+1:]
+		denom := 0
+		switch {
+		case rest == "":
+			for len(next) > 0 && next[0].kind == digit {
+				items = append(items, item{kind: denominator, text: next[0].text})
+				next = next[1:]
+			}
+			if len(items) == slash+1 {
+				continue
+			}
+		case rest[0] >= '1' && rest[0] <= '9':
+			n := 0
+			for n < len(rest) && rest[n] >= '0' && rest[n] <= '9' {
+				n++
+			}
+			fixed := rest[:n]
+			for n == len(rest) && len(next) > 0 && next[0].kind == digit && next[0].text == "0" {
+				fixed += "0"
+				next = next[1:]
+			}
+			denom, _ = strconv.Atoi(fixed)
+			items = append(items, item{kind: denominator, text: fixed})
+			if n < len(rest) {
+				items = append(items, item{kind: literal, text: rest[n:]})
+			}
+		default:
+			continue
+		}
+		for _, it := range next {
+			switch it.kind {
+			case digit, exponent:
+				it = item{kind: literal, text: it.text}
+			case point:
+				it = item{kind: literal, text: "."}
+			case comma:
+				it = item{kind: literal, text: ","}
+			}
+			items = append(items, it)
+		}
+		sec.items, sec.fraction, sec.slash, sec.denom = items, true, slash, denom
+		return true
+	}
+	return false
+}
+
+func onlyCommas(items []item) bool {
+	for _, it := range items {
+		if it.kind != comma {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveDate tells minutes from months and turns the digits after the
+// seconds into fractions of a second.
+func (sec *section) resolveDate() {
+	var items []item
+	prev := -1 // index of previous date code in items
+	for i := 0; i < len(sec.items); i++ {
+		it := sec.items[i]
+		switch it.kind {
+		case point:
+			j := i + 1
+			for j < len(sec.items) && sec.items[j].kind == digit && sec.items[j].text == "0" {
+				j++
+			}
+			if j > i+1 && prev >= 0 && (items[prev].kind == second || items[prev].kind == elapsedSec) {
+				items = append(items, item{kind: subsecond, n: j - i - 1})
+				i = j - 1
+				continue
+			}
+			it = item{kind: literal, text: "."}
+		case digit:
+			it = item{kind: literal, text: it.text}
+		case comma:
+			it = item{kind: literal, text: ","}
+		case month:
+			if it.n <= 2 && (prev >= 0 && (items[prev].kind == hour || items[prev].kind == elapsedHour) || nextIsSecond(sec.items[i+1:])) {
+				it.kind = minute
+			}
+		}
+		if it.kind != literal {
+			prev = len(items)
+		}
+		items = append(items, it)
+	}
+	sec.items = items
+}
+
+func nextIsSecond(items []item) bool {
+	for _, it := range items {
+		switch it.kind {
+		case literal, point, digit, comma:
+			continue
+		case second, elapsedSec:
+			return true
+		}
+		return false
+	}
+	return false
+}
+
+// IsDate reports whether the format displays numbers as dates or times.
+func (f *Format) IsDate() bool {
+	return len(f.sections) > 0 && f.sections[0].date
+}
+
+// Color returns the color of the section used to display x, if any.
+func (f *Format) Color(x float64) string {
+	sec, _ := f.pick(x)
+	return sec.color
+}
+
+// pick returns the section used to display x and whether a minus sign
+// must be shown for negative values.
+func (f *Format) pick(x float64) (*section, bool) {
+	secs := f.sections
+	if len(secs) == 4 || len(secs) > 1 && secs[len(secs)-1].text && !secs[len(secs)-1].date {
+		secs = secs[:len(secs)-1]
+	}
+	if len(secs) == 0 {
+		return General.sections[0], true
+	}
+	if secs[0].cond != nil || len(secs) > 1 && secs[1].cond != nil {
+		for i, sec := range secs {
+			if sec.cond == nil || sec.cond.match(x) {
+				return sec, i == 0 || sec.cond != nil
+			}
+		}
+		return secs[len(secs)-1], true
+	}
+	switch {
+	case len(secs) == 1 || x > 0:
+		return secs[0], true
+	case x < 0:
+		return secs[1], false
+	case len(secs) > 2:
+		return secs[2], true
+	}
+	return secs[0], true
+}
@@ -0,0 +1,164 @@
+package format
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		code string
+		x    float64
+		want string
+	}{
+		{"General", 1.0 / 3, "0.333333333"},
+		{"General", 123456789012, "1.23457E+11"},
+		{"General", -0.5, "-0.5"},
+		{"General", 1e-10, "1E-10"},
+		{"0", 2.5, "3"},
+		{"0.00", 2.675, "2.68"},
+		{"0.00", -1.005, "-1.01"},
+		{"#,##0.00", 1234567.891, "1,234,567.89"},
+		{"#,##0", 0, "0"},
+		{"#,##0", -1234, "-1,234"},
+		{"#,##0,", 1234567, "1,235"},
+		{"0.0,,\"M\"", 1234567, "1.2M"},
+		{"#.##", 1, "1."},
+		{"#.##", 0.5, ".5"},
+		{"0.0#", 1.5, "1.5"},
+		{"???.??", 1.5, "  1.5 "},
+		{"00000", 123, "00123"},
+		{"000-00-0000", 123456789, "123-45-6789"},
+		{"0%", 0.256, "26%"},
+		{"0.0%", 0.0125, "1.3%"},
+		{"0.00E+00", 12345, "1.23E+04"},
+		{"0.00E+00", 0.00012, "1.20E-04"},
+		{"0.00E+00", 9.999, "1.00E+01"},
+		{"##0.0E+0", 12345, "12.3E+3"},
+		{"$#,##0;[Red]($#,##0)", 1234, "$1,234"},
+		{"$#,##0;[Red]($#,##0)", -1234, "($1,234)"},
+		{"0;-0;\"zero\"", 0, "zero"},
+		{"[>=100]\"big\";[<0]\"neg\";0", 150, "big"},
+		{"[>=100]\"big\";[<0]\"neg\";0", -5, "neg"},
+		{"[>=100]\"big\";[<0]\"neg\";0", 5, "5"},
+		{"\\$0_)", 5, "$5 "},
+		{"[$€-407] #,##0.00", 1234.5, "€ 1,234.50"},
+		{"0 \"units\"", 3, "3 units"},
+		{"# ?/?", 3.14159, "3 1/7"},
+		{"?/?", 3.14159, "22/7"},
+		{"# ??/??", 3.14159, "3 14/99"},
+		{"# ??/??", 0.25, "  1/4 "},
+		{"# ?/?", 0.5, " 1/2"},
+		{"0 ?/?", 0.5, "0 1/2"},
+		{"# ?/?", -1.5, "-1 1/2"},
+		{"# ?/?", 2, "2    "},
+		{"# ?/?", 0.99, "1    "},
+		{"# ?/8", 1.3, "1 2/8"},
+		{"# ?/100", 0.123, " 12/100"},
+		{"?/?", 0, "0/1"},
+		{"# #/#", 1.75, "1 3/4"},
+	}
+	for _, test := range tests {
+		f, err := Parse(test.code)
+		if err != nil {
+			t.Errorf("Parse(%q) %v", test.code, err)
+			continue
+		}
+		if got, err := f.Number(test.x, false); err != nil || got != test.want {
+			t.Errorf("Parse(%q).Number(%v) = %q %v want %q", test.code, test.x, got, err, test.want)
+		}
+	}
+}
+
+func TestDate(t *testing.T) {
+	serial := float64(Serial(2021, time.March, 7, false)) + (13*3600+5*60+9.5)/86400
+	tests := []struct {
+		code string
+		x    float64
+		want string
+	}{
+		{"yyyy-mm-dd hh:mm", serial, "2021-03-07 13:05"},
+		{"yyyy-mm-dd hh:mm:ss.00", serial, "2021-03-07 13:05:09.50"},
+		{"m/d/yy h:mm AM/PM", serial, "3/7/21 1:05 PM"},
+		{"dddd, mmmm d, yyyy", serial, "Sunday, March 7, 2021"},
+		{"ddd mmm", serial, "Sun Mar"},
+		{"mmmmm", serial, "M"},
+		{"h:mm:ss a/p", 0.25, "6:00:00 a"},
+		{"mm:ss", 0.5 / 1440, "00:30"},
+		{"[h]:mm", 1.5, "36:00"},
+		{"[mm]:ss", 0.1, "144:00"},
+		{"hh:mm", 0.999999, "00:00"},
+		{"yyyy-mm-dd", 60, "1900-02-29"},
+		{"yyyy-mm-dd", 61, "1900-03-01"},
+		{"dddd", 1, "Sunday"},
+		{"d-mmm-yy", 0, "0-Jan-00"},
+	}
+	for _, test := range tests {
+		f, err := Parse(test.code)
+		if err != nil {
+			t.Errorf("Parse(%q) %v", test.code, err)
+			continue
+		}
+		if got, err := f.Number(test.x, false); err != nil || got != test.want {
+			t.Errorf("Parse(%q).Number(%v) = %q %v want %q", test.code, test.x, got, err, test.want)
+		}
+	}
+	f, _ := Parse("yyyy-mm-dd")
+	if got, _ := f.Number(0, true); got != "1904-01-01" {
+		t.Errorf("1904 Number(0) = %q want 1904-01-01", got)
+	}
+	if _, err := f.Number(-1, false); err != ErrRange {
+		t.Errorf("Number(-1) = %v want ErrRange", err)
+	}
+}
+
+func TestSerial(t *testing.T) {
+	for _, days := range []int{0, 1, 59, 60, 61, 43166, 2958465} {
+		y, m, d := Date(days, false)
+		if got := Serial(y, m, d, false); got != days {
+			t.Errorf("Serial(Date(%d)) = %d", days, got)
+		}
+		y, m, d = Date(days, true)
+		if got := Serial(y, m, d, true); got != days {
+			t.Errorf("1904 Serial(Date(%d)) = %d", days, got)
+		}
+	}
+	if got := Serial(2021, time.March, 7, false); got != 44262 {
+		t.Errorf("Serial(2021-03-07) = %d want 44262", got)
+	}
+	if got := Serial(1900, time.March, 0, false); got != 60 {
+		t.Errorf("Serial(1900-03-00) = %d want 60", got)
+	}
+	if got := Serial(2020, 14, 31, false); got != 44258 {
+		t.Errorf("Serial(2020-14-31) = %d want 44258", got)
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		code, s, want string
+	}{
+		{"@", "abc", "abc"},
+		{"\"Name: \"@", "Bob", "Name: Bob"},
+		{"0;-0;0;[Blue]\"<\"@\">\"", "x", "<x>"},
+		{"0.00", "abc", "abc"},
+	}
+	for _, test := range tests {
+		f, err := Parse(test.code)
+		if err != nil {
+			t.Errorf("Parse(%q) %v", test.code, err)
+			continue
+		}
+		if got := f.Text(test.s); got != test.want {
+			t.Errorf("Parse(%q).Text(%q) = %q want %q", test.code, test.s, got, test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, code := range []string{`0"abc`, "[Red", "0;0;0;@;0"} {
+		if _, err := Parse(code); err == nil {
+			t.Errorf("Parse(%q) succeeded want error", code)
+		}
+	}
+}
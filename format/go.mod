@@ -0,0 +1,3 @@
+module github.com/ajz01/calc/format
+
+go 1.13
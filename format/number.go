@@ -0,0 +1,385 @@
+package format
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Number formats x. Dates and times use the 1900 date system unless
+// date1904 is set. ErrRange is returned for dates that cannot be shown.
+func (f *Format) Number(x float64, date1904 bool) (string, error) {
+	sec, sign := f.pick(x)
+	if sec.date {
+		return sec.formatDate(x, date1904)
+	}
+	return sec.formatNumber(x, sign), nil
+}
+
+// Text formats text s using the text section of the format, if any.
+func (f *Format) Text(s string) string {
+	n := len(f.sections)
+	if n == 0 || n < 4 && !f.sections[n-1].text {
+		return s
+	}
+	var b strings.Builder
+	for _, it := range f.sections[n-1].items {
+		switch it.kind {
+		case literal:
+			b.WriteString(it.text)
+		case textValue:
+			b.WriteString(s)
+		}
+	}
+	return b.String()
+}
+
+// Value formats x using the General format.
+func Value(x float64) string {
+	s, _ := General.Number(x, false)
+	return s
+}
+
+// decimal rounds v >= 0 half away from zero to frac decimal places and
+// returns the integer digits, without leading zeros, and the frac
+// fraction digits. Rounding uses the 15 significant digits spreadsheets
+// display, so 2.675 rounds to 2.68.
+func decimal(v float64, frac int) (string, string) {
+	s := strconv.FormatFloat(v, 'e', 14, 64)
+	i := strings.IndexByte(s, 'e')
+	exp, _ := strconv.Atoi(s[i+1:])
+	digits := []byte(s[:1] + s[2:i])
+	if v == 0 {
+		exp = 0
+	}
+	pt := exp + 1 // digits before the decimal point
+	keep := pt + frac
+	switch {
+	case keep < 0:
+		digits, pt = nil, -frac
+	case keep < len(digits):
+		up := digits[keep] >= '5'
+		digits = digits[:keep]
+		if up {
+			j := len(digits) - 1
+			for ; j >= 0 && digits[j] == '9'; j-- {
+				digits[j] = '0'
+			}
+			if j >= 0 {
+				digits[j]++
+			} else {
+				digits = append([]byte{'1'}, digits...)
+				pt++
+			}
+		}
+	default:
+		for len(digits) < keep {
+			digits = append(digits, '0')
+		}
+	}
+	if pt <= 0 {
+		return "", strings.Repeat("0", -pt) + string(digits)
+	}
+	return strings.TrimLeft(string(digits[:pt]), "0"), string(digits[pt:])
+}
+
+// formatGeneral formats x the way the General format does: as many digits as
+// fit in 11 characters, switching to scientific notation for very large
+// and very small numbers.
+func formatGeneral(x float64) string {
+	v := math.Abs(x)
+	sign := ""
+	if x < 0 {
+		sign = "-"
+	}
+	if v == 0 {
+		return "0"
+	}
+	if v < 1e11 && v >= 1e-9 {
+		n := len(strconv.FormatFloat(math.Floor(v), 'f', 0, 64))
+		frac := 10 - n
+		if frac < 0 {
+			frac = 0
+		}
+		ip, fp := decimal(v, frac)
+		if len(ip) <= 11 {
+			fp = strings.TrimRight(fp, "0")
+			if ip == "" {
+				ip = "0"
+			}
+			if fp == "" {
+				return sign + ip
+			}
+			return sign + ip + "." + fp
+		}
+	}
+	s := strconv.FormatFloat(v, 'E', 5, 64)
+	i := strings.IndexByte(s, 'E')
+	m := strings.TrimRight(strings.TrimRight(s[:i], "0"), ".")
+	return sign + m + s[i:]
+}
+
+func (sec *section) formatNumber(x float64, sign bool) string {
+	v := math.Abs(x)
+	for i := 0; i < sec.percent; i++ {
+		v *= 100
+	}
+	for i := 0; i < sec.scale; i++ {
+		v /= 1000
+	}
+	if sec.fraction {
+		return sec.formatFraction(x, v, sign)
+	}
+
+	// placeholders of the integer, fraction and exponent parts
+	var ints, fracs, exps []int
+	part := &ints
+	for i, it := range sec.items {
+		switch it.kind {
+		case digit:
+			*part = append(*part, i)
+		case point:
+			part = &fracs
+		case exponent:
+			part = &exps
+		}
+	}
+
+	exp := 0
+	if sec.exp && v != 0 {
+		exp = int(math.Floor(math.Log10(v)))
+		n := len(ints)
+		if n > 1 && sec.items[ints[0]].text == "#" {
+			// engineering notation: exponent is a multiple of n
+			exp = int(math.Floor(float64(exp)/float64(n))) * n
+		} else if n > 1 {
+			exp -= n - 1
+		}
+		v /= math.Pow10(exp)
+		if ip, _ := decimal(v, sec.fracDigits); len(ip) > len(ints) && len(ints) > 0 && sec.items[ints[0]].text != "#" {
+			v /= 10
+			exp++
+		}
+	}
+	ip, fp := decimal(v, sec.fracDigits)
+
+	// text placed at each placeholder
+	out := make(map[int]string)
+	if sec.grouping {
+		min := 0
+		for i, j := range ints {
+			if sec.items[j].text == "0" {
+				min = len(ints) - i
+				break
+			}
+		}
+		for len(ip) < min {
+			ip = "0" + ip
+		}
+		for i := len(ip) - 3; i > 0; i -= 3 {
+			ip = ip[:i] + "," + ip[i:]
+		}
+		for _, j := range ints {
+			out[j] = ""
+		}
+		if len(ints) > 0 {
+			out[ints[0]] = ip
+		}
+	} else {
+		sec.fill(out, ints, ip)
+	}
+	for k, j := range fracs {
+		out[j] = fp[k : k+1]
+	}
+	for k := len(fracs) - 1; k >= 0 && fp[k] == '0'; k-- {
+		p := sec.items[fracs[k]].text
+		if p == "0" {
+			break
+		}
+		out[fracs[k]] = pad(p)
+	}
+	if len(exps) > 0 {
+		es := strconv.Itoa(abs(exp))
+		for len(es) < len(exps) {
+			es = "0" + es
+		}
+		for _, j := range exps {
+			out[j] = ""
+		}
+		out[exps[0]] = es
+	}
+
+	var b strings.Builder
+	if x < 0 && sign && (len(ints)+len(fracs) > 0 || sec.general) {
+		b.WriteByte('-')
+	}
+	for i, it := range sec.items {
+		switch it.kind {
+		case literal:
+			b.WriteString(it.text)
+		case digit:
+			b.WriteString(out[i])
+		case point:
+			b.WriteByte('.')
+		case exponent:
+			b.WriteByte('E')
+			if exp < 0 {
+				b.WriteByte('-')
+			} else if it.text == "E+" {
+				b.WriteByte('+')
+			}
+		case general:
+			b.WriteString(formatGeneral(v))
+		}
+	}
+	return b.String()
+}
+
+// fill places the digits of the whole number ip at the placeholders ps,
+// right-aligned, the first placeholder taking any digits left over.
+func (sec *section) fill(out map[int]string, ps []int, ip string) {
+	for k := len(ps) - 1; k >= 0; k-- {
+		j := ps[k]
+		switch {
+		case k == 0:
+			out[j] = ip
+			if ip == "" {
+				out[j] = pad(sec.items[j].text)
+			}
+		case ip != "":
+			out[j] = ip[len(ip)-1:]
+			ip = ip[:len(ip)-1]
+		default:
+			out[j] = pad(sec.items[j].text)
+		}
+	}
+}
+
+// formatFraction formats v, the absolute value of x, as a fraction: a
+// whole part if the code has one, then a numerator and a denominator.
+// Without a fixed denominator the closest fraction whose denominator has
+// no more digits than there are placeholders is used. A whole number
+// shows no fraction, only blanks for ? placeholders.
+func (sec *section) formatFraction(x, v float64, sign bool) string {
+	var ints, nums, dens []int
+	for i, it := range sec.items {
+		switch it.kind {
+		case digit:
+			ints = append(ints, i)
+		case numerator:
+			nums = append(nums, i)
+		case denominator:
+			dens = append(dens, i)
+		}
+	}
+	whole, f := 0.0, v
+	if len(ints) > 0 {
+		whole = math.Floor(v)
+		f = v - whole
+	}
+	num, den := 0.0, float64(sec.denom)
+	if sec.denom > 0 {
+		num = math.Round(f * den)
+	} else {
+		num, den = closest(f, math.Pow10(len(dens))-1)
+	}
+	if len(ints) > 0 && num == den {
+		whole, num = whole+1, 0
+	}
+
+	out := make(map[int]string)
+	ip, _ := decimal(whole, 0)
+	if ip == "" && (num == 0 || len(nums) == 0) {
+		ip = "0"
+	}
+	sec.fill(out, ints, ip)
+	blank := len(ints) > 0 && num == 0
+	if blank {
+		for _, j := range append(nums, dens...) {
+			out[j] = pad(sec.items[j].text)
+			if out[j] != "" {
+				out[j] = " "
+			}
+		}
+	} else {
+		np, _ := decimal(num, 0)
+		if np == "" {
+			np = "0"
+		}
+		sec.fill(out, nums, np)
+		dp, _ := decimal(den, 0)
+		if sec.denom > 0 {
+			out[dens[0]] = dp
+		} else {
+			// left-aligned: the denominator has at most len(dens) digits
+			for k, j := range dens {
+				out[j] = pad(sec.items[j].text)
+				if k < len(dp) {
+					out[j] = dp[k : k+1]
+				}
+			}
+		}
+	}
+
+	var b strings.Builder
+	if x < 0 && sign && (whole != 0 || num != 0) {
+		b.WriteByte('-')
+	}
+	for i, it := range sec.items {
+		switch {
+		case i == sec.slash && blank:
+			b.WriteByte(' ')
+		case it.kind == literal:
+			b.WriteString(it.text)
+		case it.kind == digit || it.kind == numerator || it.kind == denominator:
+			b.WriteString(out[i])
+		case it.kind == point:
+			b.WriteByte('.')
+		}
+	}
+	return b.String()
+}
+
+// closest returns the fraction closest to f >= 0 with a denominator of
+// at most max, the one with the smaller denominator on ties. It walks
+// the convergents of the continued fraction of f and, once the next one
+// has too large a denominator, tries the largest semiconvergent.
+func closest(f, max float64) (num, den float64) {
+	h0, h1, k0, k1 := 0.0, 1.0, 1.0, 0.0
+	x := f
+	for i := 0; i < 64; i++ {
+		a := math.Floor(x)
+		h2, k2 := a*h1+h0, a*k1+k0
+		if k2 > max {
+			t := math.Floor((max - k0) / k1)
+			if h, k := t*h1+h0, t*k1+k0; math.Abs(f-h/k) < math.Abs(f-h1/k1) {
+				return h, k
+			}
+			break
+		}
+		h0, h1, k0, k1 = h1, h2, k1, k2
+		if x == a {
+			break
+		}
+		x = 1 / (x - a)
+	}
+	return h1, k1
+}
+
+// pad returns the text shown by an unused digit placeholder.
+func pad(placeholder string) string {
+	switch placeholder {
+	case "0":
+		return "0"
+	case "?":
+		return " "
+	}
+	return ""
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
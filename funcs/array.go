@@ -0,0 +1,401 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/types"
+	"math"
+	"sort"
+)
+
+// Array holds the dynamic array functions, whose results are arrays
+// sized by their data.
+var Array = []*eval.Func{
+	{Name: "FILTER", Sig: fixed(types.Array, val("array"), val("include"), opt(val("if_empty"))), Call: filter},
+	{Name: "SORT", Sig: fixed(types.Array, val("array"), opt(val("sort_index")), opt(val("sort_order")), opt(param("by_col", types.Logical))), Call: sortFunc},
+	{Name: "SORTBY", Sig: variadic(types.Array, val("array"), val("by_array")), Call: sortBy},
+	{Name: "UNIQUE", Sig: fixed(types.Array, val("array"), opt(param("by_col", types.Logical)), opt(param("exactly_once", types.Logical))), Call: unique},
+	{Name: "SEQUENCE", Sig: fixed(types.Array, num("rows"), opt(num("columns")), opt(num("start")), opt(num("step"))), Call: sequence},
+	{Name: "RANDARRAY", Sig: fixed(types.Array, opt(num("rows")), opt(num("columns")), opt(num("min")), opt(num("max")), opt(param("whole_number", types.Logical))), Call: randArray, Serial: true, Volatile: true},
+}
+
+// arrayArg returns v as an array. A single value is an array of one
+// element; an error is returned as such.
+func arrayArg(e *eval.Evaluator, v eval.Value) (*eval.Array, error) {
+	if a, ok := e.Array(v); ok {
+		return a, nil
+	}
+	v = e.Deref(v)
+	if err, ok := v.(eval.Error); ok {
+		return nil, err
+	}
+	a := eval.NewArray(1, 1)
+	a.Set(0, 0, v)
+	return a, nil
+}
+
+// optBool returns argument i as a logical value, or false if it was
+// omitted.
+func optBool(e *eval.Evaluator, args []eval.Value, i int) (bool, error) {
+	if i >= len(args) {
+		return false, nil
+	}
+	if _, ok := args[i].(eval.Blank); ok {
+		return false, nil
+	}
+	return e.Bool(args[i])
+}
+
+// lines describes the rows of an array, or its columns if byCol is set.
+type lines struct {
+	a     *eval.Array
+	byCol bool
+}
+
+func (l lines) len() int {
+	if l.byCol {
+		return l.a.Cols
+	}
+	return l.a.Rows
+}
+
+// width returns the number of elements of a line.
+func (l lines) width() int {
+	if l.byCol {
+		return l.a.Rows
+	}
+	return l.a.Cols
+}
+
+// at returns element k of line i.
+func (l lines) at(i, k int) eval.Value {
+	if l.byCol {
+		return l.a.At(k, i)
+	}
+	return l.a.At(i, k)
+}
+
+// pick returns a new array of the lines with the given indices.
+func (l lines) pick(idx []int) *eval.Array {
+	var r *eval.Array
+	if l.byCol {
+		r = eval.NewArray(l.a.Rows, len(idx))
+	} else {
+		r = eval.NewArray(len(idx), l.a.Cols)
+	}
+	for n, i := range idx {
+		for k := 0; k < l.width(); k++ {
+			if l.byCol {
+				r.Set(k, n, l.at(i, k))
+			} else {
+				r.Set(n, k, l.at(i, k))
+			}
+		}
+	}
+	return r
+}
+
+// vectorOf returns the elements of a single row or column.
+func vectorOf(a *eval.Array) ([]eval.Value, bool) {
+	if a.Rows != 1 && a.Cols != 1 {
+		return nil, false
+	}
+	return a.Values, true
+}
+
+func filter(e *eval.Evaluator, args []eval.Value) eval.Value {
+	a, err := arrayArg(e, args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	inc, err := arrayArg(e, args[1])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	var l lines
+	switch {
+	case inc.Cols == 1 && inc.Rows == a.Rows:
+		l = lines{a: a}
+	case inc.Rows == 1 && inc.Cols == a.Cols:
+		l = lines{a: a, byCol: true}
+	default:
+		return eval.ErrValue
+	}
+	var idx []int
+	for i, v := range inc.Values {
+		ok, err := eval.ToBool(v)
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		if ok {
+			idx = append(idx, i)
+		}
+	}
+	if len(idx) == 0 {
+		if len(args) > 2 {
+			return args[2]
+		}
+		return eval.ErrCalc
+	}
+	return l.pick(idx)
+}
+
+// sortKey is a key of SORT or SORTBY: the values to compare for each
+// line and the direction, 1 for ascending or -1 for descending.
+type sortKey struct {
+	vals  func(i int) eval.Value
+	order int
+}
+
+// sortCompare orders values for SORT like the comparison operators,
+// except that errors come after all other values and blanks last.
+func sortCompare(x, y eval.Value) int {
+	rx, ry := sortRank(x), sortRank(y)
+	if rx != ry || rx > 0 {
+		return rx - ry
+	}
+	return eval.Compare(x, y)
+}
+
+func sortRank(v eval.Value) int {
+	switch v.(type) {
+	case eval.Error:
+		return 1
+	case eval.Blank:
+		return 2
+	}
+	return 0
+}
+
+// sortLines returns the lines of l ordered by keys. The sort is stable so
+// that lines with equal keys keep their order.
+func sortLines(l lines, keys []sortKey) *eval.Array {
+	idx := make([]int, l.len())
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		for _, k := range keys {
+			x, y := k.vals(idx[i]), k.vals(idx[j])
+			// blanks stay last in either direction
+			if c := sortCompare(x, y); c != 0 {
+				if sortRank(x) == 2 || sortRank(y) == 2 {
+					return c < 0
+				}
+				return c*k.order < 0
+			}
+		}
+		return false
+	})
+	return l.pick(idx)
+}
+
+// sortOrder converts a sort order argument, which must be 1 or -1.
+func sortOrder(e *eval.Evaluator, v eval.Value) (int, error) {
+	f, err := e.Number(v)
+	if err != nil {
+		return 0, err
+	}
+	if f != 1 && f != -1 {
+		return 0, eval.ErrValue
+	}
+	return int(f), nil
+}
+
+// numbers returns the elements of an optional argument given as a
+// number or an array of numbers, or def if it was omitted.
+func numbers(e *eval.Evaluator, args []eval.Value, i int, def float64) ([]float64, error) {
+	if i >= len(args) {
+		return []float64{def}, nil
+	}
+	if _, ok := args[i].(eval.Blank); ok {
+		return []float64{def}, nil
+	}
+	a, err := arrayArg(e, args[i])
+	if err != nil {
+		return nil, err
+	}
+	xs := make([]float64, len(a.Values))
+	for k, v := range a.Values {
+		if xs[k], err = eval.ToNumber(v); err != nil {
+			return nil, err
+		}
+	}
+	return xs, nil
+}
+
+func sortFunc(e *eval.Evaluator, args []eval.Value) eval.Value {
+	a, err := arrayArg(e, args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	byCol, err := optBool(e, args, 3)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	l := lines{a: a, byCol: byCol}
+	index, err := numbers(e, args, 1, 1)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	orders, err := numbers(e, args, 2, 1)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	if len(orders) != 1 && len(orders) != len(index) {
+		return eval.ErrValue
+	}
+	keys := make([]sortKey, len(index))
+	for n, f := range index {
+		k := int(f)
+		if k < 1 || k > l.width() {
+			return eval.ErrValue
+		}
+		order, err := sortOrder(e, eval.Number(orders[n%len(orders)]))
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		keys[n] = sortKey{vals: func(i int) eval.Value { return l.at(i, k-1) }, order: order}
+	}
+	return sortLines(l, keys)
+}
+
+// sortBy sorts an array by the arrays that follow it, each a row or
+// column optionally followed by a sort order.
+func sortBy(e *eval.Evaluator, args []eval.Value) eval.Value {
+	a, err := arrayArg(e, args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	var l *lines
+	var keys []sortKey
+	for i := 1; i < len(args); i += 2 {
+		by, err := arrayArg(e, args[i])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		vals, ok := vectorOf(by)
+		if !ok {
+			return eval.ErrValue
+		}
+		// a column sorts rows and a row sorts columns; all keys agree
+		k := lines{a: a, byCol: by.Rows == 1 && by.Cols > 1}
+		if k.len() != len(vals) || l != nil && l.byCol != k.byCol {
+			return eval.ErrValue
+		}
+		l = &k
+		order := 1
+		if i+1 < len(args) {
+			if order, err = sortOrder(e, args[i+1]); err != nil {
+				return eval.ErrorValue(err)
+			}
+		}
+		keys = append(keys, sortKey{vals: func(i int) eval.Value { return vals[i] }, order: order})
+	}
+	return sortLines(*l, keys)
+}
+
+func unique(e *eval.Evaluator, args []eval.Value) eval.Value {
+	a, err := arrayArg(e, args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	byCol, err := optBool(e, args, 1)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	once, err := optBool(e, args, 2)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	l := lines{a: a, byCol: byCol}
+	same := func(i, j int) bool {
+		for k := 0; k < l.width(); k++ {
+			x, y := l.at(i, k), l.at(j, k)
+			if sortRank(x) != sortRank(y) || eval.Compare(x, y) != 0 {
+				return false
+			}
+		}
+		return true
+	}
+	// first holds the first line of each group of equal lines
+	var first, count []int
+next:
+	for i := 0; i < l.len(); i++ {
+		for n, j := range first {
+			if same(i, j) {
+				count[n]++
+				continue next
+			}
+		}
+		first = append(first, i)
+		count = append(count, 1)
+	}
+	var idx []int
+	for n, i := range first {
+		if !once || count[n] == 1 {
+			idx = append(idx, i)
+		}
+	}
+	if len(idx) == 0 {
+		return eval.ErrCalc
+	}
+	return l.pick(idx)
+}
+
+// arraySize converts the number of rows and columns of a generated
+// array. A size of 0 has no result.
+func arraySize(rows, cols float64) (int, int, error) {
+	r, c := math.Trunc(rows), math.Trunc(cols)
+	switch {
+	case r < 0 || c < 0 || r*c > 1<<24:
+		return 0, 0, eval.ErrValue
+	case r == 0 || c == 0:
+		return 0, 0, eval.ErrCalc
+	}
+	return int(r), int(c), nil
+}
+
+func sequence(e *eval.Evaluator, args []eval.Value) eval.Value {
+	x, err := numArgs(e, args, 1, 1, 1, 1)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	rows, cols, err := arraySize(x[0], x[1])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	a := eval.NewArray(rows, cols)
+	for i := range a.Values {
+		a.Values[i] = eval.Number(x[2] + float64(i)*x[3])
+	}
+	return a
+}
+
+func randArray(e *eval.Evaluator, args []eval.Value) eval.Value {
+	x, err := numArgs(e, args, 1, 1, 0, 1)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	whole, err := optBool(e, args, 4)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	rows, cols, err := arraySize(x[0], x[1])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	lo, hi := x[2], x[3]
+	if lo > hi || whole && (lo != math.Trunc(lo) || hi != math.Trunc(hi)) {
+		return eval.ErrValue
+	}
+	a := eval.NewArray(rows, cols)
+	for i := range a.Values {
+		r := e.Random()
+		if whole {
+			a.Values[i] = eval.Number(lo + math.Floor(r*(hi-lo+1)))
+		} else {
+			a.Values[i] = eval.Number(lo + r*(hi-lo))
+		}
+	}
+	return a
+}
@@ -0,0 +1,68 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"testing"
+)
+
+func TestDynamicArrays(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"FILTER(F1:G4,F1:F4>15)", `{20,"Banana";30,"cherry";40,"date"}`},
+		{"FILTER(F1:H1,{TRUE,FALSE,TRUE})", "{10,1.5}"},
+		{`FILTER(G1:G4,F1:F4>99,"none")`, "none"},
+		{"FILTER(G1:G4,F1:F4>99)", "#CALC!"},
+		{"FILTER(F1:G4,{1,0})", "{10;20;30;40}"},
+		{"FILTER(F1:G4,{1,0,1})", "#VALUE!"},
+		{"SORT({3;1;2})", "{1;2;3}"},
+		{"SORT(G1:G4,1,-1)", `{"date";"cherry";"Banana";"apple"}`},
+		{"SORT(G1:G4,,-1)", `{"date";"cherry";"Banana";"apple"}`},
+		{`SORT({1,"b";2,"a";1,"a"},{1,2},{-1,1})`, `{2,"a";1,"a";1,"b"}`},
+		{"SORT({3,1,2},1,1,TRUE)", "{1,2,3}"},
+		{"SORT({3;1},2)", "#VALUE!"},
+		{"SORT({1;2},1,0)", "#VALUE!"},
+		{`SORT({"b";TRUE;1;#N/A})`, `{1;"b";TRUE;#N/A}`},
+		{`SORTBY({"a";"b";"c"},{2;3;1})`, `{"c";"a";"b"}`},
+		{`SORTBY({"a";"b";"c"},{1;2;1},-1,{3;2;1},1)`, `{"b";"c";"a"}`},
+		{`SORTBY({"a","b"},{2,1})`, `{"b","a"}`},
+		{`SORTBY({"a";"b"},{1;2;3})`, "#VALUE!"},
+		{`UNIQUE({1;2;1;"A";"a"})`, `{1;2;"A"}`},
+		{"UNIQUE({1;2;1},FALSE,TRUE)", "2"},
+		{"UNIQUE({1,2,1},TRUE)", "{1,2}"},
+		{"UNIQUE({1,2;1,2;1,3})", "{1,2;1,3}"},
+		{"UNIQUE({1;1},FALSE,TRUE)", "#CALC!"},
+		{"SEQUENCE(2,3)", "{1,2,3;4,5,6}"},
+		{"SEQUENCE(3,1,10,-5)", "{10;5;0}"},
+		{"SEQUENCE(1)", "1"},
+		{"SEQUENCE(0)", "#CALC!"},
+		{"SEQUENCE(-1)", "#VALUE!"},
+		{"SUM(SEQUENCE(10))", "55"},
+		{"ROWS(RANDARRAY(4,2))", "4"},
+		{"RANDARRAY(1,1,2,1)", "#VALUE!"},
+		{"RANDARRAY(1,1,1.5,3,TRUE)", "#VALUE!"},
+	}
+	for _, test := range tests {
+		if got := evalString(t, test.src); got.String() != test.want {
+			t.Errorf("Eval(%q) = %v want %v", test.src, got, test.want)
+		}
+	}
+}
+
+func TestRandArray(t *testing.T) {
+	rand := []float64{0, 0.5, 0.999}
+	e := &eval.Evaluator{Funcs: testFuncs, Rand: func() float64 {
+		r := rand[0]
+		rand = rand[1:]
+		return r
+	}}
+	if got := randArray(e, []eval.Value{eval.Number(1), eval.Number(3), eval.Number(1), eval.Number(6), eval.Bool(true)}); got.String() != "{1,4,6}" {
+		t.Errorf("RANDARRAY(1,3,1,6,TRUE) = %v want {1,4,6}", got)
+	}
+	for _, v := range evalString(t, "RANDARRAY(5,5,-1,1)").(*eval.Array).Values {
+		if x := float64(v.(eval.Number)); x < -1 || x >= 1 {
+			t.Errorf("RANDARRAY(5,5,-1,1) element %v out of range", x)
+		}
+	}
+}
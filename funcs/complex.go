@@ -0,0 +1,142 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/types"
+	"math/cmplx"
+	"strings"
+)
+
+// Complex holds the IM functions on complex numbers. Their arguments are
+// complex values such as 3+4i, numbers, or text in the form "3+4i" or
+// "3+4j"; text that is not a complex number gives #NUM!.
+var Complex = []*eval.Func{
+	{Name: "COMPLEX", Sig: fixed(types.Number, num("real_num"), num("i_num"), opt(text("suffix"))), Call: complexFunc},
+	{Name: "IMREAL", Sig: fixed(types.Number, val("inumber")), Call: im1(func(c complex128) eval.Value { return eval.Number(real(c)) })},
+	{Name: "IMAGINARY", Sig: fixed(types.Number, val("inumber")), Call: im1(func(c complex128) eval.Value { return eval.Number(imag(c)) })},
+	{Name: "IMABS", Sig: fixed(types.Number, val("inumber")), Call: im1(func(c complex128) eval.Value { return eval.Number(cmplx.Abs(c)) })},
+	{Name: "IMARGUMENT", Sig: fixed(types.Number, val("inumber")), Call: im1(imArgument)},
+	{Name: "IMSUM", Sig: variadic(types.Number, val("inumber")), Call: imFold(func(a, b complex128) complex128 { return a + b })},
+	{Name: "IMSUB", Sig: fixed(types.Number, val("inumber1"), val("inumber2")), Call: im2(func(a, b complex128) eval.Value { return eval.Complex(a - b) })},
+	{Name: "IMPRODUCT", Sig: variadic(types.Number, val("inumber")), Call: imFold(func(a, b complex128) complex128 { return a * b })},
+	{Name: "IMDIV", Sig: fixed(types.Number, val("inumber1"), val("inumber2")), Call: im2(imDiv)},
+	{Name: "IMPOWER", Sig: fixed(types.Number, val("inumber"), num("number")), Call: imPower},
+	{Name: "IMSQRT", Sig: fixed(types.Number, val("inumber")), Call: im1(func(c complex128) eval.Value { return eval.ComplexOrError(cmplx.Sqrt(c)) })},
+	{Name: "IMEXP", Sig: fixed(types.Number, val("inumber")), Call: im1(func(c complex128) eval.Value { return eval.ComplexOrError(cmplx.Exp(c)) })},
+	{Name: "IMLN", Sig: fixed(types.Number, val("inumber")), Call: im1(imLn)},
+}
+
+// im1 adapts a function of one complex number.
+func im1(f func(c complex128) eval.Value) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		c, err := e.Complex(args[0])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		return f(c)
+	}
+}
+
+// im2 adapts a function of two complex numbers.
+func im2(f func(a, b complex128) eval.Value) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		a, err := e.Complex(args[0])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		b, err := e.Complex(args[1])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		return f(a, b)
+	}
+}
+
+// imFold combines the complex numbers of all arguments with f. Blank
+// cells in ranges are skipped.
+func imFold(f func(a, b complex128) complex128) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		var r complex128
+		first := true
+		for _, a := range args {
+			for _, v := range e.Values(a) {
+				if _, ok := v.(eval.Blank); ok {
+					continue
+				}
+				c, err := eval.ToComplex(v)
+				if err != nil {
+					return eval.ErrorValue(err)
+				}
+				if first {
+					r, first = c, false
+				} else {
+					r = f(r, c)
+				}
+			}
+		}
+		return eval.ComplexOrError(r)
+	}
+}
+
+// complexFunc implements COMPLEX. With the suffix j the result is text,
+// since complex values always print with i.
+func complexFunc(e *eval.Evaluator, args []eval.Value) eval.Value {
+	x, err := numArgs(e, args, 0, 0)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	suffix := "i"
+	if len(args) > 2 {
+		if suffix, err = e.Text(args[2]); err != nil {
+			return eval.ErrorValue(err)
+		}
+	}
+	c := eval.Complex(complex(x[0], x[1]))
+	switch suffix {
+	case "i", "":
+		return c
+	case "j":
+		s := c.String()
+		if imag(c) != 0 {
+			s = strings.TrimSuffix(s, "i") + "j"
+		}
+		return eval.Text(s)
+	}
+	return eval.ErrValue
+}
+
+func imArgument(c complex128) eval.Value {
+	if c == 0 {
+		return eval.ErrDiv0
+	}
+	return eval.Number(cmplx.Phase(c))
+}
+
+func imDiv(a, b complex128) eval.Value {
+	if b == 0 {
+		return eval.ErrNum
+	}
+	return eval.ComplexOrError(a / b)
+}
+
+func imLn(c complex128) eval.Value {
+	if c == 0 {
+		return eval.ErrNum
+	}
+	return eval.ComplexOrError(cmplx.Log(c))
+}
+
+func imPower(e *eval.Evaluator, args []eval.Value) eval.Value {
+	c, err := e.Complex(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	n, err := e.Number(args[1])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	if c == 0 && n <= 0 {
+		return eval.ErrNum
+	}
+	return eval.ComplexOrError(eval.ComplexPow(c, complex(n, 0)))
+}
@@ -0,0 +1,40 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"testing"
+)
+
+func TestComplex(t *testing.T) {
+	runTests(t, []evalTest{
+		{"COMPLEX(3,4)", eval.Complex(3 + 4i)},
+		{`COMPLEX(3,-1,"j")`, eval.Text("3-j")},
+		{`COMPLEX(3,0,"j")`, eval.Text("3")},
+		{`COMPLEX(3,4,"k")`, eval.ErrValue},
+		{"IMREAL(3+4i)", eval.Number(3)},
+		{`IMREAL("3+4j")`, eval.Number(3)},
+		{`IMAGINARY("-2.5i")`, eval.Number(-2.5)},
+		{"IMAGINARY(7)", eval.Number(0)},
+		{`IMABS("3+4i")`, eval.Number(5)},
+		{`IMABS("abc")`, eval.ErrNum},
+		{"IMABS(B1)", eval.ErrDiv0},
+		{"IMARGUMENT(3+4i)", eval.Number(0.9272952180016122)},
+		{"IMARGUMENT(0)", eval.ErrDiv0},
+		{`IMSUM("3+4i","5-3i")`, eval.Complex(8 + 1i)},
+		{"IMSUM(F1:F4,1i)", eval.Complex(100 + 1i)},
+		{`IMSUB("13+4i","5+3i")`, eval.Complex(8 + 1i)},
+		{`IMPRODUCT("3+4i","5-3i")`, eval.Complex(27 + 11i)},
+		{"IMPRODUCT(1i,1i,2)", eval.Complex(-2)},
+		{`IMDIV("3+4i","1-i")`, eval.Complex(-0.5 + 3.5i)},
+		{"IMDIV(1,0)", eval.ErrNum},
+		{`IMPOWER("2+3i",3)`, eval.Complex(-46 + 9i)},
+		{`IMREAL(IMPOWER("2+3i",0.5))`, eval.Number(1.6741492280355401)},
+		{"IMPOWER(0,-1)", eval.ErrNum},
+		{`IMSQRT("-4")`, eval.Complex(2i)},
+		{"IMREAL(IMEXP(1+1i))", eval.Number(1.4686939399158851)},
+		{"IMAGINARY(IMEXP(1+1i))", eval.Number(2.2873552871788423)},
+		{"IMREAL(IMLN(3+4i))", eval.Number(1.6094379124341003)},
+		{"IMAGINARY(IMLN(3+4i))", eval.Number(0.9272952180016122)},
+		{"IMLN(0)", eval.ErrNum},
+	})
+}
@@ -0,0 +1,125 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"strings"
+)
+
+// Criterion is a condition on cell values as used by COUNTIF, SUMIFS and
+// the other conditional functions. It is given as a number, a logical
+// value or text such as "apple", "a*", ">=10", "<>" or "<2024-01-01".
+//
+// Text operands compare without regard to case; with = and <> they may
+// contain the wildcards ? and *, escaped with ~. An operand that looks
+// like a number or a date compares with number cells, and for = and <>
+// also with text cells holding the same number. An empty operand
+// matches blank cells.
+type Criterion struct {
+	op  string     // =, <>, <, <=, > or >=
+	v   eval.Value // operand
+	pat []rune     // lower case pattern of a text operand of = or <>
+}
+
+var criterionOps = []string{"<=", ">=", "<>", "<", ">", "="}
+
+// ParseCriterion returns the criterion given by v. Date text is read in
+// the date system of e.
+func ParseCriterion(e *eval.Evaluator, v eval.Value) *Criterion {
+	c := &Criterion{op: "="}
+	switch x := e.Deref(v).(type) {
+	case eval.Blank:
+		c.v = eval.Number(0)
+	case eval.Text:
+		s := string(x)
+		for _, op := range criterionOps {
+			if strings.HasPrefix(s, op) {
+				c.op, s = op, s[len(op):]
+				break
+			}
+		}
+		c.v = criterionOperand(e, s)
+		if t, ok := c.v.(eval.Text); ok && (c.op == "=" || c.op == "<>") {
+			c.pat = []rune(strings.ToLower(string(t)))
+		}
+	default:
+		c.v = x
+	}
+	return c
+}
+
+// criterionOperand converts the operand of a text criterion.
+func criterionOperand(e *eval.Evaluator, s string) eval.Value {
+	if x, ok := eval.ParseNumber(s); ok {
+		return eval.Number(x)
+	}
+	switch u := strings.ToUpper(s); u {
+	case "TRUE", "FALSE":
+		return eval.Bool(u == "TRUE")
+	case string(eval.ErrNull), string(eval.ErrDiv0), string(eval.ErrValue), string(eval.ErrRef),
+		string(eval.ErrName), string(eval.ErrNum), string(eval.ErrNA), string(eval.ErrCalc), string(eval.ErrSpill):
+		return eval.Error(u)
+	}
+	// dates need digits; a month name alone stays text
+	if strings.ContainsAny(s, "0123456789") {
+		if dt, ok := parseDateTime(s, e.Now().Year(), e.Date1904); ok {
+			return eval.Number(float64(dt.days) + dt.secs/86400)
+		}
+	}
+	return eval.Text(s)
+}
+
+// Match reports whether v satisfies the criterion.
+func (c *Criterion) Match(v eval.Value) bool {
+	n, ok := c.compare(v)
+	switch c.op {
+	case "<>":
+		return !ok || n != 0
+	case "<":
+		return ok && n < 0
+	case "<=":
+		return ok && n <= 0
+	case ">":
+		return ok && n > 0
+	case ">=":
+		return ok && n >= 0
+	}
+	return ok && n == 0
+}
+
+// compare compares v with the operand. ok is false if they cannot be
+// compared, such as text with a number.
+func (c *Criterion) compare(v eval.Value) (n int, ok bool) {
+	switch x := c.v.(type) {
+	case eval.Number:
+		switch y := v.(type) {
+		case eval.Number:
+			return eval.Compare(y, x), true
+		case eval.Text:
+			if f, ok := eval.ParseNumber(string(y)); ok && (c.op == "=" || c.op == "<>") {
+				return eval.Compare(eval.Number(f), x), true
+			}
+		}
+	case eval.Text:
+		switch y := v.(type) {
+		case eval.Blank:
+			return 0, x == ""
+		case eval.Text:
+			if c.pat == nil {
+				return eval.Compare(y, x), true
+			}
+			if matchWildcard(c.pat, []rune(strings.ToLower(string(y))), false) {
+				return 0, true
+			}
+			return 1, true
+		}
+	case eval.Bool:
+		if y, ok := v.(eval.Bool); ok {
+			return eval.Compare(y, x), true
+		}
+	case eval.Error:
+		if y, ok := v.(eval.Error); ok && y == x {
+			return 0, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,561 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/format"
+	"github.com/ajz01/calc/types"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Date holds the date and time functions. Dates are serial day numbers
+// in the evaluator's date system, times are fractions of a day, and
+// arguments given as date text such as "2021-03-07" are converted.
+// TODAY and NOW read the evaluator's clock.
+var Date = []*eval.Func{
+	{Name: "DATE", Sig: fixed(types.Number, num("year"), num("month"), num("day")), Call: dateFunc},
+	{Name: "TIME", Sig: fixed(types.Number, num("hour"), num("minute"), num("second")), Call: timeFunc},
+	{Name: "TODAY", Sig: fixed(types.Number), Call: today, Volatile: true},
+	{Name: "NOW", Sig: fixed(types.Number), Call: now, Volatile: true},
+	{Name: "YEAR", Sig: fixed(types.Number, num("serial_number")), Call: datePart(func(y int, m time.Month, d int) int { return y })},
+	{Name: "MONTH", Sig: fixed(types.Number, num("serial_number")), Call: datePart(func(y int, m time.Month, d int) int { return int(m) })},
+	{Name: "DAY", Sig: fixed(types.Number, num("serial_number")), Call: datePart(func(y int, m time.Month, d int) int { return d })},
+	{Name: "HOUR", Sig: fixed(types.Number, num("serial_number")), Call: timePart(func(secs int) int { return secs / 3600 })},
+	{Name: "MINUTE", Sig: fixed(types.Number, num("serial_number")), Call: timePart(func(secs int) int { return secs / 60 % 60 })},
+	{Name: "SECOND", Sig: fixed(types.Number, num("serial_number")), Call: timePart(func(secs int) int { return secs % 60 })},
+	{Name: "WEEKDAY", Sig: fixed(types.Number, num("serial_number"), opt(num("return_type"))), Call: weekday},
+	{Name: "WEEKNUM", Sig: fixed(types.Number, num("serial_number"), opt(num("return_type"))), Call: weeknum},
+	{Name: "ISOWEEKNUM", Sig: fixed(types.Number, num("date")), Call: isoweeknum},
+	{Name: "EDATE", Sig: fixed(types.Number, num("start_date"), num("months")), Call: addMonths(false)},
+	{Name: "EOMONTH", Sig: fixed(types.Number, num("start_date"), num("months")), Call: addMonths(true)},
+	{Name: "DATEDIF", Sig: fixed(types.Number, num("start_date"), num("end_date"), text("unit")), Call: datedif},
+	{Name: "NETWORKDAYS", Sig: fixed(types.Number, num("start_date"), num("end_date"), opt(val("holidays"))), Call: networkdays},
+	{Name: "WORKDAY", Sig: fixed(types.Number, num("start_date"), num("days"), opt(val("holidays"))), Call: workday},
+	{Name: "DAYS", Sig: fixed(types.Number, num("end_date"), num("start_date")), Call: days},
+	{Name: "DATEVALUE", Sig: fixed(types.Number, text("date_text")), Call: datevalue},
+	{Name: "TIMEVALUE", Sig: fixed(types.Number, text("time_text")), Call: timevalue},
+}
+
+// maxSerial is the serial number after 31 December 9999 in the 1900
+// date system; the 1904 system starts 1462 days later.
+const maxSerial = 2958466
+
+// dateLimit returns the first serial number past the last valid date.
+func dateLimit(e *eval.Evaluator) int {
+	if e.Date1904 {
+		return maxSerial - 1462
+	}
+	return maxSerial
+}
+
+// dateResult returns days as a Number, or #NUM! if it is not a valid date.
+func dateResult(e *eval.Evaluator, days int) eval.Value {
+	if days < 0 || days >= dateLimit(e) {
+		return eval.ErrNum
+	}
+	return eval.Number(days)
+}
+
+// serialArg returns v as a serial date and time. Text is converted as by
+// DATEVALUE and TIMEVALUE combined, falling back to a number.
+func serialArg(e *eval.Evaluator, v eval.Value) (float64, error) {
+	v = e.Deref(v)
+	if s, ok := v.(eval.Text); ok {
+		if dt, ok := parseDateTime(string(s), e.Now().Year(), e.Date1904); ok {
+			return float64(dt.days) + dt.secs/86400, nil
+		}
+	}
+	x, err := eval.ToNumber(v)
+	if err != nil {
+		return 0, err
+	}
+	if x < 0 || x >= float64(dateLimit(e)) {
+		return 0, eval.ErrNum
+	}
+	return x, nil
+}
+
+// dayArg returns argument i as a serial day number, ignoring the time.
+func dayArg(e *eval.Evaluator, args []eval.Value, i int) (int, error) {
+	x, err := serialArg(e, args[i])
+	return int(x), err
+}
+
+// daysIn returns the number of days in a month, 29 for February 1900.
+func daysIn(year int, month time.Month, date1904 bool) int {
+	return format.Serial(year, month+1, 1, date1904) - format.Serial(year, month, 1, date1904)
+}
+
+func dateFunc(e *eval.Evaluator, args []eval.Value) eval.Value {
+	var n [3]int
+	for i := range n {
+		x, err := e.Number(args[i])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		n[i] = int(x)
+	}
+	y := n[0]
+	if y >= 0 && y < 1900 {
+		y += 1900
+	}
+	if y < 0 || y > 9999 {
+		return eval.ErrNum
+	}
+	return dateResult(e, format.Serial(y, time.Month(n[1]), n[2], e.Date1904))
+}
+
+func timeFunc(e *eval.Evaluator, args []eval.Value) eval.Value {
+	secs := 0
+	for i, unit := range []int{3600, 60, 1} {
+		x, err := e.Number(args[i])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		if x > 32767 {
+			return eval.ErrNum
+		}
+		secs += int(x) * unit
+	}
+	if secs < 0 {
+		return eval.ErrNum
+	}
+	return eval.Number(float64(secs%86400) / 86400)
+}
+
+func today(e *eval.Evaluator, args []eval.Value) eval.Value {
+	y, m, d := e.Now().Date()
+	return dateResult(e, format.Serial(y, m, d, e.Date1904))
+}
+
+func now(e *eval.Evaluator, args []eval.Value) eval.Value {
+	return eval.Number(format.FromTime(e.Now(), e.Date1904))
+}
+
+// datePart adapts YEAR, MONTH and DAY.
+func datePart(f func(y int, m time.Month, d int) int) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		days, err := dayArg(e, args, 0)
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		return eval.Number(f(format.Date(days, e.Date1904)))
+	}
+}
+
+// timePart adapts HOUR, MINUTE and SECOND, which see the time rounded to
+// the second.
+func timePart(f func(secs int) int) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		x, err := serialArg(e, args[0])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		secs := int(math.Round((x-math.Floor(x))*86400)) % 86400
+		return eval.Number(f(secs))
+	}
+}
+
+// weekStarts maps the return types of WEEKDAY and WEEKNUM to the first
+// day of the week.
+var weekStarts = map[int]time.Weekday{
+	1: time.Sunday, 2: time.Monday, 3: time.Monday,
+	11: time.Monday, 12: time.Tuesday, 13: time.Wednesday, 14: time.Thursday,
+	15: time.Friday, 16: time.Saturday, 17: time.Sunday,
+}
+
+func weekday(e *eval.Evaluator, args []eval.Value) eval.Value {
+	days, err := dayArg(e, args, 0)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	t, err := optNumber(e, args, 1, 1)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	start, ok := weekStarts[int(t)]
+	if !ok {
+		return eval.ErrNum
+	}
+	n := (int(format.Weekday(days, e.Date1904))-int(start)+7)%7 + 1
+	if t == 3 {
+		n--
+	}
+	return eval.Number(n)
+}
+
+// weeknum numbers weeks so that week 1 contains 1 January. Return type
+// 21 gives ISO 8601 week numbers.
+func weeknum(e *eval.Evaluator, args []eval.Value) eval.Value {
+	days, err := dayArg(e, args, 0)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	t, err := optNumber(e, args, 1, 1)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	if t == 21 {
+		_, w := format.Time(float64(days), e.Date1904).ISOWeek()
+		return eval.Number(w)
+	}
+	start, ok := weekStarts[int(t)]
+	if !ok || t == 3 {
+		return eval.ErrNum
+	}
+	y, _, _ := format.Date(days, e.Date1904)
+	jan1 := format.Serial(y, time.January, 1, e.Date1904)
+	offset := (int(format.Weekday(jan1, e.Date1904)) - int(start) + 7) % 7
+	return eval.Number((days-jan1+offset)/7 + 1)
+}
+
+func isoweeknum(e *eval.Evaluator, args []eval.Value) eval.Value {
+	days, err := dayArg(e, args, 0)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	_, w := format.Time(float64(days), e.Date1904).ISOWeek()
+	return eval.Number(w)
+}
+
+// addMonths adapts EDATE, which keeps the day of the month where it can,
+// and EOMONTH, which returns the last day of the month.
+func addMonths(end bool) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		days, err := dayArg(e, args, 0)
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		n, err := e.Number(args[1])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		y, m, d := format.Date(days, e.Date1904)
+		m += time.Month(n)
+		if end {
+			return dateResult(e, format.Serial(y, m+1, 0, e.Date1904))
+		}
+		if last := daysIn(y, m, e.Date1904); d > last {
+			d = last
+		}
+		return dateResult(e, format.Serial(y, m, d, e.Date1904))
+	}
+}
+
+func datedif(e *eval.Evaluator, args []eval.Value) eval.Value {
+	start, err := dayArg(e, args, 0)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	end, err := dayArg(e, args, 1)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	unit, err := e.Text(args[2])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	if start > end {
+		return eval.ErrNum
+	}
+	y1, m1, d1 := format.Date(start, e.Date1904)
+	y2, m2, d2 := format.Date(end, e.Date1904)
+	months := (y2-y1)*12 + int(m2-m1)
+	if d2 < d1 {
+		months--
+	}
+	switch strings.ToUpper(unit) {
+	case "D":
+		return eval.Number(end - start)
+	case "M":
+		return eval.Number(months)
+	case "Y":
+		return eval.Number(months / 12)
+	case "YM":
+		return eval.Number(months % 12)
+	case "MD":
+		if d2 >= d1 {
+			return eval.Number(d2 - d1)
+		}
+		return eval.Number(end - format.Serial(y2, m2-1, d1, e.Date1904))
+	case "YD":
+		a := format.Serial(y2, m1, d1, e.Date1904)
+		if a > end {
+			a = format.Serial(y2-1, m1, d1, e.Date1904)
+		}
+		return eval.Number(end - a)
+	}
+	return eval.ErrNum
+}
+
+// holidays collects the serial days in the optional argument i.
+func holidays(e *eval.Evaluator, args []eval.Value, i int) (map[int]bool, error) {
+	h := make(map[int]bool)
+	if i >= len(args) {
+		return h, nil
+	}
+	for _, v := range e.Values(args[i]) {
+		if _, ok := v.(eval.Blank); ok {
+			continue
+		}
+		x, err := serialArg(e, v)
+		if err != nil {
+			return nil, err
+		}
+		h[int(x)] = true
+	}
+	return h, nil
+}
+
+// isWorkday reports whether serial day days is a weekday and not a
+// holiday.
+func isWorkday(e *eval.Evaluator, days int, h map[int]bool) bool {
+	switch format.Weekday(days, e.Date1904) {
+	case time.Saturday, time.Sunday:
+		return false
+	}
+	return !h[days]
+}
+
+func networkdays(e *eval.Evaluator, args []eval.Value) eval.Value {
+	start, err := dayArg(e, args, 0)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	end, err := dayArg(e, args, 1)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	h, err := holidays(e, args, 2)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	sign := 1
+	if start > end {
+		start, end, sign = end, start, -1
+	}
+	n := 0
+	for d := start; d <= end; d++ {
+		if isWorkday(e, d, h) {
+			n++
+		}
+	}
+	return eval.Number(sign * n)
+}
+
+func workday(e *eval.Evaluator, args []eval.Value) eval.Value {
+	d, err := dayArg(e, args, 0)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	x, err := e.Number(args[1])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	h, err := holidays(e, args, 2)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	if math.Abs(x) >= float64(dateLimit(e)) {
+		return eval.ErrNum
+	}
+	n, step := int(x), 1
+	if n < 0 {
+		n, step = -n, -1
+	}
+	for n > 0 {
+		d += step
+		if d < 0 || d >= dateLimit(e) {
+			return eval.ErrNum
+		}
+		if isWorkday(e, d, h) {
+			n--
+		}
+	}
+	return eval.Number(d)
+}
+
+func days(e *eval.Evaluator, args []eval.Value) eval.Value {
+	end, err := dayArg(e, args, 0)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	start, err := dayArg(e, args, 1)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	return eval.Number(end - start)
+}
+
+func datevalue(e *eval.Evaluator, args []eval.Value) eval.Value {
+	s, err := e.Text(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	dt, ok := parseDateTime(s, e.Now().Year(), e.Date1904)
+	if !ok || !dt.hasDate {
+		return eval.ErrValue
+	}
+	return eval.Number(dt.days)
+}
+
+func timevalue(e *eval.Evaluator, args []eval.Value) eval.Value {
+	s, err := e.Text(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	dt, ok := parseDateTime(s, e.Now().Year(), e.Date1904)
+	if !ok {
+		return eval.ErrValue
+	}
+	return eval.Number(math.Mod(dt.secs, 86400) / 86400)
+}
+
+// dateTime is parsed date and time text.
+type dateTime struct {
+	days             int     // serial day number
+	secs             float64 // seconds since midnight
+	hasDate, hasTime bool
+}
+
+var monthNames = []string{"january", "february", "march", "april", "may", "june", "july", "august", "september", "october", "november", "december"}
+
+// monthName returns the month named by s, which may be abbreviated to
+// three letters or more.
+func monthName(s string) (time.Month, bool) {
+	s = strings.ToLower(s)
+	if len(s) < 3 {
+		return 0, false
+	}
+	for i, name := range monthNames {
+		if strings.HasPrefix(name, s) {
+			return time.Month(i + 1), true
+		}
+	}
+	return 0, false
+}
+
+// parseDateTime parses dates such as "2021-03-07", "3/7/2021",
+// "7-Mar-2021" and "March 7, 2021", times such as "13:05", "1:05:09 PM"
+// and "13:05:09.5", or a date followed by a time. Numeric dates with a
+// four digit year first are year-month-day, others month/day/year. Dates
+// without a year fall in thisYear.
+func parseDateTime(s string, thisYear int, date1904 bool) (dateTime, bool) {
+	var dt dateTime
+	var parts []string
+	pm, ampm := false, false
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' }) {
+		lf := strings.ToLower(f)
+		if strings.HasSuffix(lf, "am") || strings.HasSuffix(lf, "pm") {
+			if ampm {
+				return dt, false
+			}
+			ampm, pm = true, lf[len(lf)-2] == 'p'
+			if f = f[:len(f)-2]; f == "" {
+				continue
+			}
+			if !strings.ContainsRune(f, ':') {
+				f += ":00" // "3 PM"
+			}
+		}
+		if !strings.ContainsRune(f, ':') {
+			parts = append(parts, strings.FieldsFunc(f, func(r rune) bool { return r == '-' || r == '/' })...)
+			continue
+		}
+		secs, ok := parseTime(f)
+		if !ok || dt.hasTime {
+			return dt, false
+		}
+		dt.secs, dt.hasTime = secs, true
+	}
+	if ampm {
+		if !dt.hasTime || dt.secs < 3600 || dt.secs >= 13*3600 {
+			return dt, false
+		}
+		if dt.secs >= 12*3600 {
+			dt.secs -= 12 * 3600
+		}
+		if pm {
+			dt.secs += 12 * 3600
+		}
+	}
+	if len(parts) == 0 {
+		return dt, dt.hasTime
+	}
+
+	var month time.Month
+	var nums []int
+	var lens []int
+	for _, p := range parts {
+		if m, ok := monthName(p); ok && month == 0 {
+			month = m
+			continue
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return dt, false
+		}
+		nums, lens = append(nums, n), append(lens, len(p))
+	}
+	year, day := thisYear, 1
+	switch {
+	case month == 0 && len(nums) == 3 && lens[0] == 4:
+		year, month, day = nums[0], time.Month(nums[1]), nums[2]
+	case month == 0 && len(nums) == 3:
+		month, day, year = time.Month(nums[0]), nums[1], fullYear(nums[2], lens[2])
+	case month == 0 && len(nums) == 2 && (nums[1] > 31 || lens[1] == 4):
+		month, year = time.Month(nums[0]), nums[1]
+	case month == 0 && len(nums) == 2:
+		month, day = time.Month(nums[0]), nums[1]
+	case month != 0 && len(nums) == 2:
+		day, year = nums[0], fullYear(nums[1], lens[1])
+	case month != 0 && len(nums) == 1 && (nums[0] > 31 || lens[0] == 4):
+		year = nums[0]
+	case month != 0 && len(nums) == 1:
+		day = nums[0]
+	default:
+		return dt, false
+	}
+	if year < 1900 || year > 9999 || month < 1 || month > 12 || day < 1 || day > daysIn(year, month, date1904) {
+		return dt, false
+	}
+	dt.days, dt.hasDate = format.Serial(year, month, day, date1904), true
+	return dt, dt.days >= 0
+}
+
+// fullYear expands a two digit year to 1930 through 2029.
+func fullYear(n, digits int) int {
+	switch {
+	case digits > 2:
+		return n
+	case n < 30:
+		return n + 2000
+	}
+	return n + 1900
+}
+
+// parseTime parses h:mm, h:mm:ss or h:mm:ss.fff to seconds.
+func parseTime(s string) (float64, bool) {
+	f := strings.Split(s, ":")
+	if len(f) < 2 || len(f) > 3 {
+		return 0, false
+	}
+	h, err := strconv.Atoi(f[0])
+	if err != nil || h < 0 {
+		return 0, false
+	}
+	m, err := strconv.Atoi(f[1])
+	if err != nil || m < 0 || m > 59 {
+		return 0, false
+	}
+	var sec float64
+	if len(f) == 3 {
+		sec, err = strconv.ParseFloat(f[2], 64)
+		if err != nil || sec < 0 || sec >= 60 {
+			return 0, false
+		}
+	}
+	return float64(h*3600+m*60) + sec, true
+}
@@ -0,0 +1,124 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/parser"
+	"testing"
+	"time"
+)
+
+func TestDate(t *testing.T) {
+	runTests(t, []evalTest{
+		{"DATE(2024,3,15)", eval.Number(45366)},
+		{"DATE(1900,2,29)", eval.Number(60)},
+		{"DATE(1900,3,1)", eval.Number(61)},
+		{"DATE(2024,14,1)", eval.Number(45689)},
+		{"DATE(2024,1,0)", eval.Number(45291)},
+		{"DATE(124,1,1)", eval.Number(45292)},
+		{"DATE(-1,1,1)", eval.ErrNum},
+		{"TIME(12,0,0)", eval.Number(0.5)},
+		{"TIME(25,0,0)", eval.Number(1.0 / 24)},
+		{"TIME(0,-1,0)", eval.ErrNum},
+		{"TODAY()", eval.Number(45366)},
+		{"NOW()", eval.Number(45366 + 49530.0/86400)},
+		{"YEAR(45366)", eval.Number(2024)},
+		{"MONTH(60)", eval.Number(2)},
+		{"DAY(60)", eval.Number(29)},
+		{`YEAR("2024-03-15")`, eval.Number(2024)},
+		{"YEAR(-1)", eval.ErrNum},
+		{"HOUR(0.75)", eval.Number(18)},
+		{"MINUTE(0.5+15/1440)", eval.Number(15)},
+		{`SECOND("10:15:30")`, eval.Number(30)},
+		{`HOUR("1:05 PM")`, eval.Number(13)},
+	})
+}
+
+func TestWeeks(t *testing.T) {
+	runTests(t, []evalTest{
+		{"WEEKDAY(45366)", eval.Number(6)},
+		{"WEEKDAY(45366,2)", eval.Number(5)},
+		{"WEEKDAY(45366,3)", eval.Number(4)},
+		{"WEEKDAY(45366,16)", eval.Number(7)},
+		{"WEEKDAY(1)", eval.Number(1)},
+		{"WEEKDAY(45366,4)", eval.ErrNum},
+		{"WEEKNUM(45366)", eval.Number(11)},
+		{"WEEKNUM(DATE(2024,1,7))", eval.Number(2)},
+		{"WEEKNUM(DATE(2024,1,7),2)", eval.Number(1)},
+		{"WEEKNUM(DATE(2021,1,1),21)", eval.Number(53)},
+		{"ISOWEEKNUM(45366)", eval.Number(11)},
+		{"ISOWEEKNUM(DATE(2021,1,1))", eval.Number(53)},
+	})
+}
+
+func TestDateArithmetic(t *testing.T) {
+	runTests(t, []evalTest{
+		{"EDATE(DATE(2024,1,31),1)", eval.Number(45351)},
+		{"EDATE(45366,-3)", eval.Number(45275)},
+		{"EOMONTH(DATE(2024,1,15),1)", eval.Number(45351)},
+		{"EOMONTH(45366,-1)", eval.Number(45351)},
+		{`DATEDIF(DATE(2020,5,20),45366,"Y")`, eval.Number(3)},
+		{`DATEDIF(DATE(2020,5,20),45366,"M")`, eval.Number(45)},
+		{`DATEDIF(DATE(2020,5,20),45366,"D")`, eval.Number(1395)},
+		{`DATEDIF(DATE(2020,5,20),45366,"YM")`, eval.Number(9)},
+		{`DATEDIF(DATE(2020,5,20),45366,"MD")`, eval.Number(24)},
+		{`DATEDIF(DATE(2020,5,20),45366,"yd")`, eval.Number(300)},
+		{`DATEDIF(2,1,"D")`, eval.ErrNum},
+		{`DATEDIF(1,2,"X")`, eval.ErrNum},
+		{"NETWORKDAYS(DATE(2024,3,1),DATE(2024,3,31))", eval.Number(21)},
+		{"NETWORKDAYS(DATE(2024,3,1),DATE(2024,3,31),DATE(2024,3,29))", eval.Number(20)},
+		{"NETWORKDAYS(DATE(2024,3,31),DATE(2024,3,1))", eval.Number(-21)},
+		{"WORKDAY(45366,1)", eval.Number(45369)},
+		{"WORKDAY(45366,-5)", eval.Number(45359)},
+		{"WORKDAY(45366,1,DATE(2024,3,18))", eval.Number(45370)},
+		{"WORKDAY(45366,1,B1)", eval.ErrDiv0},
+		{"WORKDAY(1,1E300)", eval.ErrNum},
+		{"WORKDAY(45366,-1E300)", eval.ErrNum},
+		{`DAYS("2024-03-15","2024-01-01")`, eval.Number(74)},
+	})
+}
+
+func TestDateValue(t *testing.T) {
+	runTests(t, []evalTest{
+		{`DATEVALUE("2024-03-15")`, eval.Number(45366)},
+		{`DATEVALUE("3/15/2024")`, eval.Number(45366)},
+		{`DATEVALUE("15-Mar-2024")`, eval.Number(45366)},
+		{`DATEVALUE("March 15, 2024")`, eval.Number(45366)},
+		{`DATEVALUE("15 Mar 24")`, eval.Number(45366)},
+		{`DATEVALUE("Mar 15")`, eval.Number(45366)},
+		{`DATEVALUE("2024-03-15 10:30")`, eval.Number(45366)},
+		{`DATEVALUE("2/29/1900")`, eval.Number(60)},
+		{`DATEVALUE("2/30/2024")`, eval.ErrValue},
+		{`DATEVALUE("10:30")`, eval.ErrValue},
+		{`TIMEVALUE("10:30")`, eval.Number(0.4375)},
+		{`TIMEVALUE("1:30 PM")`, eval.Number(0.5625)},
+		{`TIMEVALUE("12:00 AM")`, eval.Number(0)},
+		{`TIMEVALUE("6pm")`, eval.Number(0.75)},
+		{`TIMEVALUE("2024-03-15 18:00")`, eval.Number(0.75)},
+		{`TIMEVALUE("25:00")`, eval.Number(1.0 / 24)},
+		{`TIMEVALUE("13:00 PM")`, eval.ErrValue},
+		{`TIMEVALUE("abc")`, eval.ErrValue},
+	})
+}
+
+func TestDate1904(t *testing.T) {
+	e := &eval.Evaluator{Funcs: testFuncs, Date1904: true, Clock: func() time.Time { return testNow }}
+	tests := []evalTest{
+		{"DATE(1904,1,1)", eval.Number(0)},
+		{"DATE(2024,3,15)", eval.Number(43904)},
+		{"DATE(1903,1,1)", eval.ErrNum},
+		{"TODAY()", eval.Number(43904)},
+		{"YEAR(0)", eval.Number(1904)},
+		{"WEEKDAY(43904)", eval.Number(6)},
+		{`DATEVALUE("2024-03-15")`, eval.Number(43904)},
+		{`TEXT(0,"yyyy-mm-dd")`, eval.Text("1904-01-01")},
+	}
+	for _, test := range tests {
+		x, err := parser.ParseBytes([]byte(test.src))
+		if err != nil {
+			t.Fatalf("ParseBytes(%q) %v", test.src, err)
+		}
+		if got := e.Eval(x); got != test.want {
+			t.Errorf("Eval(%q) = %v want %v", test.src, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/types"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Engineering holds the base conversion, bitwise and unit conversion
+// functions. Binary, octal and hexadecimal numbers have at most 10
+// digits; negative numbers are written in 10 digit two's complement.
+var Engineering = []*eval.Func{
+	{Name: "BIN2DEC", Sig: fixed(types.Number, val("number")), Call: baseFunc(2, 10)},
+	{Name: "BIN2OCT", Sig: fixed(types.Text, val("number"), opt(num("places"))), Call: baseFunc(2, 8)},
+	{Name: "BIN2HEX", Sig: fixed(types.Text, val("number"), opt(num("places"))), Call: baseFunc(2, 16)},
+	{Name: "OCT2BIN", Sig: fixed(types.Text, val("number"), opt(num("places"))), Call: baseFunc(8, 2)},
+	{Name: "OCT2DEC", Sig: fixed(types.Number, val("number")), Call: baseFunc(8, 10)},
+	{Name: "OCT2HEX", Sig: fixed(types.Text, val("number"), opt(num("places"))), Call: baseFunc(8, 16)},
+	{Name: "DEC2BIN", Sig: fixed(types.Text, num("number"), opt(num("places"))), Call: baseFunc(10, 2)},
+	{Name: "DEC2OCT", Sig: fixed(types.Text, num("number"), opt(num("places"))), Call: baseFunc(10, 8)},
+	{Name: "DEC2HEX", Sig: fixed(types.Text, num("number"), opt(num("places"))), Call: baseFunc(10, 16)},
+	{Name: "HEX2BIN", Sig: fixed(types.Text, val("number"), opt(num("places"))), Call: baseFunc(16, 2)},
+	{Name: "HEX2OCT", Sig: fixed(types.Text, val("number"), opt(num("places"))), Call: baseFunc(16, 8)},
+	{Name: "HEX2DEC", Sig: fixed(types.Number, val("number")), Call: baseFunc(16, 10)},
+	{Name: "BITAND", Sig: fixed(types.Number, num("number1"), num("number2")), Call: bitFunc(func(a, b uint64) uint64 { return a & b })},
+	{Name: "BITOR", Sig: fixed(types.Number, num("number1"), num("number2")), Call: bitFunc(func(a, b uint64) uint64 { return a | b })},
+	{Name: "BITXOR", Sig: fixed(types.Number, num("number1"), num("number2")), Call: bitFunc(func(a, b uint64) uint64 { return a ^ b })},
+	{Name: "BITLSHIFT", Sig: fixed(types.Number, num("number"), num("shift_amount")), Call: shiftFunc(1)},
+	{Name: "BITRSHIFT", Sig: fixed(types.Number, num("number"), num("shift_amount")), Call: shiftFunc(-1)},
+	{Name: "CONVERT", Sig: fixed(types.Number, num("number"), text("from_unit"), text("to_unit")), Call: convert},
+}
+
+// baseFunc adapts the conversion of a number from one base to another.
+// Base 10 numbers are numbers; the others are text, which is read with
+// eval.ParseBase and written by formatBase.
+func baseFunc(from, to int) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		var n float64
+		if from == 10 {
+			x, err := e.Number(args[0])
+			if err != nil {
+				return eval.ErrorValue(err)
+			}
+			n = math.Trunc(x)
+		} else {
+			v := e.Deref(args[0])
+			if _, ok := v.(eval.Bool); ok {
+				return eval.ErrValue
+			}
+			s, err := eval.ToText(v)
+			if err != nil {
+				return eval.ErrorValue(err)
+			}
+			if n, err = eval.ParseBase(strings.TrimSpace(s), from); err != nil {
+				return eval.ErrorValue(err)
+			}
+		}
+		if to == 10 {
+			return eval.Number(n)
+		}
+		places := -1.0
+		if len(args) > 1 {
+			x, err := optNumber(e, args, 1, -1)
+			if err != nil {
+				return eval.ErrorValue(err)
+			}
+			if places = math.Trunc(x); places < 1 || places > 10 {
+				return eval.ErrNum
+			}
+		}
+		s, err := formatBase(int64(n), to, int(places))
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		return eval.Text(s)
+	}
+}
+
+// formatBase writes n in base 2, 8 or 16, padded with zeros to places
+// digits if places is positive. Negative numbers are written as 10
+// digits regardless of places. Numbers out of range report #NUM!.
+func formatBase(n int64, base, places int) (string, error) {
+	size := uint(10 * bits.Len(uint(base-1)))
+	if n < -1<<(size-1) || n >= 1<<(size-1) {
+		return "", eval.ErrNum
+	}
+	if n < 0 {
+		n += 1 << size
+		places = 0
+	}
+	s := strings.ToUpper(strconv.FormatInt(n, base))
+	if places > 0 {
+		if len(s) > places {
+			return "", eval.ErrNum
+		}
+		s = strings.Repeat("0", places-len(s)) + s
+	}
+	return s, nil
+}
+
+// maxBits is the largest number the BIT functions accept, 2^48-1.
+const maxBits = 1<<48 - 1
+
+// bitArg returns x as an unsigned integer, or #NUM! if x is negative,
+// fractional or larger than maxBits.
+func bitArg(x float64) (uint64, error) {
+	if x < 0 || x > maxBits || x != math.Trunc(x) {
+		return 0, eval.ErrNum
+	}
+	return uint64(x), nil
+}
+
+// bitFunc adapts a bitwise operation on two numbers.
+func bitFunc(f func(a, b uint64) uint64) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return fn2(0, func(x, y float64) eval.Value {
+		a, err := bitArg(x)
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		b, err := bitArg(y)
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		return eval.Number(f(a, b))
+	})
+}
+
+// shiftFunc adapts BITLSHIFT, with dir 1, and BITRSHIFT, with dir -1. A
+// negative shift amount shifts the other way.
+func shiftFunc(dir float64) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return fn2(0, func(x, shift float64) eval.Value {
+		n, err := bitArg(x)
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		shift = math.Trunc(shift) * dir
+		if math.Abs(shift) > 53 {
+			return eval.ErrNum
+		}
+		if shift < 0 {
+			return eval.Number(n >> uint(-shift))
+		}
+		if r := float64(n) * math.Pow(2, shift); r <= maxBits {
+			return eval.Number(r)
+		}
+		return eval.ErrNum
+	})
+}
+
+// A unit is a unit of measurement for CONVERT. A value x in the unit is
+// x*factor+offset in the base unit of its kind; only temperatures have
+// an offset. Prefixable units take a metric prefix such as k or m, and
+// units of information also a binary prefix such as ki. The prefix
+// factor is raised to power for units of area and volume.
+type unit struct {
+	kind   string
+	factor float64
+	offset float64
+	prefix prefixable
+	power  int
+}
+
+type prefixable int
+
+const (
+	noPrefix prefixable = iota
+	metric
+	binary // metric or binary
+)
+
+var units = map[string]unit{}
+
+func init() {
+	add := func(kind string, factor float64, prefix prefixable, power int, names ...string) {
+		for _, name := range names {
+			units[name] = unit{kind: kind, factor: factor, prefix: prefix, power: power}
+		}
+	}
+	// mass, in grams
+	add("mass", 1, metric, 1, "g")
+	add("mass", 14593.902937206364, noPrefix, 1, "sg")
+	add("mass", 453.59237, noPrefix, 1, "lbm")
+	add("mass", 1.66053906660e-24, metric, 1, "u")
+	add("mass", 28.349523125, noPrefix, 1, "ozm")
+	add("mass", 0.06479891, noPrefix, 1, "grain")
+	add("mass", 45359.237, noPrefix, 1, "cwt", "shweight")
+	add("mass", 50802.34544, noPrefix, 1, "uk_cwt", "lcwt", "hweight")
+	add("mass", 6350.29318, noPrefix, 1, "stone")
+	add("mass", 907184.74, noPrefix, 1, "ton")
+	add("mass", 1016046.9088, noPrefix, 1, "uk_ton", "LTON", "brton")
+
+	// distance, in meters
+	add("distance", 1, metric, 1, "m")
+	add("distance", 1609.344, noPrefix, 1, "mi")
+	add("distance", 1852, noPrefix, 1, "Nmi")
+	add("distance", 0.0254, noPrefix, 1, "in")
+	add("distance", 0.3048, noPrefix, 1, "ft")
+	add("distance", 0.9144, noPrefix, 1, "yd")
+	add("distance", 1e-10, metric, 1, "ang")
+	add("distance", 1.143, noPrefix, 1, "ell")
+	add("distance", 9460730472580800, metric, 1, "ly")
+	add("distance", 3.0856775814913673e16, metric, 1, "parsec", "pc")
+	add("distance", 0.0254/72, noPrefix, 1, "Picapt", "Pica")
+	add("distance", 0.0254/6, noPrefix, 1, "pica")
+	add("distance", 5280*1200.0/3937, noPrefix, 1, "survey_mi")
+
+	// time, in seconds
+	add("time", 365.25*86400, metric, 1, "yr")
+	add("time", 86400, metric, 1, "day", "d")
+	add("time", 3600, metric, 1, "hr")
+	add("time", 60, metric, 1, "mn", "min")
+	add("time", 1, metric, 1, "sec", "s")
+
+	// pressure, in pascals
+	add("pressure", 1, metric, 1, "Pa", "p")
+	add("pressure", 101325, metric, 1, "atm", "at")
+	add("pressure", 133.322, metric, 1, "mmHg")
+	add("pressure", 6894.757293168361, noPrefix, 1, "psi")
+	add("pressure", 101325.0/760, noPrefix, 1, "Torr")
+
+	// force, in newtons
+	add("force", 1, metric, 1, "N")
+	add("force", 1e-5, metric, 1, "dyn", "dy")
+	add("force", 4.4482216152605, noPrefix, 1, "lbf")
+	add("force", 0.00980665, metric, 1, "pond")
+
+	// energy, in joules
+	add("energy", 1, metric, 1, "J")
+	add("energy", 1e-7, metric, 1, "e")
+	add("energy", 4.184, metric, 1, "c")
+	add("energy", 4.1868, metric, 1, "cal")
+	add("energy", 1.602176634e-19, metric, 1, "eV", "ev")
+	add("energy", 2684519.537696173, noPrefix, 1, "HPh", "hh")
+	add("energy", 3600, metric, 1, "Wh", "wh")
+	add("energy", 1.3558179483314004, noPrefix, 1, "flb")
+	add("energy", 1055.05585262, noPrefix, 1, "BTU", "btu")
+
+	// power, in watts
+	add("power", 745.6998715822702, noPrefix, 1, "HP", "h")
+	add("power", 735.49875, noPrefix, 1, "PS")
+	add("power", 1, metric, 1, "W", "w")
+
+	// magnetism, in teslas
+	add("magnetism", 1, metric, 1, "T")
+	add("magnetism", 1e-4, metric, 1, "ga")
+
+	// speed, in meters per second
+	add("speed", 1, metric, 1, "m/s", "m/sec")
+	add("speed", 1.0/3600, metric, 1, "m/h", "m/hr")
+	add("speed", 0.44704, noPrefix, 1, "mph")
+	add("speed", 1852.0/3600, noPrefix, 1, "kn")
+	add("speed", 1853.184/3600, noPrefix, 1, "admkn")
+
+	// information, in bits
+	add("information", 1, binary, 1, "bit")
+	add("information", 8, binary, 1, "byte")
+
+	// area, in square meters
+	add("area", 1, metric, 2, "m2", "m^2")
+	add("area", 1e4, noPrefix, 1, "ha")
+	add("area", 100, noPrefix, 1, "ar")
+	add("area", 4046.8564224, noPrefix, 1, "uk_acre")
+	add("area", 4046.872609874252, noPrefix, 1, "us_acre")
+	add("area", 0.09290304, noPrefix, 1, "ft2", "ft^2")
+	add("area", 0.00064516, noPrefix, 1, "in2", "in^2")
+	add("area", 0.83612736, noPrefix, 1, "yd2", "yd^2")
+	add("area", 2589988.110336, noPrefix, 1, "mi2", "mi^2")
+	add("area", 1852*1852, noPrefix, 1, "Nmi2", "Nmi^2")
+	add("area", 1e-20, metric, 2, "ang2", "ang^2")
+	add("area", 2500, noPrefix, 1, "Morgen")
+
+	// volume, in cubic meters
+	add("volume", 1, metric, 3, "m3", "m^3")
+	add("volume", 1e-3, metric, 1, "l", "L", "lt")
+	add("volume", 4.92892159375e-6, noPrefix, 1, "tsp")
+	add("volume", 5e-6, noPrefix, 1, "tspm")
+	add("volume", 14.78676478125e-6, noPrefix, 1, "tbs")
+	add("volume", 29.5735295625e-6, noPrefix, 1, "oz")
+	add("volume", 236.5882365e-6, noPrefix, 1, "cup")
+	add("volume", 473.176473e-6, noPrefix, 1, "pt", "us_pt")
+	add("volume", 568.26125e-6, noPrefix, 1, "uk_pt")
+	add("volume", 946.352946e-6, noPrefix, 1, "qt")
+	add("volume", 1136.5225e-6, noPrefix, 1, "uk_qt")
+	add("volume", 3.785411784e-3, noPrefix, 1, "gal")
+	add("volume", 4.54609e-3, noPrefix, 1, "uk_gal")
+	add("volume", 0.028316846592, noPrefix, 1, "ft3", "ft^3")
+	add("volume", 1.6387064e-5, noPrefix, 1, "in3", "in^3")
+	add("volume", 0.764554857984, noPrefix, 1, "yd3", "yd^3")
+	add("volume", 0.158987294928, noPrefix, 1, "barrel")
+	add("volume", 0.03523907016688, noPrefix, 1, "bushel")
+	add("volume", 1.13267386368, noPrefix, 1, "MTON")
+	add("volume", 2.8316846592, noPrefix, 1, "GRT", "regton")
+	add("volume", 1e-30, metric, 3, "ang3", "ang^3")
+
+	// temperature, in kelvins
+	add("temperature", 1, metric, 1, "K", "kel")
+	add("temperature", 5.0/9, noPrefix, 1, "Rank")
+	for name, u := range map[string]unit{
+		"C":    {factor: 1, offset: 273.15},
+		"cel":  {factor: 1, offset: 273.15},
+		"F":    {factor: 5.0 / 9, offset: 273.15 - 32*5.0/9},
+		"fah":  {factor: 5.0 / 9, offset: 273.15 - 32*5.0/9},
+		"Reau": {factor: 1.25, offset: 273.15},
+	} {
+		u.kind, u.power = "temperature", 1
+		units[name] = u
+	}
+}
+
+var metricPrefixes = map[string]float64{
+	"Y": 1e24, "Z": 1e21, "E": 1e18, "P": 1e15, "T": 1e12, "G": 1e9, "M": 1e6,
+	"k": 1e3, "h": 1e2, "da": 1e1, "e": 1e1, "d": 1e-1, "c": 1e-2, "m": 1e-3,
+	"u": 1e-6, "n": 1e-9, "p": 1e-12, "f": 1e-15, "a": 1e-18, "z": 1e-21, "y": 1e-24,
+}
+
+var binaryPrefixes = map[string]float64{
+	"ki": 1 << 10, "Mi": 1 << 20, "Gi": 1 << 30, "Ti": 1 << 40,
+	"Pi": 1 << 50, "Ei": 1 << 60, "Zi": 1 << 70, "Yi": 1 << 80,
+}
+
+// lookupUnit returns the unit named name, which may carry a prefix.
+func lookupUnit(name string) (unit, bool) {
+	if u, ok := units[name]; ok {
+		return u, true
+	}
+	for n := 1; n <= 2 && n < len(name); n++ {
+		p, rest := name[:n], name[n:]
+		u, ok := units[rest]
+		if !ok || u.prefix == noPrefix {
+			continue
+		}
+		f, ok := metricPrefixes[p]
+		if !ok && u.prefix == binary {
+			f, ok = binaryPrefixes[p]
+		}
+		if ok {
+			u.factor *= math.Pow(f, float64(u.power))
+			u.offset = 0
+			return u, true
+		}
+	}
+	return unit{}, false
+}
+
+// convert implements CONVERT. Units of different kinds give #N/A.
+func convert(e *eval.Evaluator, args []eval.Value) eval.Value {
+	x, err := e.Number(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	names, err := texts(e, args[1:], 2)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	from, ok := lookupUnit(names[0])
+	if !ok {
+		return eval.ErrNA
+	}
+	to, ok := lookupUnit(names[1])
+	if !ok || from.kind != to.kind {
+		return eval.ErrNA
+	}
+	return eval.NumberOrError((x*from.factor + from.offset - to.offset) / to.factor)
+}
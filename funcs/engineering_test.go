@@ -0,0 +1,83 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"testing"
+)
+
+func TestBaseConversion(t *testing.T) {
+	runTests(t, []evalTest{
+		{"DEC2BIN(9)", eval.Text("1001")},
+		{"DEC2BIN(9,8)", eval.Text("00001001")},
+		{"DEC2BIN(9,2)", eval.ErrNum},
+		{"DEC2BIN(-100)", eval.Text("1110011100")},
+		{"DEC2BIN(512)", eval.ErrNum},
+		{"DEC2OCT(-100)", eval.Text("7777777634")},
+		{"DEC2HEX(255)", eval.Text("FF")},
+		{"DEC2HEX(-54)", eval.Text("FFFFFFFFCA")},
+		{"DEC2HEX(64,0)", eval.ErrNum},
+		{`BIN2DEC("1100100")`, eval.Number(100)},
+		{"BIN2DEC(1111111111)", eval.Number(-1)},
+		{`BIN2DEC("12")`, eval.ErrNum},
+		{`BIN2HEX("11111011",4)`, eval.Text("00FB")},
+		{`BIN2OCT("1110")`, eval.Text("16")},
+		{`OCT2DEC("7777777533")`, eval.Number(-165)},
+		{`OCT2BIN("3",3)`, eval.Text("011")},
+		{`OCT2HEX("100")`, eval.Text("40")},
+		{`HEX2DEC("A5")`, eval.Number(165)},
+		{`HEX2DEC("FFFFFFFF5B")`, eval.Number(-165)},
+		{`HEX2DEC("10000000000")`, eval.ErrNum},
+		{`HEX2BIN("FFFFFFFE00")`, eval.Text("1000000000")},
+		{`HEX2BIN("200")`, eval.ErrNum},
+		{`HEX2OCT("FFFFFFFF00")`, eval.Text("7777777400")},
+		{"HEX2DEC(TRUE)", eval.ErrValue},
+		{`HEX2DEC("")`, eval.Number(0)},
+	})
+}
+
+func TestBaseLiterals(t *testing.T) {
+	runTests(t, []evalTest{
+		{`0xA5=HEX2DEC("A5")`, eval.Bool(true)},
+		{`0xFFFFFFFF5B=HEX2DEC("FFFFFFFF5B")`, eval.Bool(true)},
+		{`0b1111111111=BIN2DEC("1111111111")`, eval.Bool(true)},
+		{`0o7777777533=OCT2DEC("7777777533")`, eval.Bool(true)},
+	})
+}
+
+func TestBits(t *testing.T) {
+	runTests(t, []evalTest{
+		{"BITAND(13,25)", eval.Number(9)},
+		{"BITOR(23,10)", eval.Number(31)},
+		{"BITXOR(5,3)", eval.Number(6)},
+		{"BITAND(-1,1)", eval.ErrNum},
+		{"BITAND(1.5,1)", eval.ErrNum},
+		{"BITOR(2^48,1)", eval.ErrNum},
+		{"BITLSHIFT(4,2)", eval.Number(16)},
+		{"BITLSHIFT(4,-2)", eval.Number(1)},
+		{"BITLSHIFT(1,48)", eval.ErrNum},
+		{"BITRSHIFT(13,2)", eval.Number(3)},
+		{"BITRSHIFT(1,-47)", eval.Number(1 << 47)},
+		{"BITRSHIFT(1,54)", eval.ErrNum},
+	})
+}
+
+func TestConvert(t *testing.T) {
+	runTests(t, []evalTest{
+		{`CONVERT(1,"lbm","kg")`, eval.Number(0.45359237)},
+		{`CONVERT(68,"F","C")`, eval.Number(20)},
+		{`CONVERT(0,"C","K")`, eval.Number(273.15)},
+		{`CONVERT(100,"cel","fah")`, eval.Number(212)},
+		{`CONVERT(6,"tsp","tbs")`, eval.Number(2)},
+		{`CONVERT(100,"ft","m")`, eval.Number(30.48)},
+		{`CONVERT(1,"mi","km")`, eval.Number(1.609344)},
+		{`CONVERT(1,"hr","mn")`, eval.Number(60)},
+		{`CONVERT(1,"km2","m2")`, eval.Number(1e6)},
+		{`CONVERT(1,"l","cm3")`, eval.Number(1000)},
+		{`CONVERT(1,"kbyte","bit")`, eval.Number(8000)},
+		{`CONVERT(1,"kibyte","bit")`, eval.Number(8192)},
+		{`CONVERT(1,"atm","kPa")`, eval.Number(101.325)},
+		{`CONVERT(2.5,"ft","sec")`, eval.ErrNA},
+		{`CONVERT(1,"kmi","m")`, eval.ErrNA},
+		{`CONVERT(1,"LBM","kg")`, eval.ErrNA},
+	})
+}
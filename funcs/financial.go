@@ -0,0 +1,416 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/types"
+	"math"
+)
+
+// Financial holds the time value of money, cash flow and depreciation
+// functions. Money paid out is negative and money received positive.
+// The type argument is 0 for payments at the end of each period and 1
+// for payments at the beginning. RATE, IRR and XIRR solve for the rate
+// by Newton's method and return #NUM! if it does not converge.
+var Financial = []*eval.Func{
+	{Name: "PMT", Sig: fixed(types.Number, num("rate"), num("nper"), num("pv"), opt(num("fv")), opt(num("type"))), Call: tvm(pmtFunc)},
+	{Name: "IPMT", Sig: fixed(types.Number, num("rate"), num("per"), num("nper"), num("pv"), opt(num("fv")), opt(num("type"))), Call: ipmtFunc(false)},
+	{Name: "PPMT", Sig: fixed(types.Number, num("rate"), num("per"), num("nper"), num("pv"), opt(num("fv")), opt(num("type"))), Call: ipmtFunc(true)},
+	{Name: "PV", Sig: fixed(types.Number, num("rate"), num("nper"), num("pmt"), opt(num("fv")), opt(num("type"))), Call: tvm(pvFunc)},
+	{Name: "FV", Sig: fixed(types.Number, num("rate"), num("nper"), num("pmt"), opt(num("pv")), opt(num("type"))), Call: tvm(fvFunc)},
+	{Name: "NPER", Sig: fixed(types.Number, num("rate"), num("pmt"), num("pv"), opt(num("fv")), opt(num("type"))), Call: tvm(nperFunc)},
+	{Name: "RATE", Sig: fixed(types.Number, num("nper"), num("pmt"), num("pv"), opt(num("fv")), opt(num("type")), opt(num("guess"))), Call: rate},
+	{Name: "NPV", Sig: variadic(types.Number, num("rate"), num("value")), Call: npv},
+	{Name: "XNPV", Sig: fixed(types.Number, num("rate"), val("values"), val("dates")), Call: xnpv},
+	{Name: "IRR", Sig: fixed(types.Number, val("values"), opt(num("guess"))), Call: irr},
+	{Name: "XIRR", Sig: fixed(types.Number, val("values"), val("dates"), opt(num("guess"))), Call: xirr},
+	{Name: "MIRR", Sig: fixed(types.Number, val("values"), num("finance_rate"), num("reinvest_rate")), Call: mirr},
+	{Name: "SLN", Sig: fixed(types.Number, num("cost"), num("salvage"), num("life")), Call: sln},
+	{Name: "DB", Sig: fixed(types.Number, num("cost"), num("salvage"), num("life"), num("period"), opt(num("month"))), Call: db},
+	{Name: "DDB", Sig: fixed(types.Number, num("cost"), num("salvage"), num("life"), num("period"), opt(num("factor"))), Call: ddb},
+	{Name: "EFFECT", Sig: fixed(types.Number, num("nominal_rate"), num("npery")), Call: fn2(0, effect)},
+	{Name: "NOMINAL", Sig: fixed(types.Number, num("effect_rate"), num("npery")), Call: fn2(0, nominal)},
+}
+
+// tvm adapts the time value of money functions, which take five numbers
+// of which the last two default to 0. The payment type is 0 or 1.
+func tvm(f func(a, b, c, d float64, typ float64) float64) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		x, err := numArgs(e, args, 0, 0, 0, 0, 0)
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		return eval.NumberOrError(f(x[0], x[1], x[2], x[3], payType(x[4])))
+	}
+}
+
+func payType(x float64) float64 {
+	if x != 0 {
+		return 1
+	}
+	return 0
+}
+
+// annuity returns the future value factor ((1+r)^n - 1) / r of a series
+// of payments of 1.
+func annuity(r, n float64) float64 {
+	if r == 0 {
+		return n
+	}
+	return math.Expm1(n*math.Log1p(r)) / r
+}
+
+func pmtFunc(r, n, pv, fv, typ float64) float64 {
+	return -(fv + pv*math.Pow(1+r, n)) / ((1 + r*typ) * annuity(r, n))
+}
+
+func fvFunc(r, n, pmt, pv, typ float64) float64 {
+	return -(pv*math.Pow(1+r, n) + pmt*(1+r*typ)*annuity(r, n))
+}
+
+func pvFunc(r, n, pmt, fv, typ float64) float64 {
+	return -(fv + pmt*(1+r*typ)*annuity(r, n)) / math.Pow(1+r, n)
+}
+
+func nperFunc(r, pmt, pv, fv, typ float64) float64 {
+	if r == 0 {
+		return -(pv + fv) / pmt
+	}
+	p := pmt * (1 + r*typ)
+	return math.Log((p-fv*r)/(p+pv*r)) / math.Log1p(r)
+}
+
+// ipmtFunc adapts IPMT, the interest part of payment per, and PPMT, the
+// principal part.
+func ipmtFunc(principal bool) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		x, err := numArgs(e, args, 0, 0, 0, 0, 0, 0)
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		r, per, n, pv, fv, typ := x[0], x[1], x[2], x[3], x[4], payType(x[5])
+		if per < 1 || per > n {
+			return eval.ErrNum
+		}
+		pmt := pmtFunc(r, n, pv, fv, typ)
+		var balance float64
+		switch {
+		case per == 1 && typ == 1:
+			balance = 0
+		case per == 1:
+			balance = -pv
+		case typ == 1:
+			balance = fvFunc(r, per-2, pmt, pv, 1) - pmt
+		default:
+			balance = fvFunc(r, per-1, pmt, pv, 0)
+		}
+		if principal {
+			return eval.NumberOrError(pmt - balance*r)
+		}
+		return eval.NumberOrError(balance * r)
+	}
+}
+
+// newton finds a root of f, whose derivative is df, from guess. Rates
+// at or below -1 are rejected.
+func newton(f, df func(x float64) float64, guess float64) (float64, error) {
+	x := guess
+	for i := 0; i < 100; i++ {
+		d := df(x)
+		if d == 0 || math.IsNaN(d) {
+			break
+		}
+		step := f(x) / d
+		x -= step
+		if x <= -1 || math.IsNaN(x) || math.IsInf(x, 0) {
+			break
+		}
+		if math.Abs(step) <= 1e-12*math.Max(1, math.Abs(x)) {
+			return x, nil
+		}
+	}
+	return 0, eval.ErrNum
+}
+
+func rate(e *eval.Evaluator, args []eval.Value) eval.Value {
+	x, err := numArgs(e, args, 0, 0, 0, 0, 0, 0.1)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	n, pmt, pv, fv, typ := x[0], x[1], x[2], x[3], payType(x[4])
+	if n <= 0 {
+		return eval.ErrNum
+	}
+	f := func(r float64) float64 {
+		return pv*math.Pow(1+r, n) + pmt*(1+r*typ)*annuity(r, n) + fv
+	}
+	df := func(r float64) float64 {
+		g := n * math.Pow(1+r, n-1)
+		da := n * (n - 1) / 2
+		if math.Abs(r) > 1e-6 {
+			da = (g - annuity(r, n)) / r
+		}
+		return pv*g + pmt*(typ*annuity(r, n)+(1+r*typ)*da)
+	}
+	r, err := newton(f, df, x[5])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	return eval.Number(r)
+}
+
+// flows returns the numbers of a range of cash flows or dates, which
+// must all be numbers.
+func flows(e *eval.Evaluator, v eval.Value) ([]float64, error) {
+	var xs []float64
+	for _, v := range e.Values(v) {
+		switch x := v.(type) {
+		case eval.Number:
+			xs = append(xs, float64(x))
+		case eval.Error:
+			return nil, x
+		default:
+			return nil, eval.ErrValue
+		}
+	}
+	return xs, nil
+}
+
+// presentValue returns the value at time 0 of cash flows xs at times ts,
+// in periods, discounted at rate r, and its derivative with respect to r.
+func presentValue(r float64, xs, ts []float64) (v, dv float64) {
+	for i, x := range xs {
+		d := math.Pow(1+r, -ts[i])
+		v += x * d
+		dv -= ts[i] * x * d / (1 + r)
+	}
+	return v, dv
+}
+
+// periods returns the times 0, 1, ... n-1.
+func periods(n int) []float64 {
+	ts := make([]float64, n)
+	for i := range ts {
+		ts[i] = float64(i)
+	}
+	return ts
+}
+
+// npv discounts the values from the end of the first period.
+func npv(e *eval.Evaluator, args []eval.Value) eval.Value {
+	r, err := e.Number(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	xs, err := e.Numbers(args[1:])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	if r == -1 {
+		return eval.ErrDiv0
+	}
+	v, _ := presentValue(r, xs, periods(len(xs)))
+	return eval.NumberOrError(v / (1 + r))
+}
+
+// datedFlows returns the cash flows and their times in years of 365
+// days after the first date.
+func datedFlows(e *eval.Evaluator, values, dates eval.Value) ([]float64, []float64, error) {
+	xs, err := flows(e, values)
+	if err != nil {
+		return nil, nil, err
+	}
+	ds, err := flows(e, dates)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(xs) != len(ds) || len(xs) == 0 {
+		return nil, nil, eval.ErrNum
+	}
+	ts := make([]float64, len(ds))
+	for i, d := range ds {
+		if d < ds[0] {
+			return nil, nil, eval.ErrNum
+		}
+		ts[i] = (math.Trunc(d) - math.Trunc(ds[0])) / 365
+	}
+	return xs, ts, nil
+}
+
+func xnpv(e *eval.Evaluator, args []eval.Value) eval.Value {
+	r, err := e.Number(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	xs, ts, err := datedFlows(e, args[1], args[2])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	if r <= -1 {
+		return eval.ErrNum
+	}
+	v, _ := presentValue(r, xs, ts)
+	return eval.NumberOrError(v)
+}
+
+// solveFlows finds the rate at which the cash flows xs at times ts have
+// a present value of 0. There must be both a payment and a receipt.
+func solveFlows(xs, ts []float64, guess float64) eval.Value {
+	pos, neg := false, false
+	for _, x := range xs {
+		pos = pos || x > 0
+		neg = neg || x < 0
+	}
+	if !pos || !neg {
+		return eval.ErrNum
+	}
+	r, err := newton(func(r float64) float64 {
+		v, _ := presentValue(r, xs, ts)
+		return v
+	}, func(r float64) float64 {
+		_, dv := presentValue(r, xs, ts)
+		return dv
+	}, guess)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	return eval.Number(r)
+}
+
+func irr(e *eval.Evaluator, args []eval.Value) eval.Value {
+	xs, err := e.Numbers(args[:1])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	guess, err := optNumber(e, args, 1, 0.1)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	return solveFlows(xs, periods(len(xs)), guess)
+}
+
+func xirr(e *eval.Evaluator, args []eval.Value) eval.Value {
+	xs, ts, err := datedFlows(e, args[0], args[1])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	guess, err := optNumber(e, args, 2, 0.1)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	return solveFlows(xs, ts, guess)
+}
+
+// mirr compounds the receipts to the last period at the reinvestment
+// rate and discounts the payments to the first at the finance rate.
+func mirr(e *eval.Evaluator, args []eval.Value) eval.Value {
+	xs, err := e.Numbers(args[:1])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	x, err := numArgs(e, args[1:], 0, 0)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	fr, rr := x[0], x[1]
+	n := float64(len(xs))
+	var pos, neg float64
+	for i, x := range xs {
+		if x > 0 {
+			pos += x * math.Pow(1+rr, n-1-float64(i))
+		} else {
+			neg += x / math.Pow(1+fr, float64(i))
+		}
+	}
+	if pos == 0 || neg == 0 || n < 2 {
+		return eval.ErrDiv0
+	}
+	return eval.NumberOrError(math.Pow(-pos/neg, 1/(n-1)) - 1)
+}
+
+func sln(e *eval.Evaluator, args []eval.Value) eval.Value {
+	x, err := numArgs(e, args, 0, 0, 0)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	if x[2] == 0 {
+		return eval.ErrDiv0
+	}
+	return eval.Number((x[0] - x[1]) / x[2])
+}
+
+// db implements fixed-declining balance depreciation. The rate is
+// rounded to three places; the first year has month months and the
+// year after the life the remaining 12-month.
+func db(e *eval.Evaluator, args []eval.Value) eval.Value {
+	x, err := numArgs(e, args, 0, 0, 0, 0, 12)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	cost, salvage, life, period, month := x[0], x[1], x[2], math.Trunc(x[3]), math.Trunc(x[4])
+	if cost < 0 || salvage < 0 || life <= 0 || period < 1 || month < 1 || month > 12 ||
+		period > life+1 || month == 12 && period > life {
+		return eval.ErrNum
+	}
+	if cost == 0 {
+		return eval.Number(0)
+	}
+	r := math.Round((1-math.Pow(salvage/cost, 1/life))*1000) / 1000
+	total, d := 0.0, 0.0
+	for p := 1.0; p <= period; p++ {
+		switch {
+		case p == 1:
+			d = cost * r * month / 12
+		case p == life+1:
+			d = (cost - total) * r * (12 - month) / 12
+		default:
+			d = (cost - total) * r
+		}
+		total += d
+	}
+	return eval.NumberOrError(d)
+}
+
+// ddb implements double-declining balance depreciation, or declining
+// at factor times the straight line rate, never below the salvage.
+func ddb(e *eval.Evaluator, args []eval.Value) eval.Value {
+	x, err := numArgs(e, args, 0, 0, 0, 0, 2)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	cost, salvage, life, period, factor := x[0], x[1], x[2], x[3], x[4]
+	if cost < 0 || salvage < 0 || life <= 0 || period <= 0 || period > life || factor <= 0 {
+		return eval.ErrNum
+	}
+	r := factor / life
+	var before float64
+	if r >= 1 {
+		r = 1
+		if period == 1 {
+			before = cost
+		}
+	} else {
+		before = cost * math.Pow(1-r, period-1)
+	}
+	after := cost * math.Pow(1-r, period)
+	d := before - after
+	if after < salvage {
+		d = before - salvage
+	}
+	return eval.Number(math.Max(d, 0))
+}
+
+func effect(nominal, npery float64) eval.Value {
+	npery = math.Trunc(npery)
+	if nominal <= 0 || npery < 1 {
+		return eval.ErrNum
+	}
+	return eval.NumberOrError(math.Pow(1+nominal/npery, npery) - 1)
+}
+
+func nominal(effect, npery float64) eval.Value {
+	npery = math.Trunc(npery)
+	if effect <= 0 || npery < 1 {
+		return eval.ErrNum
+	}
+	return eval.NumberOrError(npery * (math.Pow(1+effect, 1/npery) - 1))
+}
@@ -0,0 +1,65 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"testing"
+)
+
+func TestTimeValue(t *testing.T) {
+	runTests(t, []evalTest{
+		{"PMT(0.08/12,10,10000)", eval.Number(-1037.0320893591636)},
+		{"PMT(0.08/12,10,10000,0,1)", eval.Number(-1030.1643271779772)},
+		{"PMT(0,10,1000)", eval.Number(-100)},
+		{"PMT(0,0,1000)", eval.ErrNum},
+		{"FV(0.06/12,10,-200,-500,1)", eval.Number(2581.4033740601362)},
+		{"FV(0,10,-100)", eval.Number(1000)},
+		{"PV(0.08/12,240,500)", eval.Number(-59777.14585118777)},
+		{"IPMT(0.1/12,1,36,8000)", eval.Number(-66.66666666666667)},
+		{"IPMT(0.1,3,3,8000)", eval.Number(-292.4471299093658)},
+		{"IPMT(0.1,4,3,8000)", eval.ErrNum},
+		{"PPMT(0.1/12,1,24,2000)", eval.Number(-75.62318600836664)},
+		{"NPER(0.01,-100,-1000,10000,1)", eval.Number(59.67386567429457)},
+		{"NPER(0,-100,1000)", eval.Number(10)},
+		{"NPER(0.1,-100,1000)", eval.ErrNum},
+		{"RATE(48,-200,8000)", eval.Number(0.007701472488201888)},
+		{"RATE(10,-100,1000)", eval.Number(0)},
+		{"RATE(10,100,1000)", eval.ErrNum},
+		{"EFFECT(0.0525,4)", eval.Number(0.05354266737075819)},
+		{"NOMINAL(0.053543,4)", eval.Number(0.052500319868356016)},
+		{"EFFECT(0.05,0)", eval.ErrNum},
+	})
+}
+
+func TestCashFlows(t *testing.T) {
+	runTests(t, []evalTest{
+		{"NPV(0.1,-10000,3000,4200,6800)", eval.Number(1188.4434123352216)},
+		{"IRR(K1:K5)", eval.Number(-0.021244848273410943)},
+		{"IRR(K1:K6)", eval.Number(0.0866309480365316)},
+		{"IRR(K2:K6)", eval.ErrNum},
+		{"XNPV(0.09,M1:M5,L1:L5)", eval.Number(2086.647602031535)},
+		{"XNPV(0.09,M1:M5,L1:L4)", eval.ErrNum},
+		{"XIRR(M1:M5,L1:L5)", eval.Number(0.3733625335188314)},
+		{"XIRR(M1:M5,L1:L5,0.5)", eval.Number(0.3733625335188314)},
+		{"MIRR(K1:K6,0.1,0.12)", eval.Number(0.09866910733715017)},
+		{"MIRR(K2:K6,0.1,0.12)", eval.ErrDiv0},
+	})
+}
+
+func TestDepreciation(t *testing.T) {
+	runTests(t, []evalTest{
+		{"SLN(30000,7500,10)", eval.Number(2250)},
+		{"SLN(30000,7500,0)", eval.ErrDiv0},
+		{"DB(1000000,100000,6,1,7)", eval.Number(186083.33333333334)},
+		{"DB(1000000,100000,6,2,7)", eval.Number(259639.41666666666)},
+		{"DB(1000000,100000,6,3,7)", eval.Number(176814.44275000002)},
+		{"DB(1000000,100000,6,6,7)", eval.Number(55841.75673602846)},
+		{"DB(1000000,100000,6,7,7)", eval.Number(15845.098473848071)},
+		{"DB(1000000,100000,6,7)", eval.ErrNum},
+		{"DDB(2400,300,3650,1)", eval.Number(1.3150684931506476)},
+		{"DDB(2400,300,120,1)", eval.Number(40)},
+		{"DDB(2400,300,10,1)", eval.Number(480)},
+		{"DDB(2400,300,10,2,1.5)", eval.Number(306)},
+		{"DDB(2400,300,10,10)", eval.Number(22.122547200000156)},
+		{"DDB(2400,300,10,11)", eval.ErrNum},
+	})
+}
@@ -0,0 +1,120 @@
+// Package funcs implements the built-in spreadsheet functions. Functions
+// are grouped by category; each group is a slice of eval.Func that can be
+// registered on its own.
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/types"
+)
+
+// Register adds all built-in functions to r.
+func Register(r *eval.Registry) {
+	r.Register(Math...)
+	r.Register(Logical...)
+	r.Register(Text...)
+	r.Register(Date...)
+	r.Register(Lookup...)
+	r.Register(Stats...)
+	r.Register(Financial...)
+	r.Register(Complex...)
+	r.Register(Engineering...)
+	r.Register(Array...)
+	r.Register(Lambda...)
+}
+
+// Default returns a new registry holding all built-in functions.
+func Default() *eval.Registry {
+	r := eval.NewRegistry()
+	Register(r)
+	return r
+}
+
+func param(name string, t types.Type) types.Param {
+	return types.Param{Name: name, Type: t}
+}
+
+func num(name string) types.Param  { return param(name, types.Number) }
+func text(name string) types.Param { return param(name, types.Text) }
+func val(name string) types.Param  { return param(name, types.Any) }
+
+func opt(p types.Param) types.Param {
+	p.Optional = true
+	return p
+}
+
+func lazy(p types.Param) types.Param {
+	p.Lazy = true
+	return p
+}
+
+func fixed(result types.Type, params ...types.Param) types.Signature {
+	return types.Signature{Params: params, Result: result}
+}
+
+func variadic(result types.Type, params ...types.Param) types.Signature {
+	return types.Signature{Params: params, Variadic: true, Result: result}
+}
+
+// optNumber returns argument i as a number, or def if it was omitted.
+func optNumber(e *eval.Evaluator, args []eval.Value, i int, def float64) (float64, error) {
+	if i >= len(args) {
+		return def, nil
+	}
+	if _, ok := args[i].(eval.Blank); ok {
+		return def, nil
+	}
+	return e.Number(args[i])
+}
+
+// numArgs converts args to numbers. defs holds a default for every
+// parameter, used when an optional argument is omitted or blank.
+func numArgs(e *eval.Evaluator, args []eval.Value, defs ...float64) ([]float64, error) {
+	x := make([]float64, len(defs))
+	for i, def := range defs {
+		var err error
+		if x[i], err = optNumber(e, args, i, def); err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+// fn1 adapts a function of one number.
+func fn1(f func(x float64) eval.Value) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		x, err := e.Number(args[0])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		return f(x)
+	}
+}
+
+// fn2 adapts a function of two numbers; def is used if the second
+// argument is optional and omitted.
+func fn2(def float64, f func(x, y float64) eval.Value) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		x, err := e.Number(args[0])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		y, err := optNumber(e, args, 1, def)
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		return f(x, y)
+	}
+}
+
+// aggregate adapts a function of the numbers collected from all
+// arguments by eval.Evaluator.Numbers.
+func aggregate(f func(xs []float64) eval.Value) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		xs, err := e.Numbers(args)
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		return f(xs)
+	}
+}
@@ -0,0 +1,208 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/ast"
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/types"
+	"strings"
+)
+
+// Lambda holds LET, LAMBDA and the functions that apply a lambda to the
+// elements, rows or columns of arrays.
+var Lambda = []*eval.Func{
+	{Name: "LET", Sig: variadic(types.Any, lazy(val("name")), lazy(val("value")), lazy(val("calculation"))), Call: let},
+	{Name: "LAMBDA", Sig: variadic(types.Any, lazy(val("parameter_or_calculation"))), Call: lambda},
+	{Name: "MAP", Sig: variadic(types.Array, val("array"), val("lambda")), Call: mapFunc},
+	{Name: "REDUCE", Sig: fixed(types.Any, val("initial_value"), val("array"), val("lambda")), Call: reduce},
+	{Name: "SCAN", Sig: fixed(types.Array, val("initial_value"), val("array"), val("lambda")), Call: scan},
+	{Name: "BYROW", Sig: fixed(types.Array, val("array"), val("lambda")), Call: byLine(false)},
+	{Name: "BYCOL", Sig: fixed(types.Array, val("array"), val("lambda")), Call: byLine(true)},
+	{Name: "MAKEARRAY", Sig: fixed(types.Array, num("rows"), num("columns"), val("lambda")), Call: makeArray},
+}
+
+// expr returns the expression of a lazy argument.
+func expr(v eval.Value) ast.Expr {
+	return v.(*eval.Thunk).X
+}
+
+// name returns the name given by a lazy argument, which must be a plain
+// identifier.
+func name(v eval.Value) (string, bool) {
+	id, ok := expr(v).(*ast.Ident)
+	if !ok {
+		return "", false
+	}
+	return id.Name, true
+}
+
+// let binds each name to its value in turn, so that later values and
+// the calculation see the earlier names. Values are evaluated on first
+// use.
+func let(e *eval.Evaluator, args []eval.Value) eval.Value {
+	if len(args)%2 == 0 {
+		return eval.ErrValue
+	}
+	s := e
+	for i := 0; i+1 < len(args); i += 2 {
+		n, ok := name(args[i])
+		if !ok {
+			return eval.ErrValue
+		}
+		s = s.Bind(n, s.Lazy(expr(args[i+1])))
+	}
+	return s.Lazy(expr(args[len(args)-1])).Value()
+}
+
+func lambda(e *eval.Evaluator, args []eval.Value) eval.Value {
+	params := make([]string, len(args)-1)
+	for i := range params {
+		n, ok := name(args[i])
+		if !ok {
+			return eval.ErrValue
+		}
+		for _, p := range params[:i] {
+			if strings.EqualFold(p, n) {
+				return eval.ErrValue
+			}
+		}
+		params[i] = n
+	}
+	return e.NewLambda(params, expr(args[len(args)-1]))
+}
+
+// lambdaArg returns v as a lambda of n parameters.
+func lambdaArg(v eval.Value, n int) (*eval.Lambda, error) {
+	switch x := eval.Force(v).(type) {
+	case *eval.Lambda:
+		if len(x.Params) != n {
+			return nil, eval.ErrValue
+		}
+		return x, nil
+	case eval.Error:
+		return nil, x
+	}
+	return nil, eval.ErrValue
+}
+
+// apply calls l for an element of an array result, which holds single
+// values only: a result of more than one value is #CALC!.
+func apply(e *eval.Evaluator, l *eval.Lambda, args ...eval.Value) eval.Value {
+	v := l.Call(args)
+	if a, ok := e.Array(v); ok && len(a.Values) > 1 {
+		return eval.ErrCalc
+	}
+	v = e.Deref(v)
+	if _, ok := v.(*eval.Lambda); ok {
+		return eval.ErrCalc
+	}
+	return v
+}
+
+// mapFunc calls the lambda with the elements of the arrays in the same
+// position, stretching them to the same size like an operator does.
+func mapFunc(e *eval.Evaluator, args []eval.Value) eval.Value {
+	n := len(args) - 1
+	l, err := lambdaArg(args[n], n)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	arrs := make([]eval.Value, n)
+	for i := range arrs {
+		a, err := arrayArg(e, args[i])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		arrs[i] = a
+	}
+	return eval.Broadcast(arrs, func(v []eval.Value) eval.Value { return apply(e, l, v...) })
+}
+
+// foldArgs converts the array and lambda arguments of REDUCE and SCAN,
+// which call the lambda with an accumulator and each element in turn.
+func foldArgs(e *eval.Evaluator, args []eval.Value) (*eval.Array, *eval.Lambda, error) {
+	a, err := arrayArg(e, args[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	l, err := lambdaArg(args[2], 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, l, nil
+}
+
+func reduce(e *eval.Evaluator, args []eval.Value) eval.Value {
+	a, l, err := foldArgs(e, args)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	acc := args[0]
+	for _, v := range a.Values {
+		acc = l.Call([]eval.Value{acc, v})
+	}
+	return acc
+}
+
+// scan returns the accumulator after each call.
+func scan(e *eval.Evaluator, args []eval.Value) eval.Value {
+	a, l, err := foldArgs(e, args)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	r := eval.NewArray(a.Rows, a.Cols)
+	acc := args[0]
+	for i, v := range a.Values {
+		acc = apply(e, l, acc, v)
+		r.Values[i] = acc
+	}
+	return r
+}
+
+// byLine calls the lambda with each row of an array, or each column if
+// byCol is set, and returns the results as a column or row.
+func byLine(byCol bool) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		a, err := arrayArg(e, args[0])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		l, err := lambdaArg(args[1], 1)
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		if byCol {
+			r := eval.NewArray(1, a.Cols)
+			for j := range r.Values {
+				r.Values[j] = apply(e, l, a.Slice(0, j, a.Rows, 1))
+			}
+			return r
+		}
+		r := eval.NewArray(a.Rows, 1)
+		for i := range r.Values {
+			r.Values[i] = apply(e, l, a.Slice(i, 0, 1, a.Cols))
+		}
+		return r
+	}
+}
+
+func makeArray(e *eval.Evaluator, args []eval.Value) eval.Value {
+	x, err := numArgs(e, args[:2], 0, 0)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	rows, cols, err := arraySize(x[0], x[1])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	l, err := lambdaArg(args[2], 2)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	a := eval.NewArray(rows, cols)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			a.Set(i, j, apply(e, l, eval.Number(i+1), eval.Number(j+1)))
+		}
+	}
+	return a
+}
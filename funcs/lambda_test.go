@@ -0,0 +1,46 @@
+package funcs
+
+import "testing"
+
+func TestLambda(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"LET(x,A1*2,y,x+1,x*y)", "6"},
+		{"LET(x,1,x,x+1,x)", "2"},
+		{"LET(x,1,LET(x,10,x)+x)", "11"},
+		{"LET(A,5,A+A1)", "6"},
+		{"LET(r,F1:F4,SUM(r)/ROWS(r))", "25"},
+		{"LET(x,1)", "#VALUE!"},
+		{"LET(1,2,3)", "#VALUE!"},
+		{"LAMBDA(a,b,a+b)(1,2)", "3"},
+		{"LAMBDA(a,b,a+b)(1)", "#VALUE!"},
+		{"LAMBDA(a,a,a)(1,2)", "#VALUE!"},
+		{"LAMBDA(x,x*2)", "#CALC!"},
+		{"LET(f,LAMBDA(x,x*x),f(3)+f(4))", "25"},
+		{"LET(n,10,f,LAMBDA(x,x+n),n,1,f(n))", "11"},
+		{"LET(sum,LAMBDA(x,x+1),sum(1))", "2"},
+		{"LET(x,5,(LAMBDA(y,x*y))(2))", "10"},
+		{"x", "#NAME?"},
+		{"1(2)", "#VALUE!"},
+		{"MAP({1,2;3,4},LAMBDA(x,x*10))", "{10,20;30,40}"},
+		{"MAP({1,2},{10;20},LAMBDA(a,b,a+b))", "{11,12;21,22}"},
+		{"MAP(F1:F2,LAMBDA(x,{1,2}))", "{#CALC!;#CALC!}"},
+		{"MAP({1,2},LAMBDA(a,b,a))", "#VALUE!"},
+		{"REDUCE(0,F1:F4,LAMBDA(acc,v,acc+v))", "100"},
+		{`REDUCE("",{"a","b","c"},LAMBDA(s,c,c&s))`, "cba"},
+		{"SCAN(1,{1,2,3,4},LAMBDA(acc,v,acc*v))", "{1,2,6,24}"},
+		{"BYROW({1,2;3,4},LAMBDA(r,SUM(r)))", "{3;7}"},
+		{"BYCOL({1,2;3,4},LAMBDA(c,MAX(c)))", "{3,4}"},
+		{"BYROW({1,2;3,4},LAMBDA(r,r))", "{#CALC!;#CALC!}"},
+		{"MAKEARRAY(2,3,LAMBDA(r,c,r*c))", "{1,2,3;2,4,6}"},
+		{"MAKEARRAY(0,3,LAMBDA(r,c,r))", "#CALC!"},
+		{"MAKEARRAY(2,2,1)", "#VALUE!"},
+	}
+	for _, test := range tests {
+		if got := evalString(t, test.src); got.String() != test.want {
+			t.Errorf("Eval(%q) = %v want %v", test.src, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/types"
+)
+
+// Logical holds the logical and conditional functions. The conditional
+// functions take their branches as lazy parameters and evaluate only the
+// branch selected, so IF(B1=0,0,A1/B1) never divides by zero. AND, OR
+// and XOR evaluate all of their arguments as spreadsheets do.
+var Logical = []*eval.Func{
+	{Name: "TRUE", Sig: fixed(types.Logical), Call: constant(eval.Bool(true))},
+	{Name: "FALSE", Sig: fixed(types.Logical), Call: constant(eval.Bool(false))},
+	{Name: "IF", Sig: fixed(types.Any, param("condition", types.Logical), lazy(val("value_if_true")), opt(lazy(val("value_if_false")))), Call: ifFunc},
+	{Name: "IFS", Sig: variadic(types.Any, lazy(val("condition_value"))), Call: ifs},
+	{Name: "IFERROR", Sig: fixed(types.Any, val("value"), lazy(val("value_if_error"))), Call: ifError(false)},
+	{Name: "IFNA", Sig: fixed(types.Any, val("value"), lazy(val("value_if_na"))), Call: ifError(true)},
+	{Name: "SWITCH", Sig: variadic(types.Any, val("expression"), lazy(val("value_result"))), Call: switchFunc},
+	{Name: "CHOOSE", Sig: variadic(types.Any, num("index"), lazy(val("value"))), Call: choose},
+	{Name: "AND", Sig: variadic(types.Logical, param("logical", types.Logical)), Call: logical(func(n, t int) bool { return t == n })},
+	{Name: "OR", Sig: variadic(types.Logical, param("logical", types.Logical)), Call: logical(func(n, t int) bool { return t > 0 })},
+	{Name: "XOR", Sig: variadic(types.Logical, param("logical", types.Logical)), Call: logical(func(n, t int) bool { return t%2 == 1 })},
+	{Name: "NOT", Sig: fixed(types.Logical, param("logical", types.Logical)), Call: not},
+}
+
+func constant(v eval.Value) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(*eval.Evaluator, []eval.Value) eval.Value { return v }
+}
+
+func ifFunc(e *eval.Evaluator, args []eval.Value) eval.Value {
+	cond, err := e.Bool(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	if cond {
+		return eval.Force(args[1])
+	}
+	if len(args) > 2 {
+		return eval.Force(args[2])
+	}
+	return eval.Bool(false)
+}
+
+func ifs(e *eval.Evaluator, args []eval.Value) eval.Value {
+	if len(args)%2 != 0 {
+		return eval.ErrValue
+	}
+	for i := 0; i < len(args); i += 2 {
+		cond, err := e.Bool(args[i])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		if cond {
+			return eval.Force(args[i+1])
+		}
+	}
+	return eval.ErrNA
+}
+
+// ifError returns the first argument unless it is an error, or #N/A only
+// if na is set, in which case the second argument is evaluated.
+func ifError(na bool) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		v := args[0]
+		if err, ok := e.Deref(v).(eval.Error); ok && (!na || err == eval.ErrNA) {
+			return eval.Force(args[1])
+		}
+		return v
+	}
+}
+
+func switchFunc(e *eval.Evaluator, args []eval.Value) eval.Value {
+	x := e.Deref(args[0])
+	if eval.IsError(x) {
+		return x
+	}
+	cases := args[1:]
+	for i := 0; i+1 < len(cases); i += 2 {
+		v := e.Deref(cases[i])
+		if eval.IsError(v) {
+			return v
+		}
+		if eval.Compare(x, v) == 0 && sameKind(x, v) {
+			return eval.Force(cases[i+1])
+		}
+	}
+	if len(cases)%2 == 1 {
+		return eval.Force(cases[len(cases)-1])
+	}
+	return eval.ErrNA
+}
+
+// sameKind reports whether x and y are both numbers, both text or both
+// logical values; blanks match anything.
+func sameKind(x, y eval.Value) bool {
+	switch x.(type) {
+	case eval.Blank:
+		return true
+	case eval.Number:
+		_, ok := y.(eval.Number)
+		return ok
+	case eval.Text:
+		_, ok := y.(eval.Text)
+		return ok
+	case eval.Bool:
+		_, ok := y.(eval.Bool)
+		return ok
+	}
+	_, ok := y.(eval.Blank)
+	return ok
+}
+
+func choose(e *eval.Evaluator, args []eval.Value) eval.Value {
+	f, err := e.Number(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	i := int(f)
+	if i < 1 || i >= len(args) {
+		return eval.ErrValue
+	}
+	return eval.Force(args[i])
+}
+
+// logical adapts AND, OR and XOR. Inside references text and blanks are
+// skipped; if no logical values remain the result is #VALUE!. The
+// result is computed from the number of values n and true values t.
+func logical(f func(n, t int) bool) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		n, t := 0, 0
+		for _, a := range args {
+			a = eval.Force(a)
+			_, isRef := a.(*eval.Ref)
+			for _, v := range e.Values(a) {
+				switch v.(type) {
+				case eval.Text, eval.Blank:
+					if isRef {
+						continue
+					}
+				}
+				b, err := eval.ToBool(v)
+				if err != nil {
+					return eval.ErrorValue(err)
+				}
+				n++
+				if b {
+					t++
+				}
+			}
+		}
+		if n == 0 {
+			return eval.ErrValue
+		}
+		return eval.Bool(f(n, t))
+	}
+}
+
+func not(e *eval.Evaluator, args []eval.Value) eval.Value {
+	b, err := e.Bool(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	return eval.Bool(!b)
+}
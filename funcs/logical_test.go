@@ -0,0 +1,68 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/parser"
+	"github.com/ajz01/calc/types"
+	"testing"
+)
+
+func TestLogical(t *testing.T) {
+	runTests(t, []evalTest{
+		{"TRUE", eval.Bool(true)},
+		{"IF(A1=1,\"one\",\"other\")", eval.Text("one")},
+		{"IF(A1=0,1)", eval.Bool(false)},
+		{"IF(D1=0,0,A1/D1)", eval.Number(0)},
+		{"IF(B1,1,2)", eval.ErrDiv0},
+		{"SUM(IF(TRUE,A1:A2,A5))", eval.Number(3)},
+		{"IFS(A1>1,1,A1>0,2)", eval.Number(2)},
+		{"IFS(A1>1,1)", eval.ErrNA},
+		{"IFERROR(1/0,\"div\")", eval.Text("div")},
+		{"IFERROR(A1,1/0)", eval.Number(1)},
+		{"IFNA(B1,0)", eval.ErrDiv0},
+		{"IFNA(SWITCH(9,1,2),0)", eval.Number(0)},
+		{"SWITCH(A2,1,\"a\",2,\"b\")", eval.Text("b")},
+		{"SWITCH(A3,3,\"num\",\"text\")", eval.Text("text")},
+		{"CHOOSE(2,1/0,A5,1/0)", eval.Number(4)},
+		{"CHOOSE(4,1,2)", eval.ErrValue},
+		{"AND(A1:A5)", eval.Bool(true)},
+		{"AND(TRUE,0)", eval.Bool(false)},
+		{"AND(FALSE,1/0)", eval.ErrDiv0},
+		{"AND(D1:D3)", eval.ErrValue},
+		{"OR(FALSE,A4)", eval.Bool(true)},
+		{"XOR(TRUE,TRUE,TRUE)", eval.Bool(true)},
+		{"NOT(A1)", eval.Bool(false)},
+		{`NOT("x")`, eval.ErrValue},
+	})
+}
+
+func TestLazyArguments(t *testing.T) {
+	calls := 0
+	r := Default()
+	r.Register(&eval.Func{
+		Name: "TOUCH",
+		Sig:  fixed(types.Number),
+		Call: func(*eval.Evaluator, []eval.Value) eval.Value {
+			calls++
+			return eval.Number(calls)
+		},
+	})
+	for _, src := range []string{
+		"IF(TRUE,1,TOUCH())",
+		"IFS(FALSE,TOUCH(),TRUE,2)",
+		"IFERROR(1,TOUCH())",
+		"SWITCH(1,2,TOUCH(),1,3,TOUCH())",
+		"CHOOSE(1,0,TOUCH())",
+	} {
+		x, err := parser.ParseBytes([]byte(src))
+		if err != nil {
+			t.Fatalf("ParseBytes(%q) %v", src, err)
+		}
+		e := &eval.Evaluator{Funcs: r}
+		e.Eval(x)
+		if calls != 0 {
+			t.Errorf("Eval(%q) evaluated a branch not taken", src)
+			calls = 0
+		}
+	}
+}
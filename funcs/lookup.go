@@ -0,0 +1,657 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/ref"
+	"github.com/ajz01/calc/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Lookup holds the lookup and reference functions. INDEX, OFFSET and
+// INDIRECT return references, which the evaluator resolves through its
+// Context only when their values are needed. CHOOSE is with the logical
+// functions since it evaluates its arguments lazily.
+var Lookup = []*eval.Func{
+	{Name: "VLOOKUP", Sig: fixed(types.Any, val("lookup_value"), val("table_array"), num("col_index_num"), opt(param("range_lookup", types.Logical))), Call: vhlookup(false)},
+	{Name: "HLOOKUP", Sig: fixed(types.Any, val("lookup_value"), val("table_array"), num("row_index_num"), opt(param("range_lookup", types.Logical))), Call: vhlookup(true)},
+	{Name: "LOOKUP", Sig: fixed(types.Any, val("lookup_value"), val("lookup_vector"), opt(val("result_vector"))), Call: lookupFunc},
+	{Name: "MATCH", Sig: fixed(types.Number, val("lookup_value"), val("lookup_array"), opt(num("match_type"))), Call: match},
+	{Name: "XMATCH", Sig: fixed(types.Number, val("lookup_value"), val("lookup_array"), opt(num("match_mode")), opt(num("search_mode"))), Call: xmatch},
+	{Name: "XLOOKUP", Sig: fixed(types.Any, val("lookup_value"), val("lookup_array"), val("return_array"), opt(lazy(val("if_not_found"))), opt(num("match_mode")), opt(num("search_mode"))), Call: xlookup},
+	{Name: "INDEX", Sig: fixed(types.Any, val("array"), num("row_num"), opt(num("column_num")), opt(num("area_num"))), Call: index},
+	{Name: "OFFSET", Sig: fixed(types.Reference, param("reference", types.Reference), num("rows"), num("cols"), opt(num("height")), opt(num("width"))), Call: offset, Serial: true, Volatile: true},
+	{Name: "INDIRECT", Sig: fixed(types.Reference, text("ref_text"), opt(param("a1", types.Logical))), Call: indirect, Serial: true, Volatile: true},
+	{Name: "ROW", Sig: fixed(types.Number, opt(param("reference", types.Reference))), Call: rowCol(false)},
+	{Name: "COLUMN", Sig: fixed(types.Number, opt(param("reference", types.Reference))), Call: rowCol(true)},
+	{Name: "ROWS", Sig: fixed(types.Number, val("array")), Call: rowsCols(false)},
+	{Name: "COLUMNS", Sig: fixed(types.Number, val("array")), Call: rowsCols(true)},
+	{Name: "CELL", Sig: fixed(types.Any, text("info_type"), opt(param("reference", types.Reference))), Call: cellInfo, Volatile: true},
+	{Name: "ADDRESS", Sig: fixed(types.Text, num("row_num"), num("column_num"), opt(num("abs_num")), opt(param("a1", types.Logical)), opt(text("sheet_text"))), Call: address},
+}
+
+// table gives access by position to the cells of a reference or the
+// elements of an array, or to a single value as a table of one cell.
+// Cells are read on demand so that a binary search of a whole column
+// reads only the cells it compares.
+type table struct {
+	e          *eval.Evaluator
+	ref        *eval.Ref   // nil for an array or a single value
+	arr        *eval.Array // nil for a reference or a single value
+	v          eval.Value
+	rows, cols int
+}
+
+func newTable(e *eval.Evaluator, v eval.Value) (*table, error) {
+	v = eval.Force(v)
+	switch x := v.(type) {
+	case *eval.Ref:
+		return &table{e: e, ref: x, rows: x.Range.Rows(), cols: x.Range.Cols()}, nil
+	case *eval.Array:
+		return &table{e: e, arr: x, rows: x.Rows, cols: x.Cols}, nil
+	case eval.Error:
+		return nil, x
+	}
+	return &table{e: e, v: v, rows: 1, cols: 1}, nil
+}
+
+// sub returns the part of the table of the given size with its top left
+// cell at row i and column j, counted from 0.
+func (t *table) sub(i, j, rows, cols int) eval.Value {
+	if t.arr != nil {
+		if rows == 1 && cols == 1 {
+			return t.arr.At(i, j)
+		}
+		return t.arr.Slice(i, j, rows, cols)
+	}
+	if t.ref == nil {
+		return t.v
+	}
+	from := ref.Cell{Row: t.ref.Range.From.Row + i, Col: t.ref.Range.From.Col + j}
+	to := ref.Cell{Row: from.Row + rows - 1, Col: from.Col + cols - 1}
+	return &eval.Ref{Sheet: t.ref.Sheet, Range: ref.Range{From: from, To: to}}
+}
+
+func (t *table) at(i, j int) eval.Value {
+	return t.e.Deref(t.sub(i, j, 1, 1))
+}
+
+// vector is a row or column of a table.
+type vector struct {
+	t     *table
+	i     int // index of the row or column
+	byRow bool
+	n     int
+}
+
+// line returns row i of t if byRow is set, else column i.
+func (t *table) line(i int, byRow bool) vector {
+	if byRow {
+		return vector{t, i, true, t.cols}
+	}
+	return vector{t, i, false, t.rows}
+}
+
+// vector returns the cells of a table with a single row or column.
+func (t *table) vector() (vector, bool) {
+	switch {
+	case t.rows == 1:
+		return t.line(0, true), true
+	case t.cols == 1:
+		return t.line(0, false), true
+	}
+	return vector{}, false
+}
+
+func (v vector) at(k int) eval.Value {
+	if v.byRow {
+		return v.t.at(v.i, k)
+	}
+	return v.t.at(k, v.i)
+}
+
+// Match modes and search modes, numbered as by XMATCH.
+const (
+	nextSmaller   = -1
+	exactMatch    = 0
+	nextLarger    = 1
+	wildcardMatch = 2
+
+	firstToLast = 1
+	lastToFirst = -1
+	binaryAsc   = 2
+	binaryDesc  = -2
+)
+
+// sameType reports whether x and y are both numbers, text or logical
+// values. Lookups never match values of different types.
+func sameType(x, y eval.Value) bool {
+	switch x.(type) {
+	case eval.Number:
+		_, ok := y.(eval.Number)
+		return ok
+	case eval.Text:
+		_, ok := y.(eval.Text)
+		return ok
+	case eval.Bool:
+		_, ok := y.(eval.Bool)
+		return ok
+	}
+	return false
+}
+
+// matches reports whether v matches x exactly, with ? and * in text x
+// as wildcards if wildcard is set. Text matches ignore case.
+func matches(x, v eval.Value, wildcard bool) bool {
+	if !sameType(x, v) {
+		return false
+	}
+	if s, ok := x.(eval.Text); ok && wildcard {
+		pat := []rune(strings.ToLower(string(s)))
+		return matchWildcard(pat, []rune(strings.ToLower(string(v.(eval.Text)))), false)
+	}
+	return eval.Compare(x, v) == 0
+}
+
+// search returns the position in vec of the value matching x, or -1.
+// The binary search modes expect values sorted in ascending or
+// descending order; blank cells sort last.
+func search(x eval.Value, vec vector, mode, dir int) int {
+	if dir == binaryAsc || dir == binaryDesc {
+		return binarySearch(x, vec, mode, dir == binaryDesc)
+	}
+	best := -1
+	for k := 0; k < vec.n; k++ {
+		i := k
+		if dir == lastToFirst {
+			i = vec.n - 1 - k
+		}
+		v := vec.at(i)
+		if matches(x, v, mode == wildcardMatch) {
+			return i
+		}
+		if mode != nextSmaller && mode != nextLarger || !sameType(x, v) {
+			continue
+		}
+		c := eval.Compare(v, x)
+		if c*mode > 0 && (best < 0 || eval.Compare(v, vec.at(best))*mode < 0) {
+			best = i
+		}
+	}
+	return best
+}
+
+func binarySearch(x eval.Value, vec vector, mode int, desc bool) int {
+	cmp := func(i int) int {
+		v := vec.at(i)
+		if _, ok := v.(eval.Blank); ok {
+			if desc {
+				return -1
+			}
+			return 1
+		}
+		return eval.Compare(v, x)
+	}
+	var i int
+	switch {
+	case !desc && mode == nextLarger:
+		// first value >= x
+		i = sort.Search(vec.n, func(i int) bool { return cmp(i) >= 0 })
+	case !desc:
+		// last value <= x
+		i = sort.Search(vec.n, func(i int) bool { return cmp(i) > 0 }) - 1
+	case mode == nextLarger:
+		// last value >= x
+		i = sort.Search(vec.n, func(i int) bool { return cmp(i) < 0 }) - 1
+	default:
+		// first value <= x
+		i = sort.Search(vec.n, func(i int) bool { return cmp(i) <= 0 })
+	}
+	if i < 0 || i >= vec.n {
+		return -1
+	}
+	v := vec.at(i)
+	switch mode {
+	case exactMatch, wildcardMatch:
+		if !matches(x, v, false) {
+			return -1
+		}
+	default:
+		if !sameType(x, v) {
+			return -1
+		}
+	}
+	return i
+}
+
+// lookupValue returns argument i resolved to a single value.
+func lookupValue(e *eval.Evaluator, args []eval.Value, i int) (eval.Value, error) {
+	v := e.Deref(args[i])
+	if err, ok := v.(eval.Error); ok {
+		return nil, err
+	}
+	return v, nil
+}
+
+// vhlookup adapts VLOOKUP, which searches the first column of the table,
+// and HLOOKUP, which searches the first row. Approximate matches use a
+// binary search and expect the data to be sorted.
+func vhlookup(byRow bool) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		x, err := lookupValue(e, args, 0)
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		t, err := newTable(e, args[1])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		n, err := e.Number(args[2])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		approx := true
+		if len(args) > 3 {
+			if approx, err = e.Bool(args[3]); err != nil {
+				return eval.ErrorValue(err)
+			}
+		}
+		size := t.cols
+		if byRow {
+			size = t.rows
+		}
+		switch {
+		case n < 1:
+			return eval.ErrValue
+		case n > float64(size):
+			return eval.ErrRef
+		}
+		keys := t.line(0, byRow)
+		var i int
+		if approx {
+			i = search(x, keys, nextSmaller, binaryAsc)
+		} else {
+			i = search(x, keys, wildcardMatch, firstToLast)
+		}
+		switch {
+		case i < 0:
+			return eval.ErrNA
+		case byRow:
+			return t.at(int(n)-1, i)
+		}
+		return t.at(i, int(n)-1)
+	}
+}
+
+// lookupFunc implements LOOKUP. Without a result vector a table with
+// more columns than rows is searched along its first row and the result
+// taken from its last row, otherwise down its first and last columns.
+func lookupFunc(e *eval.Evaluator, args []eval.Value) eval.Value {
+	x, err := lookupValue(e, args, 0)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	t, err := newTable(e, args[1])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	byRow := t.cols > t.rows
+	keys, results := t.line(0, byRow), t.line(t.cols-1, false)
+	if byRow {
+		results = t.line(t.rows-1, true)
+	}
+	if len(args) > 2 {
+		var ok bool
+		if keys, ok = t.vector(); !ok {
+			return eval.ErrNA
+		}
+		rt, err := newTable(e, args[2])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		if results, ok = rt.vector(); !ok {
+			return eval.ErrNA
+		}
+	}
+	i := search(x, keys, nextSmaller, binaryAsc)
+	if i < 0 || i >= results.n {
+		return eval.ErrNA
+	}
+	return results.at(i)
+}
+
+// matchArgs returns the lookup value and vector of MATCH and XMATCH.
+func matchArgs(e *eval.Evaluator, args []eval.Value) (eval.Value, vector, error) {
+	x, err := lookupValue(e, args, 0)
+	if err != nil {
+		return nil, vector{}, err
+	}
+	t, err := newTable(e, args[1])
+	if err != nil {
+		return nil, vector{}, err
+	}
+	vec, ok := t.vector()
+	if !ok {
+		return nil, vector{}, eval.ErrNA
+	}
+	return x, vec, nil
+}
+
+func match(e *eval.Evaluator, args []eval.Value) eval.Value {
+	x, vec, err := matchArgs(e, args)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	typ, err := optNumber(e, args, 2, 1)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	var i int
+	switch {
+	case typ > 0:
+		i = search(x, vec, nextSmaller, binaryAsc)
+	case typ < 0:
+		i = search(x, vec, nextLarger, binaryDesc)
+	default:
+		i = search(x, vec, wildcardMatch, firstToLast)
+	}
+	if i < 0 {
+		return eval.ErrNA
+	}
+	return eval.Number(i + 1)
+}
+
+// xmodes returns the match and search modes of XMATCH and XLOOKUP from
+// arguments i and i+1.
+func xmodes(e *eval.Evaluator, args []eval.Value, i int) (mode, dir int, err error) {
+	m, err := optNumber(e, args, i, exactMatch)
+	if err != nil {
+		return 0, 0, err
+	}
+	d, err := optNumber(e, args, i+1, firstToLast)
+	if err != nil {
+		return 0, 0, err
+	}
+	mode, dir = int(m), int(d)
+	if mode < nextSmaller || mode > wildcardMatch || dir == 0 || dir < binaryDesc || dir > binaryAsc {
+		return 0, 0, eval.ErrValue
+	}
+	if mode == wildcardMatch && (dir == binaryAsc || dir == binaryDesc) {
+		return 0, 0, eval.ErrValue
+	}
+	return mode, dir, nil
+}
+
+func xmatch(e *eval.Evaluator, args []eval.Value) eval.Value {
+	x, vec, err := matchArgs(e, args)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	mode, dir, err := xmodes(e, args, 2)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	i := search(x, vec, mode, dir)
+	if i < 0 {
+		return eval.ErrNA
+	}
+	return eval.Number(i + 1)
+}
+
+// xlookup implements XLOOKUP. The result is the row of the return array
+// matching a lookup column, or the column matching a lookup row.
+func xlookup(e *eval.Evaluator, args []eval.Value) eval.Value {
+	x, vec, err := matchArgs(e, args)
+	if err != nil {
+		if err == eval.ErrNA {
+			return eval.ErrValue
+		}
+		return eval.ErrorValue(err)
+	}
+	rt, err := newTable(e, args[2])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	mode, dir, err := xmodes(e, args, 4)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	n := rt.rows
+	if vec.byRow {
+		n = rt.cols
+	}
+	if n != vec.n {
+		return eval.ErrValue
+	}
+	i := search(x, vec, mode, dir)
+	switch {
+	case i >= 0 && vec.byRow:
+		return rt.sub(0, i, rt.rows, 1)
+	case i >= 0:
+		return rt.sub(i, 0, 1, rt.cols)
+	case len(args) > 3:
+		return eval.Force(args[3])
+	}
+	return eval.ErrNA
+}
+
+// index implements INDEX. A row or column number of 0 selects the whole
+// column or row; a single number indexes a one row table by column.
+func index(e *eval.Evaluator, args []eval.Value) eval.Value {
+	t, err := newTable(e, args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	var n [3]float64
+	for i, def := range []float64{0, 0, 1} {
+		if n[i], err = optNumber(e, args, i+1, def); err != nil {
+			return eval.ErrorValue(err)
+		}
+	}
+	row, col := int(n[0]), int(n[1])
+	if len(args) == 2 && t.rows == 1 {
+		row, col = 1, row
+	}
+	switch {
+	case row < 0 || col < 0:
+		return eval.ErrValue
+	case row > t.rows || col > t.cols || n[2] != 1:
+		return eval.ErrRef
+	}
+	i, j, rows, cols := row-1, col-1, 1, 1
+	if row == 0 {
+		i, rows = 0, t.rows
+	}
+	if col == 0 {
+		j, cols = 0, t.cols
+	}
+	return t.sub(i, j, rows, cols)
+}
+
+func offset(e *eval.Evaluator, args []eval.Value) eval.Value {
+	r, ok := eval.Force(args[0]).(*eval.Ref)
+	if !ok {
+		if err, ok := eval.Force(args[0]).(eval.Error); ok {
+			return err
+		}
+		return eval.ErrValue
+	}
+	var n [4]float64
+	defs := []float64{0, 0, float64(r.Range.Rows()), float64(r.Range.Cols())}
+	for i, def := range defs {
+		var err error
+		if n[i], err = optNumber(e, args, i+1, def); err != nil {
+			return eval.ErrorValue(err)
+		}
+	}
+	from := ref.Cell{Row: r.Range.From.Row + int(n[0]), Col: r.Range.From.Col + int(n[1])}
+	to := ref.Cell{Row: from.Row + int(n[2]) - 1, Col: from.Col + int(n[3]) - 1}
+	if int(n[2]) < 1 || int(n[3]) < 1 || !from.IsValid() || !to.IsValid() {
+		return eval.ErrRef
+	}
+	return &eval.Ref{Sheet: r.Sheet, Range: ref.Range{From: from, To: to}}
+}
+
+// indirect implements INDIRECT for A1 style references such as "B2",
+// "$A$1:$C$3" and "'Sheet 2'!A1".
+func indirect(e *eval.Evaluator, args []eval.Value) eval.Value {
+	s, err := e.Text(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	if len(args) > 1 {
+		a1, err := e.Bool(args[1])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		if !a1 {
+			return eval.ErrRef
+		}
+	}
+	r, ok := parseRef(s)
+	if !ok {
+		return eval.ErrRef
+	}
+	return r
+}
+
+// parseRef parses an optionally sheet qualified A1 style reference.
+func parseRef(s string) (*eval.Ref, bool) {
+	r, err := ref.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return nil, false
+	}
+	return &eval.Ref{Sheet: r.Sheet, Range: r.Range}, true
+}
+
+// rowCol adapts ROW and COLUMN, which default to the formula's own cell.
+func rowCol(col bool) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		c := e.Cell
+		if len(args) > 0 {
+			switch v := eval.Force(args[0]).(type) {
+			case *eval.Ref:
+				c = v.Range.From
+			case eval.Error:
+				return v
+			default:
+				return eval.ErrValue
+			}
+		}
+		if col {
+			return eval.Number(c.Col)
+		}
+		return eval.Number(c.Row)
+	}
+}
+
+// rowsCols adapts ROWS and COLUMNS.
+func rowsCols(col bool) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		t, err := newTable(e, args[0])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		if col {
+			return eval.Number(t.cols)
+		}
+		return eval.Number(t.rows)
+	}
+}
+
+// cellInfo returns information about the first cell of a reference, or
+// the formula's own cell if the reference is omitted. Of the info types
+// only those that do not depend on formatting are supported.
+func cellInfo(e *eval.Evaluator, args []eval.Value) eval.Value {
+	info, err := e.Text(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	r := &eval.Ref{Range: ref.Range{From: e.Cell, To: e.Cell}}
+	if len(args) > 1 {
+		switch v := eval.Force(args[1]).(type) {
+		case *eval.Ref:
+			r = &eval.Ref{Sheet: v.Sheet, Range: ref.Range{From: v.Range.From, To: v.Range.From}}
+		case eval.Error:
+			return v
+		default:
+			return eval.ErrValue
+		}
+	}
+	c := r.Range.From
+	switch strings.ToLower(info) {
+	case "address":
+		return eval.Text("$" + ref.ColName(c.Col) + "$" + strconv.Itoa(c.Row))
+	case "col":
+		return eval.Number(c.Col)
+	case "row":
+		return eval.Number(c.Row)
+	case "contents":
+		return e.Deref(r)
+	case "type":
+		switch e.Deref(r).(type) {
+		case eval.Blank:
+			return eval.Text("b")
+		case eval.Text:
+			return eval.Text("l")
+		}
+		return eval.Text("v")
+	}
+	return eval.ErrValue
+}
+
+// address implements ADDRESS. abs_num 1 to 4 makes both the row and
+// column, the row only, the column only or neither absolute.
+func address(e *eval.Evaluator, args []eval.Value) eval.Value {
+	var n [3]float64
+	for i, def := range []float64{0, 0, 1} {
+		var err error
+		if n[i], err = optNumber(e, args, i, def); err != nil {
+			return eval.ErrorValue(err)
+		}
+	}
+	a1 := true
+	if len(args) > 3 {
+		var err error
+		if a1, err = e.Bool(args[3]); err != nil {
+			return eval.ErrorValue(err)
+		}
+	}
+	c := ref.Cell{Row: int(n[0]), Col: int(n[1])}
+	abs := int(n[2])
+	if !c.IsValid() || abs < 1 || abs > 4 {
+		return eval.ErrValue
+	}
+	absRow, absCol := abs <= 2, abs%2 == 1
+	var s string
+	if a1 {
+		if absCol {
+			s = "$"
+		}
+		s += ref.ColName(c.Col)
+		if absRow {
+			s += "$"
+		}
+		s += strconv.Itoa(c.Row)
+	} else {
+		s = "R" + r1c1(c.Row, absRow) + "C" + r1c1(c.Col, absCol)
+	}
+	if len(args) > 4 {
+		sheet, err := e.Text(args[4])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		s = ref.QuoteSheet(sheet) + "!" + s
+	}
+	return eval.Text(s)
+}
+
+// r1c1 formats an R1C1 style row or column number, relative ones in
+// brackets.
+func r1c1(n int, abs bool) string {
+	if abs {
+		return strconv.Itoa(n)
+	}
+	return "[" + strconv.Itoa(n) + "]"
+}
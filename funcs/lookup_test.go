@@ -0,0 +1,104 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	runTests(t, []evalTest{
+		{"VLOOKUP(20,F1:H4,2,FALSE)", eval.Text("Banana")},
+		{"VLOOKUP(25,F1:H4,3)", eval.Number(2.5)},
+		{"VLOOKUP(5,F1:H4,2)", eval.ErrNA},
+		{"VLOOKUP(20,F1:H4,4)", eval.ErrRef},
+		{"VLOOKUP(20,F1:H4,0)", eval.ErrValue},
+		{`VLOOKUP("b*",G1:H4,2,FALSE)`, eval.Number(2.5)},
+		{`VLOOKUP("BANANA",G1:H4,2,FALSE)`, eval.Number(2.5)},
+		{`VLOOKUP("20",F1:H4,2,FALSE)`, eval.ErrNA},
+		{"VLOOKUP(99,F:H,2)", eval.Text("date")},
+		{"VLOOKUP(B1,F1:H4,2)", eval.ErrDiv0},
+		{"VLOOKUP(1,{1,2;3,4},1E300,1)", eval.ErrRef},
+		{"HLOOKUP(10,F1:H2,2,FALSE)", eval.Number(20)},
+		{"HLOOKUP(1,{1,2;3,4},1E300,1)", eval.ErrRef},
+		{"HLOOKUP(1,{1,2;3,4},-1E300,1)", eval.ErrValue},
+		{"LOOKUP(35,F1:F4,G1:G4)", eval.Text("cherry")},
+		{"LOOKUP(35,F1:H4)", eval.Number(3.5)},
+		{"MATCH(30,F1:F4,0)", eval.Number(3)},
+		{"MATCH(35,F1:F4)", eval.Number(3)},
+		{"MATCH(5,F1:F4)", eval.ErrNA},
+		{`MATCH("c*",G1:G4,0)`, eval.Number(3)},
+		{"MATCH(30,F1:H4,0)", eval.ErrNA},
+		{"XMATCH(35,F1:F4,1)", eval.Number(4)},
+		{"XMATCH(35,F1:F4,-1)", eval.Number(3)},
+		{"XMATCH(35,F1:F4)", eval.ErrNA},
+		{"XMATCH(30,F1:F4,0,2)", eval.Number(3)},
+		{"XMATCH(30,F1:F4,0,-1)", eval.Number(3)},
+		{`XMATCH("?ate",G1:G4,2)`, eval.Number(4)},
+		{`XMATCH("?ate",G1:G4)`, eval.ErrNA},
+		{`XLOOKUP("cherry",G1:G4,H1:H4)`, eval.Number(3.5)},
+		{`XLOOKUP("x",G1:G4,H1:H4,"none")`, eval.Text("none")},
+		{`XLOOKUP("x",G1:G4,H1:H4)`, eval.ErrNA},
+		{`XLOOKUP("apple",G1:G4,H1:H4,1/0)`, eval.Number(1.5)},
+		{`XLOOKUP(25,F1:F4,G1:G4,"",1)`, eval.Text("cherry")},
+		{"XLOOKUP(25,F1:F4,G1:G4,,1)", eval.Text("cherry")},
+		{"XLOOKUP(25,F1:F4,G1:G4,,-1)", eval.Text("Banana")},
+		{"XLOOKUP(20,F1:F4,G1:G3)", eval.ErrValue},
+	})
+}
+
+func TestReference(t *testing.T) {
+	runTests(t, []evalTest{
+		{"INDEX(F1:H4,2,3)", eval.Number(2.5)},
+		{"INDEX(F1:H4,5,1)", eval.ErrRef},
+		{"INDEX(F1:F4,3)", eval.Number(30)},
+		{"INDEX(F1:H1,2)", eval.Text("apple")},
+		{"SUM(INDEX(F1:H4,0,1))", eval.Number(100)},
+		{"SUM(INDEX(F1:H4,2,0))", eval.Number(22.5)},
+		{"OFFSET(F1,2,1)", eval.Text("cherry")},
+		{"SUM(OFFSET(F1,0,0,4))", eval.Number(100)},
+		{"OFFSET(A1,-1,0)", eval.ErrRef},
+		{`INDIRECT("G2")`, eval.Text("Banana")},
+		{`INDIRECT("$F$3")`, eval.Number(30)},
+		{`SUM(INDIRECT("F1:F4"))`, eval.Number(100)},
+		{`INDIRECT("'My Sheet'!F1")`, eval.Number(10)},
+		{`INDIRECT("1x")`, eval.ErrRef},
+		{"ROW(F3)", eval.Number(3)},
+		{"COLUMN(G1:H4)", eval.Number(7)},
+		{"ROWS(F1:H4)", eval.Number(4)},
+		{"COLUMNS(F1:H4)", eval.Number(3)},
+		{"ROWS(5)", eval.Number(1)},
+		{"ADDRESS(2,3)", eval.Text("$C$2")},
+		{"ADDRESS(2,3,2)", eval.Text("C$2")},
+		{"ADDRESS(2,3,3)", eval.Text("$C2")},
+		{"ADDRESS(2,3,4)", eval.Text("C2")},
+		{"ADDRESS(2,3,1,FALSE)", eval.Text("R2C3")},
+		{"ADDRESS(2,3,4,FALSE)", eval.Text("R[2]C[3]")},
+		{`ADDRESS(1,1,1,TRUE,"Data")`, eval.Text("Data!$A$1")},
+		{`ADDRESS(1,1,1,TRUE,"My Sheet")`, eval.Text("'My Sheet'!$A$1")},
+		{"ADDRESS(0,1)", eval.ErrValue},
+		{`CELL("address",G2:H4)`, eval.Text("$G$2")},
+		{`CELL("row",G2)`, eval.Number(2)},
+		{`CELL("col",G2)`, eval.Number(7)},
+		{`CELL("contents",G2)`, eval.Text("Banana")},
+		{`CELL("type",G2)`, eval.Text("l")},
+		{`CELL("type",F2)`, eval.Text("v")},
+		{`CELL("type",Z9)`, eval.Text("b")},
+		{`CELL("width",F2)`, eval.ErrValue},
+	})
+}
+
+func TestArrayArgs(t *testing.T) {
+	abs := eval.NewArray(1, 2)
+	abs.Set(0, 0, eval.Number(1))
+	abs.Set(0, 1, eval.Number(2))
+	runTests(t, []evalTest{
+		{"SUM(IF(F1:F4>15,F1:F4))", eval.Number(90)},
+		{"SUM({1,2;3,4}*{1;0})", eval.Number(3)},
+		{"ABS({-1,2})", abs},
+		{"ROWS({1,2;3,4})", eval.Number(2)},
+		{"COLUMNS({1,2,3})", eval.Number(3)},
+		{"MATCH(2,{1,2,3},0)", eval.Number(2)},
+		{"INDEX({1,2;3,4},2,1)", eval.Number(3)},
+		{`VLOOKUP(2,{1,"a";2,"b"},2,FALSE)`, eval.Text("b")},
+	})
+}
@@ -0,0 +1,252 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/types"
+	"math"
+	"strconv"
+)
+
+// Math holds the arithmetic, rounding and aggregate functions.
+var Math = []*eval.Func{
+	{Name: "SUM", Sig: variadic(types.Number, num("number")), Call: aggregate(sum)},
+	{Name: "PRODUCT", Sig: variadic(types.Number, num("number")), Call: aggregate(product)},
+	{Name: "AVERAGE", Sig: variadic(types.Number, num("number")), Call: aggregate(average)},
+	{Name: "MIN", Sig: variadic(types.Number, num("number")), Call: aggregate(min)},
+	{Name: "MAX", Sig: variadic(types.Number, num("number")), Call: aggregate(max)},
+	{Name: "COUNT", Sig: variadic(types.Number, val("value")), Call: count},
+	{Name: "COUNTA", Sig: variadic(types.Number, val("value")), Call: counta},
+	{Name: "ROUND", Sig: fixed(types.Number, num("number"), num("digits")), Call: fn2(0, roundFunc(math.Round))},
+	{Name: "ROUNDUP", Sig: fixed(types.Number, num("number"), num("digits")), Call: fn2(0, roundFunc(roundUp))},
+	{Name: "ROUNDDOWN", Sig: fixed(types.Number, num("number"), num("digits")), Call: fn2(0, roundFunc(math.Trunc))},
+	{Name: "TRUNC", Sig: fixed(types.Number, num("number"), opt(num("digits"))), Call: fn2(0, roundFunc(math.Trunc))},
+	{Name: "INT", Sig: fixed(types.Number, num("number")), Call: fn1(func(x float64) eval.Value { return eval.Number(math.Floor(x)) })},
+	{Name: "MOD", Sig: fixed(types.Number, num("number"), num("divisor")), Call: fn2(0, mod)},
+	{Name: "ABS", Sig: fixed(types.Number, num("number")), Call: fn1(func(x float64) eval.Value { return eval.Number(math.Abs(x)) })},
+	{Name: "SQRT", Sig: fixed(types.Number, num("number")), Call: fn1(sqrt)},
+	{Name: "POWER", Sig: fixed(types.Number, num("number"), num("power")), Call: fn2(0, Power)},
+	{Name: "EXP", Sig: fixed(types.Number, num("number")), Call: fn1(func(x float64) eval.Value { return eval.NumberOrError(math.Exp(x)) })},
+	{Name: "LN", Sig: fixed(types.Number, num("number")), Call: fn1(ln)},
+	{Name: "LOG", Sig: fixed(types.Number, num("number"), opt(num("base"))), Call: fn2(10, log)},
+	{Name: "PI", Sig: fixed(types.Number), Call: func(*eval.Evaluator, []eval.Value) eval.Value { return eval.Number(math.Pi) }},
+	{Name: "SIGN", Sig: fixed(types.Number, num("number")), Call: fn1(sign)},
+	{Name: "CEILING", Sig: fixed(types.Number, num("number"), num("significance")), Call: fn2(1, ceiling)},
+	{Name: "FLOOR", Sig: fixed(types.Number, num("number"), num("significance")), Call: fn2(1, floor)},
+	{Name: "RAND", Sig: fixed(types.Number), Call: func(e *eval.Evaluator, _ []eval.Value) eval.Value { return eval.Number(e.Random()) }, Serial: true, Volatile: true},
+	{Name: "RANDBETWEEN", Sig: fixed(types.Number, num("bottom"), num("top")), Call: randBetween, Serial: true, Volatile: true},
+}
+
+func sum(xs []float64) eval.Value {
+	s := 0.0
+	for _, x := range xs {
+		s += x
+	}
+	return eval.NumberOrError(s)
+}
+
+func product(xs []float64) eval.Value {
+	if len(xs) == 0 {
+		return eval.Number(0)
+	}
+	p := 1.0
+	for _, x := range xs {
+		p *= x
+	}
+	return eval.NumberOrError(p)
+}
+
+func average(xs []float64) eval.Value {
+	if len(xs) == 0 {
+		return eval.ErrDiv0
+	}
+	s := 0.0
+	for _, x := range xs {
+		s += x
+	}
+	return eval.NumberOrError(s / float64(len(xs)))
+}
+
+func min(xs []float64) eval.Value {
+	if len(xs) == 0 {
+		return eval.Number(0)
+	}
+	m := xs[0]
+	for _, x := range xs[1:] {
+		m = math.Min(m, x)
+	}
+	return eval.Number(m)
+}
+
+func max(xs []float64) eval.Value {
+	if len(xs) == 0 {
+		return eval.Number(0)
+	}
+	m := xs[0]
+	for _, x := range xs[1:] {
+		m = math.Max(m, x)
+	}
+	return eval.Number(m)
+}
+
+// count counts numbers. Inside references and arrays only numbers count;
+// direct arguments also count if they convert to a number.
+func count(e *eval.Evaluator, args []eval.Value) eval.Value {
+	n := 0
+	for _, a := range args {
+		switch a.(type) {
+		case *eval.Ref, *eval.Array:
+			for _, v := range e.Values(a) {
+				if _, ok := v.(eval.Number); ok {
+					n++
+				}
+			}
+			continue
+		case eval.Error, eval.Blank:
+			continue
+		}
+		if _, err := eval.ToNumber(a); err == nil {
+			n++
+		}
+	}
+	return eval.Number(n)
+}
+
+// counta counts values that are not blank, including errors.
+func counta(e *eval.Evaluator, args []eval.Value) eval.Value {
+	n := 0
+	for _, a := range args {
+		for _, v := range e.Values(a) {
+			if _, ok := v.(eval.Blank); !ok {
+				n++
+			}
+		}
+	}
+	return eval.Number(n)
+}
+
+// canonical rounds x to 15 significant digits, the precision shown by
+// spreadsheets, so that 2.675*100 is treated as 267.5.
+func canonical(x float64) float64 {
+	y, err := strconv.ParseFloat(strconv.FormatFloat(x, 'g', 15, 64), 64)
+	if err != nil {
+		return x
+	}
+	return y
+}
+
+func roundUp(x float64) float64 {
+	if x < 0 {
+		return math.Floor(x)
+	}
+	return math.Ceil(x)
+}
+
+// roundFunc returns a function rounding to a number of digits with mode.
+// Negative digits round to the left of the decimal point.
+func roundFunc(mode func(float64) float64) func(x, digits float64) eval.Value {
+	return func(x, digits float64) eval.Value {
+		return eval.NumberOrError(Round(x, int(math.Max(math.Min(digits, 16), -309)), mode))
+	}
+}
+
+// Round rounds x to digits decimal places using mode, one of math.Round,
+// math.Trunc, math.Floor or math.Ceil. Digits greater than 15 leave x as
+// is, and digits less than -308 round it to 0.
+func Round(x float64, digits int, mode func(float64) float64) float64 {
+	switch {
+	case digits > 15:
+		return x
+	case digits < -308:
+		return 0
+	}
+	p := math.Pow10(digits)
+	return mode(canonical(x*p)) / p
+}
+
+func mod(n, d float64) eval.Value {
+	if d == 0 {
+		return eval.ErrDiv0
+	}
+	return eval.NumberOrError(n - d*math.Floor(canonical(n/d)))
+}
+
+func sqrt(x float64) eval.Value {
+	if x < 0 {
+		return eval.ErrNum
+	}
+	return eval.Number(math.Sqrt(x))
+}
+
+// Power returns x raised to y with spreadsheet error rules.
+func Power(x, y float64) eval.Value {
+	if x == 0 {
+		switch {
+		case y == 0:
+			return eval.ErrNum
+		case y < 0:
+			return eval.ErrDiv0
+		}
+	}
+	return eval.NumberOrError(math.Pow(x, y))
+}
+
+func ln(x float64) eval.Value {
+	if x <= 0 {
+		return eval.ErrNum
+	}
+	return eval.Number(math.Log(x))
+}
+
+func log(x, base float64) eval.Value {
+	if x <= 0 || base <= 0 {
+		return eval.ErrNum
+	}
+	if base == 1 {
+		return eval.ErrDiv0
+	}
+	return eval.NumberOrError(math.Log(x) / math.Log(base))
+}
+
+func sign(x float64) eval.Value {
+	switch {
+	case x > 0:
+		return eval.Number(1)
+	case x < 0:
+		return eval.Number(-1)
+	}
+	return eval.Number(0)
+}
+
+func ceiling(x, sig float64) eval.Value {
+	if sig == 0 {
+		return eval.Number(0)
+	}
+	if x > 0 && sig < 0 {
+		return eval.ErrNum
+	}
+	return eval.NumberOrError(math.Ceil(canonical(x/sig)) * sig)
+}
+
+func floor(x, sig float64) eval.Value {
+	if sig == 0 {
+		return eval.ErrDiv0
+	}
+	if x > 0 && sig < 0 {
+		return eval.ErrNum
+	}
+	return eval.NumberOrError(math.Floor(canonical(x/sig)) * sig)
+}
+
+// randBetween returns a random integer from bottom to top, rounded
+// inwards to integers.
+func randBetween(e *eval.Evaluator, args []eval.Value) eval.Value {
+	x, err := numArgs(e, args, 0, 0)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	lo, hi := math.Ceil(x[0]), math.Floor(x[1])
+	if lo > hi {
+		return eval.ErrNum
+	}
+	return eval.Number(lo + math.Floor(e.Random()*(hi-lo+1)))
+}
@@ -0,0 +1,178 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/parser"
+	"github.com/ajz01/calc/ref"
+	"math"
+	"testing"
+	"time"
+)
+
+type cells map[string]eval.Value
+
+func (c cells) Value(sheet string, cell ref.Cell) eval.Value {
+	return c[cell.String()]
+}
+
+var testCells = cells{
+	"A1": eval.Number(1),
+	"A2": eval.Number(2),
+	"A3": eval.Text("3"),
+	"A4": eval.Bool(true),
+	"A5": eval.Number(4),
+	"B1": eval.ErrDiv0,
+	"C1": eval.Number(-2.5),
+
+	// lookup table
+	"F1": eval.Number(10), "G1": eval.Text("apple"), "H1": eval.Number(1.5),
+	"F2": eval.Number(20), "G2": eval.Text("Banana"), "H2": eval.Number(2.5),
+	"F3": eval.Number(30), "G3": eval.Text("cherry"), "H3": eval.Number(3.5),
+	"F4": eval.Number(40), "G4": eval.Text("date"), "H4": eval.Number(4.5),
+
+	// observed and expected counts
+	"I1": eval.Number(10), "J1": eval.Number(20),
+	"I2": eval.Number(20), "J2": eval.Number(20),
+	"I3": eval.Number(30), "J3": eval.Number(20),
+
+	// cash flows, dated cash flows
+	"K1": eval.Number(-70000), "L1": eval.Number(39448), "M1": eval.Number(-10000),
+	"K2": eval.Number(12000), "L2": eval.Number(39508), "M2": eval.Number(2750),
+	"K3": eval.Number(15000), "L3": eval.Number(39751), "M3": eval.Number(4250),
+	"K4": eval.Number(18000), "L4": eval.Number(39859), "M4": eval.Number(3250),
+	"K5": eval.Number(21000), "L5": eval.Number(39904), "M5": eval.Number(2750),
+	"K6": eval.Number(26000),
+}
+
+var testFuncs = Default()
+
+// testNow is the time seen by TODAY and NOW in tests.
+var testNow = time.Date(2024, time.March, 15, 13, 45, 30, 0, time.UTC)
+
+func evalString(t *testing.T, src string) eval.Value {
+	x, err := parser.ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("ParseBytes(%q) %v", src, err)
+	}
+	e := &eval.Evaluator{Context: testCells, Funcs: testFuncs, Clock: func() time.Time { return testNow }}
+	return e.Eval(x)
+}
+
+type evalTest struct {
+	src  string
+	want eval.Value
+}
+
+func runTests(t *testing.T, tests []evalTest) {
+	for _, test := range tests {
+		got := evalString(t, test.src)
+		if n, ok := test.want.(eval.Number); ok {
+			if g, ok := got.(eval.Number); ok && math.Abs(float64(g-n)) <= 1e-9*math.Max(1, math.Abs(float64(n))) {
+				continue
+			}
+		} else if a, ok := test.want.(*eval.Array); ok {
+			if got.String() == a.String() {
+				continue
+			}
+		} else if got == test.want {
+			continue
+		}
+		t.Errorf("Eval(%q) = %v want %v", test.src, got, test.want)
+	}
+}
+
+func TestAggregates(t *testing.T) {
+	runTests(t, []evalTest{
+		{"SUM(A1:A5)", eval.Number(7)},
+		{`SUM(A1,"3",A4)`, eval.Number(4)},
+		{"SUM(A1:B1)", eval.ErrDiv0},
+		{`SUM("x")`, eval.ErrValue},
+		{"PRODUCT(A1:A5)", eval.Number(8)},
+		{"PRODUCT(D1:D3)", eval.Number(0)},
+		{"AVERAGE(A1:A5)", eval.Number(7.0 / 3)},
+		{"AVERAGE(D1:D3)", eval.ErrDiv0},
+		{"MIN(A1:A5,0.5)", eval.Number(0.5)},
+		{"MAX(A1:A5)", eval.Number(4)},
+		{"MAX(D1:D3)", eval.Number(0)},
+		{`COUNT(A1:A5,"7",B1)`, eval.Number(4)},
+		{`COUNT({1,2,"a"})`, eval.Number(2)},
+		{"COUNT({1,2,3}*1)", eval.Number(3)},
+		{"COUNTA(A1:A5,B1,D1)", eval.Number(6)},
+	})
+}
+
+func TestRounding(t *testing.T) {
+	runTests(t, []evalTest{
+		{"ROUND(2.675,2)", eval.Number(2.68)},
+		{"ROUND(C1,0)", eval.Number(-3)},
+		{"ROUND(1234.5,-2)", eval.Number(1200)},
+		{"ROUND(2.5,)", eval.Number(3)},
+		{"ROUND(1,1E300)", eval.Number(1)},
+		{"ROUND(5,-400)", eval.Number(0)},
+		{"ROUND(5,-1E300)", eval.Number(0)},
+		{"ROUNDUP(5,-400)", eval.Number(0)},
+		{"ROUNDDOWN(1.5,1E300)", eval.Number(1.5)},
+		{"ROUNDUP(1.1,1)", eval.Number(1.1)},
+		{"ROUNDUP(C1,0)", eval.Number(-3)},
+		{"ROUNDDOWN(C1,0)", eval.Number(-2)},
+		{"TRUNC(8.9)", eval.Number(8)},
+		{"INT(C1)", eval.Number(-3)},
+		{"CEILING(2.5,1)", eval.Number(3)},
+		{"CEILING(C1,-2)", eval.Number(-4)},
+		{"CEILING(C1,2)", eval.Number(-2)},
+		{"CEILING(2.5,-1)", eval.ErrNum},
+		{"FLOOR(C1,2)", eval.Number(-4)},
+		{"FLOOR(C1,-2)", eval.Number(-2)},
+		{"FLOOR(1,0)", eval.ErrDiv0},
+	})
+}
+
+func TestMath(t *testing.T) {
+	runTests(t, []evalTest{
+		{"MOD(-3,2)", eval.Number(1)},
+		{"MOD(3,-2)", eval.Number(-1)},
+		{"MOD(1,0)", eval.ErrDiv0},
+		{"ABS(C1)", eval.Number(2.5)},
+		{"SQRT(16)", eval.Number(4)},
+		{"SQRT(C1)", eval.ErrNum},
+		{"POWER(2,10)", eval.Number(1024)},
+		{"POWER(0,0)", eval.ErrNum},
+		{"POWER(C1,0.5)", eval.ErrNum},
+		{"EXP(0)", eval.Number(1)},
+		{"LN(EXP(2))", eval.Number(2)},
+		{"LN(0)", eval.ErrNum},
+		{"LOG(1000)", eval.Number(3)},
+		{"LOG(8,2)", eval.Number(3)},
+		{"LOG(8,1)", eval.ErrDiv0},
+		{"PI()", eval.Number(math.Pi)},
+		{"SIGN(C1)", eval.Number(-1)},
+		{"SQRT(B1)", eval.ErrDiv0},
+		{"ROUND(1)", eval.ErrValue},
+	})
+}
+
+func TestRand(t *testing.T) {
+	rand := []float64{0.25, 0, 0.999}
+	e := &eval.Evaluator{Funcs: testFuncs, Rand: func() float64 {
+		r := rand[0]
+		rand = rand[1:]
+		return r
+	}}
+	for _, test := range []struct {
+		src  string
+		want eval.Value
+	}{
+		{"RAND()", eval.Number(0.25)},
+		{"RANDBETWEEN(1.5,6)", eval.Number(2)},
+		{"RANDBETWEEN(-1,1)", eval.Number(1)},
+		{"RANDBETWEEN(2.5,2.7)", eval.ErrNum},
+	} {
+		x, err := parser.ParseBytes([]byte(test.src))
+		if err != nil {
+			t.Fatalf("ParseBytes(%q) %v", test.src, err)
+		}
+		if got := e.Eval(x); got != test.want {
+			t.Errorf("Eval(%q) = %v want %v", test.src, got, test.want)
+		}
+	}
+}
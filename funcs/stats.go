@@ -0,0 +1,726 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/types"
+	"math"
+	"sort"
+)
+
+// Stats holds the statistical functions and the conditional aggregates
+// such as COUNTIF and SUMIFS, whose criteria are read by ParseCriterion.
+// The older names STDEV, VAR, MODE and so on are kept as aliases.
+var Stats = []*eval.Func{
+	{Name: "MEDIAN", Sig: variadic(types.Number, num("number")), Call: aggregate(median)},
+	{Name: "MODE.SNGL", Sig: variadic(types.Number, num("number")), Call: aggregate(mode)},
+	{Name: "MODE.MULT", Sig: variadic(types.Array, num("number")), Call: aggregate(modeMult)},
+	{Name: "MODE", Sig: variadic(types.Number, num("number")), Call: aggregate(mode)},
+	{Name: "STDEV.S", Sig: variadic(types.Number, num("number")), Call: aggregate(stdev(true))},
+	{Name: "STDEV.P", Sig: variadic(types.Number, num("number")), Call: aggregate(stdev(false))},
+	{Name: "STDEV", Sig: variadic(types.Number, num("number")), Call: aggregate(stdev(true))},
+	{Name: "STDEVP", Sig: variadic(types.Number, num("number")), Call: aggregate(stdev(false))},
+	{Name: "VAR.S", Sig: variadic(types.Number, num("number")), Call: aggregate(variance(true))},
+	{Name: "VAR.P", Sig: variadic(types.Number, num("number")), Call: aggregate(variance(false))},
+	{Name: "VAR", Sig: variadic(types.Number, num("number")), Call: aggregate(variance(true))},
+	{Name: "VARP", Sig: variadic(types.Number, num("number")), Call: aggregate(variance(false))},
+	{Name: "PERCENTILE.INC", Sig: fixed(types.Number, val("array"), num("k")), Call: percentile(percentileInc, 1)},
+	{Name: "PERCENTILE.EXC", Sig: fixed(types.Number, val("array"), num("k")), Call: percentile(percentileExc, 1)},
+	{Name: "PERCENTILE", Sig: fixed(types.Number, val("array"), num("k")), Call: percentile(percentileInc, 1)},
+	{Name: "QUARTILE.INC", Sig: fixed(types.Number, val("array"), num("quart")), Call: percentile(percentileInc, 4)},
+	{Name: "QUARTILE.EXC", Sig: fixed(types.Number, val("array"), num("quart")), Call: percentile(percentileExc, 4)},
+	{Name: "QUARTILE", Sig: fixed(types.Number, val("array"), num("quart")), Call: percentile(percentileInc, 4)},
+	{Name: "RANK.EQ", Sig: fixed(types.Number, num("number"), param("ref", types.Reference), opt(num("order"))), Call: rank(false)},
+	{Name: "RANK.AVG", Sig: fixed(types.Number, num("number"), param("ref", types.Reference), opt(num("order"))), Call: rank(true)},
+	{Name: "RANK", Sig: fixed(types.Number, num("number"), param("ref", types.Reference), opt(num("order"))), Call: rank(false)},
+	{Name: "LARGE", Sig: fixed(types.Number, val("array"), num("k")), Call: kth(true)},
+	{Name: "SMALL", Sig: fixed(types.Number, val("array"), num("k")), Call: kth(false)},
+	{Name: "CORREL", Sig: fixed(types.Number, val("array1"), val("array2")), Call: paired(correl)},
+	{Name: "COVARIANCE.P", Sig: fixed(types.Number, val("array1"), val("array2")), Call: paired(covariance(false))},
+	{Name: "COVARIANCE.S", Sig: fixed(types.Number, val("array1"), val("array2")), Call: paired(covariance(true))},
+	{Name: "COVAR", Sig: fixed(types.Number, val("array1"), val("array2")), Call: paired(covariance(false))},
+	{Name: "SLOPE", Sig: fixed(types.Number, val("known_ys"), val("known_xs")), Call: paired(slope)},
+	{Name: "INTERCEPT", Sig: fixed(types.Number, val("known_ys"), val("known_xs")), Call: paired(intercept)},
+	{Name: "FORECAST.LINEAR", Sig: fixed(types.Number, num("x"), val("known_ys"), val("known_xs")), Call: forecast},
+	{Name: "FORECAST", Sig: fixed(types.Number, num("x"), val("known_ys"), val("known_xs")), Call: forecast},
+	{Name: "NORM.DIST", Sig: fixed(types.Number, num("x"), num("mean"), num("standard_dev"), param("cumulative", types.Logical)), Call: normDist},
+	{Name: "NORM.INV", Sig: fixed(types.Number, num("probability"), num("mean"), num("standard_dev")), Call: normInv},
+	{Name: "NORM.S.DIST", Sig: fixed(types.Number, num("z"), param("cumulative", types.Logical)), Call: normDist},
+	{Name: "NORM.S.INV", Sig: fixed(types.Number, num("probability")), Call: normInv},
+	{Name: "T.DIST", Sig: fixed(types.Number, num("x"), num("deg_freedom"), param("cumulative", types.Logical)), Call: tDist},
+	{Name: "T.DIST.2T", Sig: fixed(types.Number, num("x"), num("deg_freedom")), Call: tDistTails(2)},
+	{Name: "T.DIST.RT", Sig: fixed(types.Number, num("x"), num("deg_freedom")), Call: tDistTails(1)},
+	{Name: "CHISQ.TEST", Sig: fixed(types.Number, val("actual_range"), val("expected_range")), Call: chisqTest},
+
+	{Name: "COUNTIF", Sig: fixed(types.Number, val("range"), val("criteria")), Call: countifs},
+	{Name: "COUNTIFS", Sig: variadic(types.Number, val("criteria_range"), val("criteria")), Call: countifs},
+	{Name: "SUMIF", Sig: fixed(types.Number, val("range"), val("criteria"), opt(val("sum_range"))), Call: conditional(false, sum)},
+	{Name: "SUMIFS", Sig: variadic(types.Number, val("sum_range"), val("criteria_range"), val("criteria")), Call: conditional(true, sum)},
+	{Name: "AVERAGEIF", Sig: fixed(types.Number, val("range"), val("criteria"), opt(val("average_range"))), Call: conditional(false, average)},
+	{Name: "AVERAGEIFS", Sig: variadic(types.Number, val("average_range"), val("criteria_range"), val("criteria")), Call: conditional(true, average)},
+	{Name: "MAXIFS", Sig: variadic(types.Number, val("max_range"), val("criteria_range"), val("criteria")), Call: conditional(true, max)},
+	{Name: "MINIFS", Sig: variadic(types.Number, val("min_range"), val("criteria_range"), val("criteria")), Call: conditional(true, min)},
+}
+
+func median(xs []float64) eval.Value {
+	if len(xs) == 0 {
+		return eval.ErrNum
+	}
+	s := sorted(xs)
+	n := len(s)
+	if n%2 == 1 {
+		return eval.Number(s[n/2])
+	}
+	return eval.Number((s[n/2-1] + s[n/2]) / 2)
+}
+
+// sorted returns a sorted copy of xs.
+func sorted(xs []float64) []float64 {
+	s := append([]float64(nil), xs...)
+	sort.Float64s(s)
+	return s
+}
+
+// mode returns the most frequent number, the first one seen on ties.
+func mode(xs []float64) eval.Value {
+	ms := modes(xs)
+	if len(ms) == 0 {
+		return eval.ErrNA
+	}
+	return eval.Number(ms[0])
+}
+
+// modeMult returns the most frequent numbers as a column, in the order
+// they are first seen.
+func modeMult(xs []float64) eval.Value {
+	ms := modes(xs)
+	if len(ms) == 0 {
+		return eval.ErrNA
+	}
+	a := eval.NewArray(len(ms), 1)
+	for i, m := range ms {
+		a.Set(i, 0, eval.Number(m))
+	}
+	return a
+}
+
+// modes returns the numbers that occur most often, more than once, in
+// the order they are first seen.
+func modes(xs []float64) []float64 {
+	counts := make(map[float64]int)
+	n := 1
+	for _, x := range xs {
+		counts[x]++
+		if counts[x] > n {
+			n = counts[x]
+		}
+	}
+	if n == 1 {
+		return nil
+	}
+	var ms []float64
+	for _, x := range xs {
+		if counts[x] == n {
+			ms = append(ms, x)
+			counts[x] = 0
+		}
+	}
+	return ms
+}
+
+func mean(xs []float64) float64 {
+	s := 0.0
+	for _, x := range xs {
+		s += x
+	}
+	return s / float64(len(xs))
+}
+
+// variance returns the sample variance, or the population variance if
+// sample is not set.
+func variance(sample bool) func(xs []float64) eval.Value {
+	return func(xs []float64) eval.Value {
+		n := len(xs)
+		if sample {
+			n--
+		}
+		if n < 1 {
+			return eval.ErrDiv0
+		}
+		m, ss := mean(xs), 0.0
+		for _, x := range xs {
+			ss += (x - m) * (x - m)
+		}
+		return eval.NumberOrError(ss / float64(n))
+	}
+}
+
+func stdev(sample bool) func(xs []float64) eval.Value {
+	v := variance(sample)
+	return func(xs []float64) eval.Value {
+		r := v(xs)
+		if x, ok := r.(eval.Number); ok {
+			return eval.Number(math.Sqrt(float64(x)))
+		}
+		return r
+	}
+}
+
+// percentileInc interpolates the sorted numbers s at fraction k of the
+// way from the first to the last.
+func percentileInc(s []float64, k float64) eval.Value {
+	if k < 0 || k > 1 {
+		return eval.ErrNum
+	}
+	return interpolate(s, k*float64(len(s)-1))
+}
+
+// percentileExc interpolates the sorted numbers s at rank k(n+1), which
+// must lie within the data.
+func percentileExc(s []float64, k float64) eval.Value {
+	r := k*float64(len(s)+1) - 1
+	if r < 0 || r > float64(len(s)-1) {
+		return eval.ErrNum
+	}
+	return interpolate(s, r)
+}
+
+// interpolate returns the value at 0-based fractional position r in s.
+func interpolate(s []float64, r float64) eval.Value {
+	i := int(r)
+	if i+1 >= len(s) {
+		return eval.Number(s[len(s)-1])
+	}
+	return eval.Number(s[i] + (r-float64(i))*(s[i+1]-s[i]))
+}
+
+// percentile adapts the percentile and quartile functions; the second
+// argument is divided by parts, 4 for quartiles.
+func percentile(f func(s []float64, k float64) eval.Value, parts float64) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		xs, err := e.Numbers(args[:1])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		k, err := e.Number(args[1])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		if parts > 1 {
+			k = math.Trunc(k)
+		}
+		if len(xs) == 0 {
+			return eval.ErrNum
+		}
+		return f(sorted(xs), k/parts)
+	}
+}
+
+// rank adapts RANK.EQ and RANK.AVG, which ranks tied numbers by their
+// average position. Order 0 ranks the largest number first.
+func rank(avg bool) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		x, err := e.Number(args[0])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		xs, err := e.Numbers(args[1:2])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		order, err := optNumber(e, args, 2, 0)
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		before, ties := 0, 0
+		for _, y := range xs {
+			switch {
+			case y == x:
+				ties++
+			case order == 0 && y > x, order != 0 && y < x:
+				before++
+			}
+		}
+		if ties == 0 {
+			return eval.ErrNA
+		}
+		r := float64(before + 1)
+		if avg {
+			r += float64(ties-1) / 2
+		}
+		return eval.Number(r)
+	}
+}
+
+// kth adapts LARGE and SMALL.
+func kth(largest bool) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		xs, err := e.Numbers(args[:1])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		f, err := e.Number(args[1])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		k := int(math.Ceil(f))
+		if k < 1 || k > len(xs) {
+			return eval.ErrNum
+		}
+		s := sorted(xs)
+		if largest {
+			return eval.Number(s[len(s)-k])
+		}
+		return eval.Number(s[k-1])
+	}
+}
+
+// pairs returns the numbers of x and y at the positions where both hold
+// numbers. x and y must have the same number of values.
+func pairs(e *eval.Evaluator, x, y eval.Value) ([]float64, []float64, error) {
+	vx, vy := e.Values(x), e.Values(y)
+	if len(vx) != len(vy) {
+		return nil, nil, eval.ErrNA
+	}
+	var xs, ys []float64
+	for i := range vx {
+		for _, v := range []eval.Value{vx[i], vy[i]} {
+			if err, ok := v.(eval.Error); ok {
+				return nil, nil, err
+			}
+		}
+		a, ok := vx[i].(eval.Number)
+		b, ok2 := vy[i].(eval.Number)
+		if ok && ok2 {
+			xs, ys = append(xs, float64(a)), append(ys, float64(b))
+		}
+	}
+	return xs, ys, nil
+}
+
+// paired adapts a function of two arrays of paired numbers.
+func paired(f func(xs, ys []float64) eval.Value) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		xs, ys, err := pairs(e, args[0], args[1])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		return f(xs, ys)
+	}
+}
+
+// moments returns the sums of squared deviations of xs and ys and of
+// the products of their deviations.
+func moments(xs, ys []float64) (sxx, syy, sxy float64) {
+	mx, my := mean(xs), mean(ys)
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		sxx += dx * dx
+		syy += dy * dy
+		sxy += dx * dy
+	}
+	return sxx, syy, sxy
+}
+
+func correl(xs, ys []float64) eval.Value {
+	if len(xs) == 0 {
+		return eval.ErrDiv0
+	}
+	sxx, syy, sxy := moments(xs, ys)
+	if sxx == 0 || syy == 0 {
+		return eval.ErrDiv0
+	}
+	return eval.Number(sxy / math.Sqrt(sxx*syy))
+}
+
+func covariance(sample bool) func(xs, ys []float64) eval.Value {
+	return func(xs, ys []float64) eval.Value {
+		n := len(xs)
+		if sample {
+			n--
+		}
+		if n < 1 {
+			return eval.ErrDiv0
+		}
+		_, _, sxy := moments(xs, ys)
+		return eval.Number(sxy / float64(n))
+	}
+}
+
+// line fits ys = a + b*xs by least squares.
+func line(xs, ys []float64) (a, b float64, err error) {
+	if len(xs) == 0 {
+		return 0, 0, eval.ErrDiv0
+	}
+	sxx, _, sxy := moments(xs, ys)
+	if sxx == 0 {
+		return 0, 0, eval.ErrDiv0
+	}
+	b = sxy / sxx
+	return mean(ys) - b*mean(xs), b, nil
+}
+
+func slope(ys, xs []float64) eval.Value {
+	_, b, err := line(xs, ys)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	return eval.Number(b)
+}
+
+func intercept(ys, xs []float64) eval.Value {
+	a, _, err := line(xs, ys)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	return eval.Number(a)
+}
+
+func forecast(e *eval.Evaluator, args []eval.Value) eval.Value {
+	x, err := e.Number(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	ys, xs, err := pairs(e, args[1], args[2])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	a, b, err := line(xs, ys)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	return eval.Number(a + b*x)
+}
+
+// normDist implements NORM.DIST and, with two arguments, NORM.S.DIST.
+func normDist(e *eval.Evaluator, args []eval.Value) eval.Value {
+	n := len(args) - 1
+	x, err := e.Number(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	m, sd := 0.0, 1.0
+	if n == 3 {
+		if m, err = e.Number(args[1]); err != nil {
+			return eval.ErrorValue(err)
+		}
+		if sd, err = e.Number(args[2]); err != nil {
+			return eval.ErrorValue(err)
+		}
+	}
+	cum, err := e.Bool(args[n])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	if sd <= 0 {
+		return eval.ErrNum
+	}
+	z := (x - m) / sd
+	if cum {
+		return eval.Number(math.Erfc(-z/math.Sqrt2) / 2)
+	}
+	return eval.Number(math.Exp(-z*z/2) / (sd * math.Sqrt(2*math.Pi)))
+}
+
+// normInv implements NORM.INV and, with one argument, NORM.S.INV.
+func normInv(e *eval.Evaluator, args []eval.Value) eval.Value {
+	p, err := e.Number(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	m, sd := 0.0, 1.0
+	if len(args) == 3 {
+		if m, err = e.Number(args[1]); err != nil {
+			return eval.ErrorValue(err)
+		}
+		if sd, err = e.Number(args[2]); err != nil {
+			return eval.ErrorValue(err)
+		}
+	}
+	if p <= 0 || p >= 1 || sd <= 0 {
+		return eval.ErrNum
+	}
+	return eval.Number(m - sd*math.Sqrt2*math.Erfcinv(2*p))
+}
+
+// tArgs returns x and the degrees of freedom of the T.DIST functions.
+func tArgs(e *eval.Evaluator, args []eval.Value) (x, df float64, err error) {
+	if x, err = e.Number(args[0]); err != nil {
+		return 0, 0, err
+	}
+	if df, err = e.Number(args[1]); err != nil {
+		return 0, 0, err
+	}
+	df = math.Trunc(df)
+	if df < 1 {
+		return 0, 0, eval.ErrNum
+	}
+	return x, df, nil
+}
+
+// tTail returns the probability that a Student t variable with df
+// degrees of freedom exceeds |x|.
+func tTail(x, df float64) float64 {
+	return betaInc(df/(df+x*x), df/2, 0.5) / 2
+}
+
+func tDist(e *eval.Evaluator, args []eval.Value) eval.Value {
+	x, df, err := tArgs(e, args)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	cum, err := e.Bool(args[2])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	if !cum {
+		lg1, _ := math.Lgamma((df + 1) / 2)
+		lg2, _ := math.Lgamma(df / 2)
+		return eval.Number(math.Exp(lg1-lg2-(df+1)/2*math.Log1p(x*x/df)) / math.Sqrt(df*math.Pi))
+	}
+	if x < 0 {
+		return eval.Number(tTail(x, df))
+	}
+	return eval.Number(1 - tTail(x, df))
+}
+
+// tDistTails adapts T.DIST.2T and T.DIST.RT, the probabilities of
+// exceeding x in both tails or the right tail.
+func tDistTails(tails int) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		x, df, err := tArgs(e, args)
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		switch {
+		case tails == 2 && x < 0:
+			return eval.ErrNum
+		case tails == 2:
+			return eval.Number(2 * tTail(x, df))
+		case x < 0:
+			return eval.Number(1 - tTail(x, df))
+		}
+		return eval.Number(tTail(x, df))
+	}
+}
+
+// chisqTest returns the probability of the chi-squared statistic of the
+// actual and expected counts, with (rows-1)(cols-1) degrees of freedom,
+// or n-1 for a single row or column.
+func chisqTest(e *eval.Evaluator, args []eval.Value) eval.Value {
+	actual, err := newTable(e, args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	expected, err := newTable(e, args[1])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	if actual.rows != expected.rows || actual.cols != expected.cols {
+		return eval.ErrNA
+	}
+	os, es, err := pairs(e, args[0], args[1])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	chi := 0.0
+	for i := range os {
+		if es[i] == 0 {
+			return eval.ErrDiv0
+		}
+		chi += (os[i] - es[i]) * (os[i] - es[i]) / es[i]
+	}
+	df := (actual.rows - 1) * (actual.cols - 1)
+	if actual.rows == 1 || actual.cols == 1 {
+		df = actual.rows*actual.cols - 1
+	}
+	if df < 1 {
+		return eval.ErrNA
+	}
+	return eval.Number(gammaQ(float64(df)/2, chi/2))
+}
+
+// betaInc returns the regularized incomplete beta function I_x(a, b).
+func betaInc(x, a, b float64) float64 {
+	switch {
+	case x <= 0:
+		return 0
+	case x >= 1:
+		return 1
+	}
+	lab, _ := math.Lgamma(a + b)
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log1p(-x))
+	if x < (a+1)/(a+b+2) {
+		return front * betaFrac(x, a, b) / a
+	}
+	return 1 - front*betaFrac(1-x, b, a)/b
+}
+
+// betaFrac evaluates the continued fraction of the incomplete beta
+// function by the modified Lentz method.
+func betaFrac(x, a, b float64) float64 {
+	const tiny = 1e-300
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1.0; m <= 300; m++ {
+		for _, aa := range []float64{
+			m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m)),
+			-(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1)),
+		} {
+			d = 1 + aa*d
+			if math.Abs(d) < tiny {
+				d = tiny
+			}
+			c = 1 + aa/c
+			if math.Abs(c) < tiny {
+				c = tiny
+			}
+			d = 1 / d
+			h *= d * c
+		}
+		if math.Abs(d*c-1) < 1e-16 {
+			break
+		}
+	}
+	return h
+}
+
+// gammaQ returns the regularized upper incomplete gamma function Q(a, x).
+func gammaQ(a, x float64) float64 {
+	if x <= 0 {
+		return 1
+	}
+	lg, _ := math.Lgamma(a)
+	front := math.Exp(-x + a*math.Log(x) - lg)
+	if x < a+1 {
+		// series for P(a, x)
+		sum, term := 1/a, 1/a
+		for n := 1.0; n < 500; n++ {
+			term *= x / (a + n)
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*1e-16 {
+				break
+			}
+		}
+		return 1 - front*sum
+	}
+	// continued fraction for Q(a, x)
+	const tiny = 1e-300
+	b := x + 1 - a
+	c, d := 1/tiny, 1/b
+	h := d
+	for i := 1.0; i < 500; i++ {
+		an := -i * (i - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+		if math.Abs(d*c-1) < 1e-16 {
+			break
+		}
+	}
+	return front * h
+}
+
+// matching returns the positions, relative to the top left cell, at
+// which every range in crit satisfies the criterion that follows it,
+// and the table of the first range. All ranges must have the same shape.
+func matching(e *eval.Evaluator, crit []eval.Value) (*table, [][2]int, error) {
+	if len(crit) == 0 || len(crit)%2 != 0 {
+		return nil, nil, eval.ErrValue
+	}
+	var first *table
+	var pos [][2]int
+	for k := 0; k < len(crit); k += 2 {
+		t, err := newTable(e, crit[k])
+		if err != nil {
+			return nil, nil, err
+		}
+		c := ParseCriterion(e, crit[k+1])
+		if first == nil {
+			first = t
+			for i := 0; i < t.rows; i++ {
+				for j := 0; j < t.cols; j++ {
+					if c.Match(t.at(i, j)) {
+						pos = append(pos, [2]int{i, j})
+					}
+				}
+			}
+			continue
+		}
+		if t.rows != first.rows || t.cols != first.cols {
+			return nil, nil, eval.ErrValue
+		}
+		kept := pos[:0]
+		for _, p := range pos {
+			if c.Match(t.at(p[0], p[1])) {
+				kept = append(kept, p)
+			}
+		}
+		pos = kept
+	}
+	return first, pos, nil
+}
+
+func countifs(e *eval.Evaluator, args []eval.Value) eval.Value {
+	_, pos, err := matching(e, args)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	return eval.Number(len(pos))
+}
+
+// conditional adapts SUMIF, AVERAGEIF and their IFS forms, applying f to
+// the numbers of the target range at the positions matching the
+// criteria. The IFS forms take the target range first and require it
+// to match the criteria ranges in shape; the IF forms take it last,
+// default to the criteria range, and extend it to the same shape from
+// its top left cell. An array cannot be extended: its elements past the
+// end count as blank.
+func conditional(ifs bool, f func(xs []float64) eval.Value) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		target, crit := args[0], args[1:]
+		if !ifs {
+			crit = args[:2]
+			if len(args) > 2 {
+				target = args[2]
+			}
+		}
+		first, pos, err := matching(e, crit)
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		t, err := newTable(e, target)
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		if ifs && (t.rows != first.rows || t.cols != first.cols) {
+			return eval.ErrValue
+		}
+		var xs []float64
+		for _, p := range pos {
+			if t.arr != nil && (p[0] >= t.rows || p[1] >= t.cols) {
+				continue
+			}
+			switch v := t.at(p[0], p[1]).(type) {
+			case eval.Number:
+				xs = append(xs, float64(v))
+			case eval.Error:
+				return v
+			}
+		}
+		return f(xs)
+	}
+}
@@ -0,0 +1,123 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"math"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	runTests(t, []evalTest{
+		{"MEDIAN(F1:F4)", eval.Number(25)},
+		{"MEDIAN(1,3,2)", eval.Number(2)},
+		{"MEDIAN(G1:G4)", eval.ErrNum},
+		{"MODE.SNGL(1,3,3,2,2)", eval.Number(3)},
+		{"MODE(1,2,3)", eval.ErrNA},
+		{"VAR.S(F1:F4)", eval.Number(500.0 / 3)},
+		{"VAR.P(F1:F4)", eval.Number(125)},
+		{"STDEV.S(F1:F4)", eval.Number(12.909944487358056)},
+		{"STDEV.P(F1:F4)", eval.Number(11.180339887498949)},
+		{"VAR.S(1)", eval.ErrDiv0},
+		{"PERCENTILE.INC(F1:F4,0.25)", eval.Number(17.5)},
+		{"PERCENTILE.INC(F1:F4,1.5)", eval.ErrNum},
+		{"PERCENTILE.EXC(F1:F4,0.25)", eval.Number(12.5)},
+		{"PERCENTILE.EXC(F1:F4,0.1)", eval.ErrNum},
+		{"QUARTILE(F1:F4,2)", eval.Number(25)},
+		{"QUARTILE(F1:F4,5)", eval.ErrNum},
+		{"QUARTILE.EXC(F1:F4,1)", eval.Number(12.5)},
+		{"RANK.EQ(30,F1:F4)", eval.Number(2)},
+		{"RANK.EQ(30,F1:F4,1)", eval.Number(3)},
+		{"RANK(35,F1:F4)", eval.ErrNA},
+		{"RANK.EQ(20,J1:J3)", eval.Number(1)},
+		{"RANK.AVG(20,J1:J3)", eval.Number(2)},
+		{"LARGE(F1:F4,2)", eval.Number(30)},
+		{"SMALL(F1:F4,1)", eval.Number(10)},
+		{"LARGE(F1:F4,5)", eval.ErrNum},
+	})
+}
+
+func TestModeMult(t *testing.T) {
+	for src, want := range map[string]string{
+		"MODE.MULT(1,1,2,2,3)":     "{1;2}",
+		"MODE.MULT(3,1,1,3,2,3)":   "3",
+		"MODE.MULT(2,1,1,2)":       "{2;1}",
+		"MODE.MULT({1,2,3;3,2,1})": "{1;2;3}",
+		"MODE.MULT(1,2,3)":         "#N/A",
+	} {
+		if got := evalString(t, src).String(); got != want {
+			t.Errorf("Eval(%q) = %s want %s", src, got, want)
+		}
+	}
+}
+
+func TestRegression(t *testing.T) {
+	runTests(t, []evalTest{
+		{"CORREL(F1:F4,H1:H4)", eval.Number(1)},
+		{"CORREL(F1:F4,H1:H3)", eval.ErrNA},
+		{"CORREL(J1:J3,I1:I3)", eval.ErrDiv0},
+		{"COVARIANCE.P(F1:F4,H1:H4)", eval.Number(12.5)},
+		{"COVARIANCE.S(F1:F4,H1:H4)", eval.Number(50.0 / 3)},
+		{"SLOPE(H1:H4,F1:F4)", eval.Number(0.1)},
+		{"INTERCEPT(H1:H4,F1:F4)", eval.Number(0.5)},
+		{"FORECAST.LINEAR(50,H1:H4,F1:F4)", eval.Number(5.5)},
+		{"SLOPE(G1:G4,F1:F4)", eval.ErrDiv0},
+	})
+}
+
+func TestDistributions(t *testing.T) {
+	runTests(t, []evalTest{
+		{"NORM.DIST(1,0,1,TRUE)", eval.Number(0.841344746068543)},
+		{"NORM.DIST(1,0,1,FALSE)", eval.Number(0.241970724519143)},
+		{"NORM.DIST(1,0,0,TRUE)", eval.ErrNum},
+		{"NORM.S.DIST(-1,TRUE)", eval.Number(0.158655253931457)},
+		{"NORM.INV(0.975,0,1)", eval.Number(1.95996398454005)},
+		{"NORM.INV(0.5,10,2)", eval.Number(10)},
+		{"NORM.S.INV(0)", eval.ErrNum},
+		{"T.DIST(1,1,TRUE)", eval.Number(0.75)},
+		{"T.DIST(-1,1,TRUE)", eval.Number(0.25)},
+		{"T.DIST(0,1,FALSE)", eval.Number(1 / math.Pi)},
+		{"T.DIST(1,10,TRUE)", eval.Number(0.82955343384897)},
+		{"T.DIST(1,0,TRUE)", eval.ErrNum},
+		{"T.DIST.2T(1,1)", eval.Number(0.5)},
+		{"T.DIST.RT(1,1)", eval.Number(0.25)},
+		{"CHISQ.TEST(I1:I3,J1:J3)", eval.Number(math.Exp(-5))},
+		{"CHISQ.TEST(I1:I3,J1:J2)", eval.ErrNA},
+	})
+}
+
+func TestConditional(t *testing.T) {
+	runTests(t, []evalTest{
+		{`COUNTIF(F1:F4,">=20")`, eval.Number(3)},
+		{`COUNTIF(F1:F4,20)`, eval.Number(1)},
+		{`COUNTIF(F1:F4,"20")`, eval.Number(1)},
+		{`COUNTIF(A1:A5,3)`, eval.Number(1)},
+		{`COUNTIF(A1:A5,"<>2")`, eval.Number(4)},
+		{`COUNTIF(A1:A5,TRUE)`, eval.Number(1)},
+		{`COUNTIF(G1:G4,"*a*")`, eval.Number(3)},
+		{`COUNTIF(G1:G4,"BANANA")`, eval.Number(1)},
+		{`COUNTIF(G1:G4,"<c")`, eval.Number(2)},
+		{`COUNTIF(D1:D3,"")`, eval.Number(3)},
+		{`COUNTIF(A1:D1,"<>")`, eval.Number(3)},
+		{`COUNTIF(A1:D1,"#DIV/0!")`, eval.Number(1)},
+		{`COUNTIFS(F1:F4,">15",H1:H4,"<4")`, eval.Number(2)},
+		{`COUNTIFS(F1:F4,">15",H1:H3,"<4")`, eval.ErrValue},
+		{`SUMIF(F1:F4,">15")`, eval.Number(90)},
+		{`SUMIF(G1:G4,"b*",H1:H4)`, eval.Number(2.5)},
+		{`SUMIF(G1:G4,"?????",F1)`, eval.Number(10)},
+		{`SUMIFS(H1:H4,F1:F4,">=20",G1:G4,"<>date")`, eval.Number(6)},
+		{`SUMIFS(H1:H4,F1:F3,">0")`, eval.ErrValue},
+		{`AVERAGEIF(F1:F4,">15")`, eval.Number(30)},
+		{`AVERAGEIF(F1:F4,">100")`, eval.ErrDiv0},
+		{`AVERAGEIFS(H1:H4,F1:F4,"<=20")`, eval.Number(2)},
+		{`SUMIF({1,2,3},">1",{1})`, eval.Number(0)},
+		{`SUMIF({1,2,3},">0",{5;6})`, eval.Number(5)},
+		{`AVERAGEIF({1,2,3},"<3",{4,6})`, eval.Number(5)},
+		{`AVERAGEIF({1,2,3},">1",{4})`, eval.ErrDiv0},
+		{`SUMIFS({1},{1,2,3},">1")`, eval.ErrValue},
+		{`AVERAGEIFS({1,2},{1,2,3},">0")`, eval.ErrValue},
+		{`MAXIFS({1,2},{1,2,3},">0")`, eval.ErrValue},
+		{`MAXIFS(H1:H4,G1:G4,"<>apple")`, eval.Number(4.5)},
+		{`MINIFS(H1:H4,F1:F4,">10")`, eval.Number(2.5)},
+		{`MAXIFS(H1:H4,F1:F4,">100")`, eval.Number(0)},
+	})
+}
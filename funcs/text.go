@@ -0,0 +1,485 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/format"
+	"github.com/ajz01/calc/types"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Text holds the text functions. Positions and lengths count Unicode
+// code points, not bytes.
+var Text = []*eval.Func{
+	{Name: "LEN", Sig: fixed(types.Number, text("text")), Call: text1(func(s []rune) eval.Value { return eval.Number(len(s)) })},
+	{Name: "LEFT", Sig: fixed(types.Text, text("text"), opt(num("count"))), Call: left},
+	{Name: "RIGHT", Sig: fixed(types.Text, text("text"), opt(num("count"))), Call: right},
+	{Name: "MID", Sig: fixed(types.Text, text("text"), num("start"), num("count")), Call: mid},
+	{Name: "UPPER", Sig: fixed(types.Text, text("text")), Call: text1(func(s []rune) eval.Value { return eval.Text(strings.ToUpper(string(s))) })},
+	{Name: "LOWER", Sig: fixed(types.Text, text("text")), Call: text1(func(s []rune) eval.Value { return eval.Text(strings.ToLower(string(s))) })},
+	{Name: "PROPER", Sig: fixed(types.Text, text("text")), Call: text1(proper)},
+	{Name: "TRIM", Sig: fixed(types.Text, text("text")), Call: text1(trim)},
+	{Name: "CONCATENATE", Sig: variadic(types.Text, text("text")), Call: concatenate},
+	{Name: "CONCAT", Sig: variadic(types.Text, val("text")), Call: concat},
+	{Name: "TEXTJOIN", Sig: variadic(types.Text, text("delimiter"), param("ignore_empty", types.Logical), val("text")), Call: textjoin},
+	{Name: "FIND", Sig: fixed(types.Number, text("find_text"), text("within_text"), opt(num("start"))), Call: find(false)},
+	{Name: "SEARCH", Sig: fixed(types.Number, text("find_text"), text("within_text"), opt(num("start"))), Call: find(true)},
+	{Name: "SUBSTITUTE", Sig: fixed(types.Text, text("text"), text("old_text"), text("new_text"), opt(num("instance"))), Call: substitute},
+	{Name: "REPLACE", Sig: fixed(types.Text, text("old_text"), num("start"), num("count"), text("new_text")), Call: replace},
+	{Name: "REPT", Sig: fixed(types.Text, text("text"), num("count")), Call: rept},
+	{Name: "EXACT", Sig: fixed(types.Logical, text("text1"), text("text2")), Call: exact},
+	{Name: "TEXT", Sig: fixed(types.Text, val("value"), text("format_text")), Call: textFunc},
+	{Name: "VALUE", Sig: fixed(types.Number, text("text")), Call: value},
+	{Name: "CHAR", Sig: fixed(types.Text, num("number")), Call: fn1(char)},
+	{Name: "CODE", Sig: fixed(types.Number, text("text")), Call: text1(code)},
+	{Name: "UNICHAR", Sig: fixed(types.Text, num("number")), Call: fn1(unichar)},
+}
+
+// texts converts the first n arguments to text.
+func texts(e *eval.Evaluator, args []eval.Value, n int) ([]string, error) {
+	s := make([]string, n)
+	for i := range s {
+		t, err := e.Text(args[i])
+		if err != nil {
+			return nil, err
+		}
+		s[i] = t
+	}
+	return s, nil
+}
+
+// text1 adapts a function of one text argument.
+func text1(f func(s []rune) eval.Value) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		s, err := e.Text(args[0])
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		return f([]rune(s))
+	}
+}
+
+// countArg returns argument i as a non-negative character count. Counts
+// beyond the longest text are cut to its length.
+func countArg(e *eval.Evaluator, args []eval.Value, i int, def float64) (int, error) {
+	n, err := optNumber(e, args, i, def)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, eval.ErrValue
+	}
+	return int(math.Min(n, maxText)), nil
+}
+
+func left(e *eval.Evaluator, args []eval.Value) eval.Value {
+	s, err := e.Text(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	n, err := countArg(e, args, 1, 1)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	r := []rune(s)
+	if n > len(r) {
+		n = len(r)
+	}
+	return eval.Text(r[:n])
+}
+
+func right(e *eval.Evaluator, args []eval.Value) eval.Value {
+	s, err := e.Text(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	n, err := countArg(e, args, 1, 1)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	r := []rune(s)
+	if n > len(r) {
+		n = len(r)
+	}
+	return eval.Text(r[len(r)-n:])
+}
+
+func mid(e *eval.Evaluator, args []eval.Value) eval.Value {
+	s, err := e.Text(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	start, err := e.Number(args[1])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	n, err := countArg(e, args, 2, 0)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	if start < 1 {
+		return eval.ErrValue
+	}
+	r := []rune(s)
+	i := int(math.Min(start, maxText+1)) - 1
+	if i > len(r) {
+		i = len(r)
+	}
+	if i+n > len(r) {
+		n = len(r) - i
+	}
+	return eval.Text(r[i : i+n])
+}
+
+// proper capitalizes the first letter of every word and lower-cases the
+// rest. Any character that is not a letter starts a new word.
+func proper(s []rune) eval.Value {
+	word := false
+	for i, ch := range s {
+		if unicode.IsLetter(ch) {
+			if word {
+				s[i] = unicode.ToLower(ch)
+			} else {
+				s[i] = unicode.ToTitle(ch)
+			}
+			word = true
+		} else {
+			word = false
+		}
+	}
+	return eval.Text(s)
+}
+
+// trim removes leading and trailing spaces and collapses runs of spaces.
+func trim(s []rune) eval.Value {
+	return eval.Text(strings.Join(strings.FieldsFunc(string(s), func(ch rune) bool { return ch == ' ' }), " "))
+}
+
+func concatenate(e *eval.Evaluator, args []eval.Value) eval.Value {
+	s, err := texts(e, args, len(args))
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	return eval.Text(strings.Join(s, ""))
+}
+
+// join converts the values of args, including all cells of references,
+// to text and joins them with sep.
+func join(e *eval.Evaluator, args []eval.Value, sep string, skipEmpty bool) eval.Value {
+	var b strings.Builder
+	first := true
+	for _, a := range args {
+		for _, v := range e.Values(a) {
+			s, err := eval.ToText(v)
+			if err != nil {
+				return eval.ErrorValue(err)
+			}
+			if skipEmpty && s == "" {
+				continue
+			}
+			if !first {
+				b.WriteString(sep)
+			}
+			b.WriteString(s)
+			first = false
+		}
+	}
+	return eval.Text(b.String())
+}
+
+func concat(e *eval.Evaluator, args []eval.Value) eval.Value {
+	return join(e, args, "", false)
+}
+
+func textjoin(e *eval.Evaluator, args []eval.Value) eval.Value {
+	sep, err := e.Text(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	skip, err := e.Bool(args[1])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	return join(e, args[2:], sep, skip)
+}
+
+// find returns FIND, or SEARCH if search is set. SEARCH ignores case and
+// supports the wildcards ? and *, escaped with ~.
+func find(search bool) func(*eval.Evaluator, []eval.Value) eval.Value {
+	return func(e *eval.Evaluator, args []eval.Value) eval.Value {
+		s, err := texts(e, args, 2)
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		start, err := optNumber(e, args, 2, 1)
+		if err != nil {
+			return eval.ErrorValue(err)
+		}
+		pat, within := []rune(s[0]), []rune(s[1])
+		if start < 1 || start > float64(len(within)+1) {
+			return eval.ErrValue
+		}
+		if search {
+			// fold per rune so that positions are kept
+			for i, ch := range pat {
+				pat[i] = unicode.ToLower(ch)
+			}
+			for i, ch := range within {
+				within[i] = unicode.ToLower(ch)
+			}
+		}
+		for i := int(start) - 1; i <= len(within); i++ {
+			if search && matchWildcard(pat, within[i:], true) ||
+				!search && hasPrefix(within[i:], pat) {
+				return eval.Number(i + 1)
+			}
+		}
+		return eval.ErrValue
+	}
+}
+
+func hasPrefix(s, prefix []rune) bool {
+	if len(prefix) > len(s) {
+		return false
+	}
+	for i, ch := range prefix {
+		if s[i] != ch {
+			return false
+		}
+	}
+	return true
+}
+
+// matchWildcard reports whether pattern pat matches s, or a prefix of s
+// if prefix is set. In pat ? matches any character, * any sequence of
+// characters and ~ escapes the character that follows.
+func matchWildcard(pat, s []rune, prefix bool) bool {
+	for len(pat) > 0 {
+		switch pat[0] {
+		case '*':
+			for len(pat) > 0 && pat[0] == '*' {
+				pat = pat[1:]
+			}
+			if len(pat) == 0 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchWildcard(pat, s[i:], prefix) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		case '~':
+			if len(pat) > 1 {
+				pat = pat[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || s[0] != pat[0] {
+				return false
+			}
+		}
+		pat, s = pat[1:], s[1:]
+	}
+	return prefix || len(s) == 0
+}
+
+func substitute(e *eval.Evaluator, args []eval.Value) eval.Value {
+	s, err := texts(e, args, 3)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	if len(args) < 4 {
+		if s[1] == "" {
+			return eval.Text(s[0])
+		}
+		return eval.Text(strings.Replace(s[0], s[1], s[2], -1))
+	}
+	n, err := e.Number(args[3])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	if n < 1 {
+		return eval.ErrValue
+	}
+	if s[1] == "" {
+		return eval.Text(s[0])
+	}
+	text, i := s[0], 0
+	for k := int(n); ; k-- {
+		j := strings.Index(text[i:], s[1])
+		if j < 0 {
+			return eval.Text(text)
+		}
+		i += j
+		if k == 1 {
+			return eval.Text(text[:i] + s[2] + text[i+len(s[1]):])
+		}
+		i += len(s[1])
+	}
+}
+
+func replace(e *eval.Evaluator, args []eval.Value) eval.Value {
+	old, err := e.Text(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	start, err := e.Number(args[1])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	n, err := countArg(e, args, 2, 0)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	repl, err := e.Text(args[3])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	if start < 1 {
+		return eval.ErrValue
+	}
+	r := []rune(old)
+	i := int(math.Min(start, maxText+1)) - 1
+	if i > len(r) {
+		i = len(r)
+	}
+	j := i + n
+	if j > len(r) {
+		j = len(r)
+	}
+	return eval.Text(string(r[:i]) + repl + string(r[j:]))
+}
+
+// maxText is the longest text a cell can hold.
+const maxText = 32767
+
+func rept(e *eval.Evaluator, args []eval.Value) eval.Value {
+	s, err := e.Text(args[0])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	n, err := optNumber(e, args, 1, 0)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	if n < 0 || float64(len([]rune(s)))*math.Trunc(n) > maxText {
+		return eval.ErrValue
+	}
+	return eval.Text(strings.Repeat(s, int(math.Min(n, maxText))))
+}
+
+func exact(e *eval.Evaluator, args []eval.Value) eval.Value {
+	s, err := texts(e, args, 2)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	return eval.Bool(s[0] == s[1])
+}
+
+// textFunc formats a number, or text that looks like one, with a format
+// code. Other text goes through the text section of the format.
+func textFunc(e *eval.Evaluator, args []eval.Value) eval.Value {
+	v := e.Deref(args[0])
+	if eval.IsError(v) {
+		return v
+	}
+	code, err := e.Text(args[1])
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	f, err := format.Parse(code)
+	if err != nil {
+		return eval.ErrValue
+	}
+	var x float64
+	switch v := v.(type) {
+	case eval.Number:
+		x = float64(v)
+	case eval.Blank:
+	case eval.Text:
+		n, ok := eval.ParseNumber(string(v))
+		if !ok {
+			return eval.Text(f.Text(string(v)))
+		}
+		x = n
+	default:
+		s, _ := eval.ToText(v)
+		return eval.Text(f.Text(s))
+	}
+	s, err := f.Number(x, e.Date1904)
+	if err != nil {
+		return eval.ErrValue
+	}
+	return eval.Text(s)
+}
+
+func value(e *eval.Evaluator, args []eval.Value) eval.Value {
+	v := e.Deref(args[0])
+	switch v.(type) {
+	case eval.Number, eval.Error:
+		return v
+	case eval.Blank:
+		return eval.Number(0)
+	}
+	s, err := eval.ToText(v)
+	if err != nil {
+		return eval.ErrorValue(err)
+	}
+	if f, ok := eval.ParseNumber(s); ok {
+		return eval.Number(f)
+	}
+	// date and time text as for DATEVALUE and TIMEVALUE
+	dt, ok := parseDateTime(s, e.Now().Year(), e.Date1904)
+	if !ok {
+		return eval.ErrValue
+	}
+	return eval.Number(float64(dt.days) + dt.secs/86400)
+}
+
+// cp1252 maps the codes 0x80-0x9F of the Windows-1252 character set used
+// by CHAR and CODE; other codes below 256 are Latin-1.
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+func char(x float64) eval.Value {
+	n := int(x)
+	switch {
+	case n < 1 || n > 255:
+		return eval.ErrValue
+	case 0x80 <= n && n < 0xA0:
+		return eval.Text(cp1252[n-0x80])
+	}
+	return eval.Text(rune(n))
+}
+
+func code(s []rune) eval.Value {
+	if len(s) == 0 {
+		return eval.ErrValue
+	}
+	ch := s[0]
+	if ch < 0x80 || 0xA0 <= ch && ch < 0x100 {
+		return eval.Number(ch)
+	}
+	for i, c := range cp1252 {
+		if c == ch {
+			return eval.Number(0x80 + i)
+		}
+	}
+	return eval.Number('?')
+}
+
+func unichar(x float64) eval.Value {
+	n := int(x)
+	if n < 1 || n > unicode.MaxRune || 0xD800 <= n && n < 0xE000 {
+		return eval.ErrValue
+	}
+	return eval.Text(rune(n))
+}
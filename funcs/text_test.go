@@ -0,0 +1,84 @@
+package funcs
+
+import (
+	"github.com/ajz01/calc/eval"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	runTests(t, []evalTest{
+		{`LEN("héllo wörld")`, eval.Number(11)},
+		{`LEN(A1)`, eval.Number(1)},
+		{`LEFT("日本語テキスト",3)`, eval.Text("日本語")},
+		{`LEFT("abc")`, eval.Text("a")},
+		{`LEFT("abc",-1)`, eval.ErrValue},
+		{"LEFT(1,1E300)", eval.Text("1")},
+		{"RIGHT(1,1E300)", eval.Text("1")},
+		{`RIGHT("straße",3)`, eval.Text("aße")},
+		{`MID("Fluid Flow",7,20)`, eval.Text("Flow")},
+		{`MID("abc",5,1)`, eval.Text("")},
+		{`MID("abc",0,1)`, eval.ErrValue},
+		{`MID("abc",1E300,1E300)`, eval.Text("")},
+		{`UPPER("straße")`, eval.Text("STRAßE")},
+		{`LOWER("ÀÉÎ")`, eval.Text("àéî")},
+		{`PROPER("this is a TITLE, o'neil 2nd")`, eval.Text("This Is A Title, O'Neil 2Nd")},
+		{`TRIM("  a   b  ")`, eval.Text("a b")},
+		{`CONCATENATE("a",1,TRUE)`, eval.Text("a1TRUE")},
+		{`CONCAT(A1:A3,"!")`, eval.Text("123!")},
+		{`TEXTJOIN(", ",TRUE,A1:A3,D1,"x")`, eval.Text("1, 2, 3, x")},
+		{`TEXTJOIN("-",FALSE,A1,D1,A2)`, eval.Text("1--2")},
+		{`FIND("b","abcb")`, eval.Number(2)},
+		{`FIND("b","abcb",3)`, eval.Number(4)},
+		{`FIND("B","abc")`, eval.ErrValue},
+		{`FIND("b","abc",1E300)`, eval.ErrValue},
+		{`FIND("","abc")`, eval.Number(1)},
+		{`SEARCH("B","abc")`, eval.Number(2)},
+		{`SEARCH("c?e","abcdef")`, eval.Number(3)},
+		{`SEARCH("b*e","abcdef")`, eval.Number(2)},
+		{`SEARCH("~*","a*b")`, eval.Number(2)},
+		{`SEARCH("ö","ÄÖÜ")`, eval.Number(2)},
+		{`SEARCH("x","İx")`, eval.Number(2)},
+		{`SUBSTITUTE("a-b-c","-","+")`, eval.Text("a+b+c")},
+		{`SUBSTITUTE("a-b-c","-","+",2)`, eval.Text("a-b+c")},
+		{`SUBSTITUTE("a-b-c","-","+",3)`, eval.Text("a-b-c")},
+		{`REPLACE("abcdef",2,3,"XY")`, eval.Text("aXYef")},
+		{`REPLACE("äöü",2,1,"o")`, eval.Text("äoü")},
+		{"REPLACE(1,1,1E300,1)", eval.Text("1")},
+		{"REPLACE(1,1E300,1,2)", eval.Text("12")},
+		{`REPT("ab",3)`, eval.Text("ababab")},
+		{"REPT(1,1E300)", eval.ErrValue},
+		{`REPT("",1E300)`, eval.Text("")},
+		{`EXACT("a","A")`, eval.Bool(false)},
+		{`VALUE("1,234.5")`, eval.Number(1234.5)},
+		{`VALUE("50%")`, eval.Number(0.5)},
+		{`VALUE("(12)")`, eval.Number(-12)},
+		{`VALUE("abc")`, eval.ErrValue},
+		{`VALUE("12:00")`, eval.Number(0.5)},
+		{`VALUE("2024-01-15")`, eval.Number(45306)},
+		{`VALUE("2024-01-15 6:00 PM")`, eval.Number(45306.75)},
+		{`CHAR(65)`, eval.Text("A")},
+		{`CHAR(128)`, eval.Text("€")},
+		{`CODE("€uro")`, eval.Number(128)},
+		{`CODE("é")`, eval.Number(233)},
+		{`UNICHAR(8364)`, eval.Text("€")},
+		{`UNICHAR(0)`, eval.ErrValue},
+		{`"say ""hi"""`, eval.Text(`say "hi"`)},
+	})
+}
+
+func TestTextFormat(t *testing.T) {
+	runTests(t, []evalTest{
+		{`TEXT(1234.567,"#,##0.00")`, eval.Text("1,234.57")},
+		{`TEXT(0.256,"0%")`, eval.Text("26%")},
+		{`TEXT(3.14159,"# ?/?")`, eval.Text("3 1/7")},
+		{`TEXT(-1234,"$#,##0;[Red]($#,##0)")`, eval.Text("($1,234)")},
+		{`TEXT(45000.5,"yyyy-mm-dd hh:mm")`, eval.Text("2023-03-15 12:00")},
+		{`TEXT("abc","@!")`, eval.Text("abc!")},
+		{`TEXT("12.5","0.00")`, eval.Text("12.50")},
+		{`TEXT(A1,"000")`, eval.Text("001")},
+		{`TEXT(TRUE,"0")`, eval.Text("TRUE")},
+		{`TEXT(-1,"yyyy")`, eval.ErrValue},
+		{`TEXT(1,"""x")`, eval.ErrValue},
+		{`TEXT(B1,"0")`, eval.ErrDiv0},
+	})
+}
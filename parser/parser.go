@@ -7,6 +7,19 @@ import (
 	"github.com/ajz01/calc/token"
 )
 
+// A Mode value is a set of flags (or 0). They control the syntax
+// accepted by the parser.
+type Mode uint
+
+const (
+	// StrictNumbers accepts only spreadsheet number literals. Without
+	// it Go-style literals such as 0x1F, 0b101 and 1_000 are accepted.
+	StrictNumbers Mode = 1 << iota
+	// R1C1 accepts references in R1C1 notation, such as R[-1]C, instead
+	// of A1 notation.
+	R1C1
+)
+
 type parser struct {
 	errors  scanner.ErrorList
 	scanner scanner.Scanner
@@ -25,9 +38,16 @@ type parser struct {
 	targetStack [][]*ast.Ident
 }
 
-func (p *parser) init(src []byte) {
+func (p *parser) init(src []byte, mode Mode) {
 	eh := func(pos token.Position, msg string) { p.errors.Add(pos, msg) }
-	p.scanner.Init(src, eh)
+	var m scanner.Mode
+	if mode&StrictNumbers != 0 {
+		m |= scanner.StrictNumbers
+	}
+	if mode&R1C1 != 0 {
+		m |= scanner.R1C1
+	}
+	p.scanner.Init(src, eh, m)
 	p.trace = Trace
 	p.next()
 }
@@ -251,7 +271,7 @@ func (p *parser) parseOperand(lhs bool) ast.Expr {
 		x := p.parseIdent()
 		return x
 
-	case token.INT, token.FLOAT, token.IMAG, token.STRING, token.REF, token.RNG:
+	case token.INT, token.FLOAT, token.IMAG, token.STRING, token.BOOL, token.ERR, token.ERREF, token.REF, token.RNG:
 		x := &ast.BasicLit{ValuePos: p.pos, Kind: p.tok, Value: p.lit}
 		p.next()
 		return x
@@ -263,6 +283,9 @@ func (p *parser) parseOperand(lhs bool) ast.Expr {
 		rparen := p.expect(token.RPAREN)
 		return &ast.ParenExpr{Lparen: lparen, X: x, Rparen: rparen}
 
+	case token.LBRACE:
+		return p.parseArrayLit()
+
 	//case token.FUNC:
 		//return p.parseFuncType()
 	}
@@ -274,6 +297,61 @@ func (p *parser) parseOperand(lhs bool) ast.Expr {
 	return &ast.BadExpr{From: pos, To: p.pos}
 }
 
+// parseArrayLit parses an array constant. Commas separate the elements
+// of a row and semicolons the rows. Elements are numbers, optionally
+// negated, text or logical values.
+func (p *parser) parseArrayLit() *ast.ArrayLit {
+	if p.trace {
+		defer un(trace(p, "ArrayLit"))
+	}
+
+	lbrace := p.expect(token.LBRACE)
+	var rows [][]ast.Expr
+	var row []ast.Expr
+	for p.tok != token.EOF {
+		row = append(row, p.parseArrayElem())
+		if p.tok == token.SEMICOLON {
+			rows, row = append(rows, row), nil
+		} else if p.tok != token.COMMA {
+			break
+		}
+		p.next()
+	}
+	rows = append(rows, row)
+	rbrace := p.expectClosing(token.RBRACE, "array constant")
+	for _, r := range rows[1:] {
+		if len(r) != len(rows[0]) {
+			p.error(lbrace, "array constant rows differ in length")
+			break
+		}
+	}
+	return &ast.ArrayLit{Lbrace: lbrace, Rows: rows, Rbrace: rbrace}
+}
+
+func (p *parser) parseArrayElem() ast.Expr {
+	pos := p.pos
+	if p.tok == token.SUB || p.tok == token.ADD {
+		op := p.tok
+		p.next()
+		switch p.tok {
+		case token.INT, token.FLOAT, token.IMAG:
+			x := &ast.BasicLit{ValuePos: p.pos, Kind: p.tok, Value: p.lit}
+			p.next()
+			return &ast.UnaryExpr{OpPos: pos, Op: op, X: x}
+		}
+	} else {
+		switch p.tok {
+		case token.INT, token.FLOAT, token.IMAG, token.STRING, token.BOOL, token.ERR, token.ERREF:
+			x := &ast.BasicLit{ValuePos: p.pos, Kind: p.tok, Value: p.lit}
+			p.next()
+			return x
+		}
+	}
+	p.errorExpected(p.pos, "constant in array")
+	p.next()
+	return &ast.BadExpr{From: pos, To: p.pos}
+}
+
 func (p *parser) checkExpr(x ast.Expr) ast.Expr {
 	switch unparen(x).(type) {
 	case *ast.BadExpr:
@@ -284,6 +362,7 @@ func (p *parser) checkExpr(x ast.Expr) ast.Expr {
 	case *ast.UnaryExpr:
 	case *ast.BinaryExpr:
 	case *ast.CallExpr:
+	case *ast.ArrayLit:
 	default:
 		p.errorExpected(x.Pos(), "expression")
 		x = &ast.BadExpr{From: x.Pos(), To: x.End()}
@@ -305,12 +384,19 @@ func (p *parser) parseCall(fun ast.Expr) *ast.CallExpr {
 
 	lparen := p.expect(token.LPAREN)
 	var list []ast.Expr
-	for p.tok != token.RPAREN && p.tok != token.EOF {
-		list = append(list, p.parseRhs())
-		if !p.atComma("argument list", token.RPAREN) {
-			break
+	if p.tok != token.RPAREN {
+		for p.tok != token.EOF {
+			if p.tok == token.COMMA || p.tok == token.RPAREN {
+				// omitted argument
+				list = append(list, &ast.EmptyExpr{Comma: p.pos})
+			} else {
+				list = append(list, p.parseRhs())
+			}
+			if !p.atComma("argument list", token.RPAREN) {
+				break
+			}
+			p.next()
 		}
-		p.next()
 	}
 	rparen := p.expectClosing(token.RPAREN, "argument list")
 
@@ -340,6 +426,9 @@ L:
 		switch p.tok {
 		case token.LPAREN:
 			x = p.parseCall(p.checkExpr(x))
+		case token.SPILL:
+			x = &ast.UnaryExpr{OpPos: p.pos, Op: token.SPILL, X: p.checkExpr(x)}
+			p.next()
 		default:
 			break L
 		}
@@ -355,7 +444,7 @@ func (p *parser) parseUnaryExpr(lhs bool) ast.Expr {
 	}
 
 	switch p.tok {
-	case token.ADD, token.SUB, token.NOT, token.MUL:
+	case token.ADD, token.SUB, token.NOT, token.MUL, token.AT:
 		pos, op := p.pos, p.tok
 		p.next()
 		x := p.parseUnaryExpr(false)
@@ -415,9 +504,15 @@ func (p *parser) parseBytes() ast.Expr {
 	return p.parseExpr(true)
 }
 
-const Trace = true
+const Trace = false
 
+// ParseBytes parses the formula src, accepting Go-style number literals.
 func ParseBytes(src []byte) (f ast.Expr, err error) {
+	return ParseMode(src, 0)
+}
+
+// ParseMode parses the formula src with the syntax selected by mode.
+func ParseMode(src []byte, mode Mode) (f ast.Expr, err error) {
 	var p parser
 	defer func() {
 		if f == nil {
@@ -428,8 +523,11 @@ func ParseBytes(src []byte) (f ast.Expr, err error) {
 		err = p.errors.Err()
 	}()
 
-	p.init(src)
+	p.init(src, mode)
 	f = p.parseBytes()
+	if p.tok != token.EOF {
+		p.errorExpected(p.pos, "end of formula")
+	}
 
 	return
 }
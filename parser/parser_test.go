@@ -2,6 +2,7 @@ package parser
 
 import (
 	"github.com/ajz01/calc/ast"
+	"github.com/ajz01/calc/token"
 	"testing"
 )
 
@@ -90,3 +91,133 @@ func TestParseFuncAreaRange(t *testing.T) {
 		t.Errorf("ParseExpr(%q): got %T, want *ast.BinaryExpr", src, e)
 	}
 }
+
+func TestParseMode(t *testing.T) {
+	for _, src := range []string{"0x1F", "0b101+1", "1_000"} {
+		if _, err := ParseMode([]byte(src), 0); err != nil {
+			t.Errorf("ParseMode(%q, 0) %v", src, err)
+		}
+		if _, err := ParseMode([]byte(src), StrictNumbers); err == nil {
+			t.Errorf("ParseMode(%q, StrictNumbers) succeeded, want error", src)
+		}
+	}
+	src := "010+2.5e3"
+	if _, err := ParseMode([]byte(src), StrictNumbers); err != nil {
+		t.Errorf("ParseMode(%q, StrictNumbers) %v", src, err)
+	}
+}
+
+func TestParseR1C1(t *testing.T) {
+	x, err := ParseMode([]byte("SUM(R1C1:R[-1]C)+RC[2]"), R1C1)
+	if err != nil {
+		t.Fatalf("ParseMode R1C1 %v", err)
+	}
+	var lits []string
+	ast.Inspect(x, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.BasicLit); ok {
+			lits = append(lits, lit.Value)
+		}
+		return true
+	})
+	if len(lits) != 2 || lits[0] != "R1C1:R[-1]C" || lits[1] != "RC[2]" {
+		t.Errorf("ParseMode R1C1 references = %q", lits)
+	}
+}
+
+func TestParseSpill(t *testing.T) {
+	src := "SUM(D2#)+@A1:A3"
+	e, err := parse(src)
+	if err != nil {
+		t.Fatalf("ParseExpr(%q) %v", src, err)
+	}
+	b := e.(*ast.BinaryExpr)
+	spill, ok := b.X.(*ast.CallExpr).Args[0].(*ast.UnaryExpr)
+	if !ok || spill.Op != token.SPILL || spill.Pos() != 5 || spill.End() != 8 {
+		t.Errorf("ParseExpr(%q): got %#v, want D2# spill", src, b.X.(*ast.CallExpr).Args[0])
+	}
+	if at, ok := b.Y.(*ast.UnaryExpr); !ok || at.Op != token.AT {
+		t.Errorf("ParseExpr(%q): got %#v, want @ intersection", src, b.Y)
+	}
+	if _, err := parse("1#"); err == nil {
+		t.Errorf("ParseExpr(%q) succeeded, want error", "1#")
+	}
+}
+
+func TestParseLambdaCall(t *testing.T) {
+	src := "LAMBDA(a,b,a+b)(1,2)"
+	e, err := parse(src)
+	if err != nil {
+		t.Fatalf("ParseExpr(%q) %v", src, err)
+	}
+	call, ok := e.(*ast.CallExpr)
+	if !ok || len(call.Args) != 2 {
+		t.Fatalf("ParseExpr(%q): got %T, want *ast.CallExpr with 2 arguments", src, e)
+	}
+	fun, ok := call.Fun.(*ast.CallExpr)
+	if !ok || len(fun.Args) != 3 {
+		t.Fatalf("ParseExpr(%q): got callee %T, want LAMBDA call", src, call.Fun)
+	}
+	if _, ok := fun.Args[0].(*ast.Ident); !ok {
+		t.Errorf("ParseExpr(%q): got parameter %T, want *ast.Ident", src, fun.Args[0])
+	}
+}
+
+func TestParseOmittedArgs(t *testing.T) {
+	for _, test := range []struct {
+		src   string
+		empty []bool // whether each argument is omitted
+	}{
+		{"F()", nil},
+		{"F(1)", []bool{false}},
+		{"SORT(A1:B5,,-1)", []bool{false, true, false}},
+		{"F(,)", []bool{true, true}},
+		{"F(1,)", []bool{false, true}},
+		{"F( ,2)", []bool{true, false}},
+	} {
+		e, err := parse(test.src)
+		if err != nil {
+			t.Errorf("ParseExpr(%q) %v", test.src, err)
+			continue
+		}
+		call, ok := e.(*ast.CallExpr)
+		if !ok || len(call.Args) != len(test.empty) {
+			t.Errorf("ParseExpr(%q): got %T, want *ast.CallExpr with %d arguments", test.src, e, len(test.empty))
+			continue
+		}
+		for i, arg := range call.Args {
+			if _, ok := arg.(*ast.EmptyExpr); ok != test.empty[i] {
+				t.Errorf("ParseExpr(%q): got argument %d %T", test.src, i, arg)
+			}
+		}
+	}
+	src := "F(1,,3)"
+	e, _ := parse(src)
+	if x := e.(*ast.CallExpr).Args[1]; x.Pos() != 5 || x.End() != 5 {
+		t.Errorf("ParseExpr(%q): got omitted argument at %d-%d, want 5-5", src, x.Pos(), x.End())
+	}
+	for _, src := range []string{"F(", "F(1,", "F(1 2)"} {
+		if _, err := parse(src); err == nil {
+			t.Errorf("ParseExpr(%q) succeeded, want error", src)
+		}
+	}
+}
+
+func TestParseArray(t *testing.T) {
+	src := `{1,-2;"a",TRUE}`
+	e, err := parse(src)
+	if err != nil {
+		t.Fatalf("ParseExpr(%q) %v", src, err)
+	}
+	a, ok := e.(*ast.ArrayLit)
+	if !ok {
+		t.Fatalf("ParseExpr(%q): got %T, want *ast.ArrayLit", src, e)
+	}
+	if len(a.Rows) != 2 || len(a.Rows[0]) != 2 || len(a.Rows[1]) != 2 {
+		t.Errorf("ParseExpr(%q): got %d rows, want 2 rows of 2", src, len(a.Rows))
+	}
+	for _, src := range []string{"{1,2;3}", "{}", "{1,A1}", "{1,2"} {
+		if _, err := parse(src); err == nil {
+			t.Errorf("ParseExpr(%q) succeeded, want error", src)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/ajz01/calc/ref
+
+go 1.13
@@ -0,0 +1,137 @@
+package ref
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParseR1C1 parses a reference in R1C1 notation, such as R1C1, R[-1]C,
+// RC[2], R1C1:R5C3, R1:R3, C[1]:C[2] or Data!R1C1. A number after R or
+// C is an absolute row or column; one in brackets, or none, is an
+// offset from anchor, the cell of the formula. A single row or column
+// must be written as a range such as R2:R2.
+func ParseR1C1(s string, anchor Cell) (Ref, error) {
+	var r Ref
+	var err error
+	if r.Sheet, s, err = splitSheet(s); err != nil {
+		return Ref{}, err
+	}
+	from, to := s, s
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		from, to = s[:i], s[i+1:]
+		r.Kind = AreaRef
+	}
+	a, absA, partsA, err := parseRC(from, anchor)
+	if err != nil {
+		return Ref{}, err
+	}
+	b, absB, partsB, err := parseRC(to, anchor)
+	if err != nil || partsA != partsB || r.Kind == CellRef && partsA != rowPart|colPart {
+		return Ref{}, ErrSyntax
+	}
+	switch partsA {
+	case rowPart:
+		r.Kind = RowsRef
+		a.Col, b.Col = 1, MaxCol
+	case colPart:
+		r.Kind = ColsRef
+		a.Row, b.Row = 1, MaxRow
+	}
+	if !a.IsValid() || !b.IsValid() {
+		return Ref{}, ErrSyntax
+	}
+	r.Range = NewRange(a, b)
+	r.FromAbs, r.ToAbs = absA, absB
+	if a.Row > b.Row {
+		r.FromAbs.Row, r.ToAbs.Row = r.ToAbs.Row, r.FromAbs.Row
+	}
+	if a.Col > b.Col {
+		r.FromAbs.Col, r.ToAbs.Col = r.ToAbs.Col, r.FromAbs.Col
+	}
+	return r, nil
+}
+
+// The parts of an R1C1 address.
+const (
+	rowPart = 1 << iota
+	colPart
+)
+
+// parseRC parses an R1C1 cell address, row or column relative to anchor
+// and reports which parts it has.
+func parseRC(s string, anchor Cell) (c Cell, abs Abs, parts int, err error) {
+	c = anchor
+	if len(s) > 0 && (s[0] == 'R' || s[0] == 'r') {
+		parts |= rowPart
+		if c.Row, abs.Row, s, err = parseOffset(s[1:], anchor.Row); err != nil {
+			return
+		}
+	}
+	if len(s) > 0 && (s[0] == 'C' || s[0] == 'c') {
+		parts |= colPart
+		if c.Col, abs.Col, s, err = parseOffset(s[1:], anchor.Col); err != nil {
+			return
+		}
+	}
+	if parts == 0 || s != "" {
+		err = ErrSyntax
+	}
+	return
+}
+
+// parseOffset parses the number or bracketed offset following R or C
+// and returns the row or column it gives relative to base.
+func parseOffset(s string, base int) (n int, abs bool, rest string, err error) {
+	if strings.HasPrefix(s, "[") {
+		i := strings.IndexByte(s, ']')
+		if i < 0 {
+			return 0, false, "", ErrSyntax
+		}
+		d, err := strconv.Atoi(s[1:i])
+		if err != nil {
+			return 0, false, "", ErrSyntax
+		}
+		return base + d, false, s[i+1:], nil
+	}
+	i := 0
+	for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+		i++
+	}
+	if i == 0 {
+		return base, false, s, nil
+	}
+	n, err = strconv.Atoi(s[:i])
+	return n, true, s[i:], err
+}
+
+// R1C1 returns r in R1C1 notation for a formula in cell anchor.
+// Absolute parts become row and column numbers and relative parts
+// offsets from anchor.
+func (r Ref) R1C1(anchor Cell) string {
+	var s string
+	if r.Sheet != "" {
+		s = QuoteSheet(r.Sheet) + "!"
+	}
+	part := func(p string, n, base int, abs bool) string {
+		switch {
+		case abs:
+			return p + strconv.Itoa(n)
+		case n == base:
+			return p
+		}
+		return p + "[" + strconv.Itoa(n-base) + "]"
+	}
+	cell := func(c Cell, abs Abs) string {
+		switch r.Kind {
+		case RowsRef:
+			return part("R", c.Row, anchor.Row, abs.Row)
+		case ColsRef:
+			return part("C", c.Col, anchor.Col, abs.Col)
+		}
+		return part("R", c.Row, anchor.Row, abs.Row) + part("C", c.Col, anchor.Col, abs.Col)
+	}
+	if r.Kind == CellRef {
+		return s + cell(r.Range.From, r.FromAbs)
+	}
+	return s + cell(r.Range.From, r.FromAbs) + ":" + cell(r.Range.To, r.ToAbs)
+}
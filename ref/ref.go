@@ -0,0 +1,322 @@
+package ref
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Grid limits of a sheet.
+const (
+	MaxRow = 1048576
+	MaxCol = 16384
+)
+
+var ErrSyntax = errors.New("invalid cell reference")
+
+// Cell is the address of a single cell. Row and Col are 1-based.
+type Cell struct {
+	Row, Col int
+}
+
+func (c Cell) String() string {
+	return ColName(c.Col) + strconv.Itoa(c.Row)
+}
+
+// IsValid reports whether c lies on the grid.
+func (c Cell) IsValid() bool {
+	return 1 <= c.Row && c.Row <= MaxRow && 1 <= c.Col && c.Col <= MaxCol
+}
+
+// Range is a rectangular block of cells with From at the top left.
+type Range struct {
+	From, To Cell
+}
+
+// NewRange returns the range spanning cells a and b in any order.
+func NewRange(a, b Cell) Range {
+	if a.Row > b.Row {
+		a.Row, b.Row = b.Row, a.Row
+	}
+	if a.Col > b.Col {
+		a.Col, b.Col = b.Col, a.Col
+	}
+	return Range{From: a, To: b}
+}
+
+func (r Range) String() string {
+	if r.From == r.To {
+		return r.From.String()
+	}
+	switch {
+	case r.From.Row == 1 && r.To.Row == MaxRow:
+		return ColName(r.From.Col) + ":" + ColName(r.To.Col)
+	case r.From.Col == 1 && r.To.Col == MaxCol:
+		return strconv.Itoa(r.From.Row) + ":" + strconv.Itoa(r.To.Row)
+	}
+	return r.From.String() + ":" + r.To.String()
+}
+
+func (r Range) Rows() int { return r.To.Row - r.From.Row + 1 }
+func (r Range) Cols() int { return r.To.Col - r.From.Col + 1 }
+
+// Contains reports whether cell c lies within r.
+func (r Range) Contains(c Cell) bool {
+	return r.From.Row <= c.Row && c.Row <= r.To.Row && r.From.Col <= c.Col && c.Col <= r.To.Col
+}
+
+// Overlaps reports whether r and o have a cell in common.
+func (r Range) Overlaps(o Range) bool {
+	return r.From.Row <= o.To.Row && o.From.Row <= r.To.Row && r.From.Col <= o.To.Col && o.From.Col <= r.To.Col
+}
+
+// ColName returns the letters naming column col, e.g. 28 is "AB".
+func ColName(col int) string {
+	var b []byte
+	for col > 0 {
+		col--
+		b = append(b, byte('A'+col%26))
+		col /= 26
+	}
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
+
+// ColIndex returns the column named by letters s, or 0 if s is not a
+// valid column name.
+func ColIndex(s string) int {
+	if s == "" || len(s) > 3 {
+		return 0
+	}
+	col := 0
+	for i := 0; i < len(s); i++ {
+		ch := s[i] | ('a' - 'A')
+		if ch < 'a' || ch > 'z' {
+			return 0
+		}
+		col = col*26 + int(ch-'a') + 1
+	}
+	if col > MaxCol {
+		return 0
+	}
+	return col
+}
+
+// split splits s into its leading letters and trailing digits.
+func split(s string) (letters, digits string) {
+	i := 0
+	for i < len(s) && ('a' <= s[i]|('a'-'A') && s[i]|('a'-'A') <= 'z') {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// ParseCell parses an A1 style cell address.
+func ParseCell(s string) (Cell, error) {
+	letters, digits := split(s)
+	col := ColIndex(letters)
+	row, err := strconv.Atoi(digits)
+	if col == 0 || err != nil || digits[0] == '+' || digits[0] == '-' {
+		return Cell{}, ErrSyntax
+	}
+	c := Cell{Row: row, Col: col}
+	if !c.IsValid() {
+		return Cell{}, ErrSyntax
+	}
+	return c, nil
+}
+
+// IsCell reports whether s is an A1 style cell address.
+func IsCell(s string) bool {
+	_, err := ParseCell(s)
+	return err == nil
+}
+
+// ParseRange parses a cell ("A1"), a range ("A1:C3"), whole columns
+// ("A", "A:C") or whole rows ("1:3").
+func ParseRange(s string) (Range, error) {
+	from, to := s, s
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		from, to = s[:i], s[i+1:]
+	}
+	if col := ColIndex(from); col > 0 {
+		col2 := ColIndex(to)
+		if col2 == 0 {
+			return Range{}, ErrSyntax
+		}
+		return NewRange(Cell{Row: 1, Col: col}, Cell{Row: MaxRow, Col: col2}), nil
+	}
+	if row, err := strconv.Atoi(from); err == nil && strings.IndexByte(s, ':') >= 0 {
+		row2, err := strconv.Atoi(to)
+		if err != nil || row < 1 || row2 < 1 || row > MaxRow || row2 > MaxRow {
+			return Range{}, ErrSyntax
+		}
+		return NewRange(Cell{Row: row, Col: 1}, Cell{Row: row2, Col: MaxCol}), nil
+	}
+	a, err := ParseCell(from)
+	if err != nil {
+		return Range{}, err
+	}
+	b, err := ParseCell(to)
+	if err != nil {
+		return Range{}, err
+	}
+	return NewRange(a, b), nil
+}
+
+// Kind is the form in which a reference is written.
+type Kind int
+
+const (
+	CellRef Kind = iota // A1
+	AreaRef             // A1:C3
+	ColsRef             // A:C
+	RowsRef             // 1:3
+)
+
+// Abs marks the absolute ($) parts of a cell address.
+type Abs struct {
+	Row, Col bool
+}
+
+// Ref is a reference as written in a formula: an optional sheet name, a
+// range, and which coordinates of its corners are absolute.
+type Ref struct {
+	Sheet          string // empty if unqualified
+	Range          Range
+	FromAbs, ToAbs Abs
+	Kind           Kind
+}
+
+// Parse parses a reference such as A1, $A$1:B2, $A:C, 1:3, Data!A1 or
+// 'My Sheet'!A1:C3.
+func Parse(s string) (Ref, error) {
+	var r Ref
+	var err error
+	if r.Sheet, s, err = splitSheet(s); err != nil {
+		return Ref{}, err
+	}
+	from, to := s, s
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		from, to = s[:i], s[i+1:]
+		r.Kind = AreaRef
+	}
+	from, r.FromAbs = stripAbs(from)
+	to, r.ToAbs = stripAbs(to)
+	rng, err := ParseRange(from + ":" + to)
+	if err != nil {
+		return Ref{}, err
+	}
+	r.Range = rng
+	fc, fr := coords(from)
+	tc, tr := coords(to)
+	switch {
+	case r.Kind == CellRef && (fr == 0 || fc == 0):
+		// a column or row on its own
+		return Ref{}, ErrSyntax
+	case fr == 0:
+		r.Kind = ColsRef
+	case fc == 0:
+		r.Kind = RowsRef
+	}
+	// the corners were swapped if written the other way round
+	if fc > tc {
+		r.FromAbs.Col, r.ToAbs.Col = r.ToAbs.Col, r.FromAbs.Col
+	}
+	if fr > tr {
+		r.FromAbs.Row, r.ToAbs.Row = r.ToAbs.Row, r.FromAbs.Row
+	}
+	return r, nil
+}
+
+// splitSheet splits the sheet name, unquoted, from reference s.
+func splitSheet(s string) (sheet, rest string, err error) {
+	i := strings.LastIndexByte(s, '!')
+	if i < 0 {
+		return "", s, nil
+	}
+	sheet, rest = s[:i], s[i+1:]
+	if len(sheet) >= 2 && sheet[0] == '\'' && sheet[len(sheet)-1] == '\'' {
+		sheet = strings.Replace(sheet[1:len(sheet)-1], "''", "'", -1)
+	}
+	if sheet == "" {
+		return "", "", ErrSyntax
+	}
+	return sheet, rest, nil
+}
+
+// stripAbs removes the $ signs from a cell address, column or row and
+// reports which parts they marked.
+func stripAbs(s string) (string, Abs) {
+	var abs Abs
+	if strings.HasPrefix(s, "$") {
+		s = s[1:]
+		abs.Col = true
+	}
+	letters, digits := split(s)
+	if strings.HasPrefix(digits, "$") {
+		digits = digits[1:]
+		abs.Row = true
+	}
+	if letters == "" {
+		// a row such as $3
+		abs.Row, abs.Col = abs.Col, false
+	}
+	return letters + digits, abs
+}
+
+// coords returns the column and row of a cell address, column or row
+// without $ signs; the missing one is zero.
+func coords(s string) (col, row int) {
+	letters, digits := split(s)
+	row, _ = strconv.Atoi(digits)
+	return ColIndex(letters), row
+}
+
+// String returns the reference in the form it was parsed from.
+func (r Ref) String() string {
+	var s string
+	if r.Sheet != "" {
+		s = QuoteSheet(r.Sheet) + "!"
+	}
+	col := func(c int, abs bool) string {
+		if abs {
+			return "$" + ColName(c)
+		}
+		return ColName(c)
+	}
+	row := func(n int, abs bool) string {
+		if abs {
+			return "$" + strconv.Itoa(n)
+		}
+		return strconv.Itoa(n)
+	}
+	from, to := r.Range.From, r.Range.To
+	switch r.Kind {
+	case CellRef:
+		return s + col(from.Col, r.FromAbs.Col) + row(from.Row, r.FromAbs.Row)
+	case ColsRef:
+		return s + col(from.Col, r.FromAbs.Col) + ":" + col(to.Col, r.ToAbs.Col)
+	case RowsRef:
+		return s + row(from.Row, r.FromAbs.Row) + ":" + row(to.Row, r.ToAbs.Row)
+	}
+	return s + col(from.Col, r.FromAbs.Col) + row(from.Row, r.FromAbs.Row) + ":" + col(to.Col, r.ToAbs.Col) + row(to.Row, r.ToAbs.Row)
+}
+
+// QuoteSheet quotes a sheet name for use in a reference unless it is a
+// plain identifier.
+func QuoteSheet(name string) string {
+	plain := name != "" && !IsCell(name)
+	for i, r := range name {
+		if !(unicode.IsLetter(r) || r == '_' || i > 0 && (unicode.IsDigit(r) || r == '.')) {
+			plain = false
+		}
+	}
+	if plain {
+		return name
+	}
+	return "'" + strings.Replace(name, "'", "''", -1) + "'"
+}
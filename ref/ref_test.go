@@ -0,0 +1,146 @@
+package ref
+
+import "testing"
+
+func TestColName(t *testing.T) {
+	for col, name := range map[int]string{1: "A", 26: "Z", 27: "AA", 28: "AB", 702: "ZZ", 703: "AAA", MaxCol: "XFD"} {
+		if got := ColName(col); got != name {
+			t.Errorf("ColName(%d) = %s want %s", col, got, name)
+		}
+		if got := ColIndex(name); got != col {
+			t.Errorf("ColIndex(%s) = %d want %d", name, got, col)
+		}
+	}
+}
+
+func TestParseCell(t *testing.T) {
+	c, err := ParseCell("ab12")
+	if err != nil || c != (Cell{Row: 12, Col: 28}) {
+		t.Errorf("ParseCell(ab12) = %v %v want AB12", c, err)
+	}
+	for _, s := range []string{"", "A", "12", "A0", "XFE1", "A-1", "A1048577"} {
+		if _, err := ParseCell(s); err == nil {
+			t.Errorf("ParseCell(%q) succeeded want error", s)
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		src, want  string
+		rows, cols int
+	}{
+		{"A1", "A1", 1, 1},
+		{"D3:A1", "A1:D3", 3, 4},
+		{"B:C", "B:C", MaxRow, 2},
+		{"2:4", "2:4", 3, MaxCol},
+	}
+	for _, test := range tests {
+		r, err := ParseRange(test.src)
+		if err != nil {
+			t.Errorf("ParseRange(%q) %v", test.src, err)
+			continue
+		}
+		if r.String() != test.want || r.Rows() != test.rows || r.Cols() != test.cols {
+			t.Errorf("ParseRange(%q) = %s %dx%d want %s %dx%d", test.src, r, r.Rows(), r.Cols(), test.want, test.rows, test.cols)
+		}
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"A1:C3", "C3:D4", true},
+		{"A1:C3", "D1:D9", false},
+		{"B:B", "A5:C5", true},
+		{"A1", "A2", false},
+	}
+	for _, test := range tests {
+		a, _ := ParseRange(test.a)
+		b, _ := ParseRange(test.b)
+		if got := a.Overlaps(b); got != test.want || b.Overlaps(a) != test.want {
+			t.Errorf("%s.Overlaps(%s) = %v want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src, want string
+		kind      Kind
+	}{
+		{"a1", "A1", CellRef},
+		{"$B$2", "$B$2", CellRef},
+		{"B$2:$C3", "B$2:$C3", AreaRef},
+		{"$C3:B$2", "B$2:$C3", AreaRef},
+		{"C1:A$5", "A1:C$5", AreaRef},
+		{"$A:C", "$A:C", ColsRef},
+		{"$3:4", "$3:4", RowsRef},
+		{"Data!A1", "Data!A1", CellRef},
+		{"'My Sheet'!$A$1:B2", "'My Sheet'!$A$1:B2", AreaRef},
+		{"'it''s'!A1", "'it''s'!A1", CellRef},
+		{"'A1'!A1", "'A1'!A1", CellRef},
+	}
+	for _, test := range tests {
+		r, err := Parse(test.src)
+		if err != nil {
+			t.Errorf("Parse(%q) %v", test.src, err)
+			continue
+		}
+		if r.String() != test.want || r.Kind != test.kind {
+			t.Errorf("Parse(%q) = %s kind %d want %s kind %d", test.src, r, r.Kind, test.want, test.kind)
+		}
+	}
+	for _, src := range []string{"", "A", "3", "$A$", "!A1", "A1:B", "A$0"} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("Parse(%q) succeeded want error", src)
+		}
+	}
+	r, _ := Parse("'it''s'!$C3:B$2")
+	if r.Sheet != "it's" || r.Range.String() != "B2:C3" || r.FromAbs != (Abs{Row: true}) || r.ToAbs != (Abs{Col: true}) {
+		t.Errorf("Parse('it''s'!$C3:B$2) = %+v", r)
+	}
+}
+
+func TestR1C1(t *testing.T) {
+	anchor := Cell{Row: 5, Col: 3} // C5
+	tests := []struct {
+		r1c1, a1 string
+	}{
+		{"R1C1", "$A$1"},
+		{"RC", "C5"},
+		{"R[-1]C", "C4"},
+		{"RC[2]", "E5"},
+		{"R2C[-2]", "A$2"},
+		{"R[-4]C[-2]:R5C", "A1:C$5"},
+		{"R1C1:R5C3", "$A$1:$C$5"},
+		{"R[1]:R[3]", "6:8"},
+		{"C1:C[1]", "$A:D"},
+		{"Data!R1C", "Data!C$1"},
+	}
+	for _, test := range tests {
+		r, err := ParseR1C1(test.r1c1, anchor)
+		if err != nil {
+			t.Errorf("ParseR1C1(%q) %v", test.r1c1, err)
+			continue
+		}
+		if got := r.String(); got != test.a1 {
+			t.Errorf("ParseR1C1(%q) = %s want %s", test.r1c1, got, test.a1)
+		}
+		a, err := Parse(test.a1)
+		if err != nil {
+			t.Errorf("Parse(%q) %v", test.a1, err)
+			continue
+		}
+		if got := a.R1C1(anchor); got != test.r1c1 {
+			t.Errorf("Parse(%q).R1C1(C5) = %s want %s", test.a1, got, test.r1c1)
+		}
+	}
+	for _, src := range []string{"", "R", "C2", "R[-5]C", "R1C1:R2", "R[x]C", "R1C1X"} {
+		if _, err := ParseR1C1(src, anchor); err == nil {
+			t.Errorf("ParseR1C1(%q) succeeded want error", src)
+		}
+	}
+}
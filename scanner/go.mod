@@ -1,3 +1,7 @@
 module github.com/ajz01/calc/scanner
 
 go 1.13
+
+replace github.com/ajz01/calc/token => ../token
+
+require github.com/ajz01/calc/token v0.0.0-00010101000000-000000000000
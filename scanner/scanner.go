@@ -4,12 +4,27 @@ import (
 	"fmt"
 	"github.com/ajz01/calc/token"
 	"sort"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
 
 type ErrorHandler func(pos token.Position, msg string)
 
+// A Mode value is a set of flags (or 0). They control scanner behavior.
+type Mode uint
+
+const (
+	// StrictNumbers accepts only spreadsheet number literals: decimal
+	// digits with an optional fraction and exponent. Without it the
+	// scanner also accepts Go's 0x, 0o and 0b prefixes and _ digit
+	// separators. Imaginary literals are accepted in both modes.
+	StrictNumbers Mode = 1 << iota
+	// R1C1 scans references in R1C1 notation, such as R1C1, R[-1]C and
+	// RC[2]:RC[4], instead of A1 notation.
+	R1C1
+)
+
 type Scanner struct {
 	src        []byte
 	err        ErrorHandler
@@ -19,6 +34,7 @@ type Scanner struct {
 	lineOffset int
 	colOffset  int
 	prev       token.Token
+	mode       Mode
 }
 
 func (s *Scanner) next() {
@@ -68,9 +84,10 @@ func (s *Scanner) errorf(offs int, format string, args ...interface{}) {
 	s.error(offs, fmt.Sprintf(format, args...))
 }
 
-func (s *Scanner) Init(src []byte, err ErrorHandler) {
+func (s *Scanner) Init(src []byte, err ErrorHandler, mode Mode) {
 	s.src = src
 	s.err = err
+	s.mode = mode
 	s.next()
 }
 
@@ -82,23 +99,172 @@ func isDigit(ch rune) bool {
 	return isDecimal(ch) || ch >= utf8.RuneSelf && unicode.IsDigit(ch)
 }
 
-func (s *Scanner) scanIdentifier() (string, bool) {
-	ref := false
+func (s *Scanner) scanIdentifier() string {
 	offs := s.offset
-	for isLetter(s.ch) || isDigit(s.ch) {
+	for isLetter(s.ch) || isDigit(s.ch) || s.ch == '.' && isIdentRest(s.peek()) {
 		s.next()
 	}
-	// Col or Row only Ref
-	if s.offset-offs == 1 {
-		if isLetter(rune(s.src[offs : offs+1][0])) {
-			ref = true
+	return string(s.src[offs:s.offset])
+}
+
+// isIdentRest reports whether ch may follow a dot inside a dotted
+// function name such as STDEV.S.
+func isIdentRest(ch byte) bool {
+	return isLetter(rune(ch)) || isDecimal(rune(ch))
+}
+
+// refLen returns the length of the reference at the start of src, or 0
+// if there is none. A reference is a cell such as A1, a range of cells
+// such as A1:C3 or of whole columns such as A:C, with $ marking the
+// absolute parts, optionally preceded by a sheet name and '!'. A cell
+// name followed by '(' or more name characters is not a reference but
+// part of a name, such as LOG10(.
+func refLen(src []byte) int {
+	i := sheetLen(src)
+	end, col := cellLen(src, i)
+	if end == i {
+		return 0
+	}
+	if end < len(src) && src[end] == ':' {
+		if end2, col2 := cellLen(src, end+1); end2 > end+1 && col2 == col {
+			return end2
 		}
-	} else if s.offset-offs == 2 {
-		if isLetter(rune(s.src[offs : offs+1][0])) && isDigit(rune(s.src[offs+1 : offs+2][0])) {
-			ref = true
+	}
+	if col || end < len(src) && (src[end] == '(' || isIdentRest(src[end]) || src[end] == '.' || src[end] == '$') {
+		return 0
+	}
+	return end
+}
+
+// r1c1Len is refLen for references in R1C1 notation. A single row or
+// column, such as R2 or C[1], is a reference only as part of a range.
+func r1c1Len(src []byte) int {
+	i := sheetLen(src)
+	end, parts := rcLen(src, i)
+	if end == i {
+		return 0
+	}
+	if end < len(src) && src[end] == ':' {
+		if end2, parts2 := rcLen(src, end+1); end2 > end+1 && parts2 == parts {
+			return end2
 		}
 	}
-	return string(s.src[offs:s.offset]), ref
+	if parts != 3 || end < len(src) && (src[end] == '(' || isIdentRest(src[end]) || src[end] == '.') {
+		return 0
+	}
+	return end
+}
+
+// rcLen returns the end of the R1C1 cell address, row or column starting
+// at src[i], and which parts it has: 1 for a row, 2 for a column, 3 for
+// both. It returns i if there is none.
+func rcLen(src []byte, i int) (int, int) {
+	j, parts := i, 0
+	if j < len(src) && lower(rune(src[j])) == 'r' {
+		j = offsetLen(src, j+1)
+		parts |= 1
+	}
+	if j < len(src) && lower(rune(src[j])) == 'c' {
+		j = offsetLen(src, j+1)
+		parts |= 2
+	}
+	if parts == 0 {
+		return i, 0
+	}
+	return j, parts
+}
+
+// offsetLen returns the end of the row or column number, or offset in
+// brackets, that may follow R or C at src[i].
+func offsetLen(src []byte, i int) int {
+	if i < len(src) && src[i] == '[' {
+		j := i + 1
+		if j < len(src) && (src[j] == '-' || src[j] == '+') {
+			j++
+		}
+		d := j
+		for d < len(src) && isDecimal(rune(src[d])) {
+			d++
+		}
+		if d == j || d >= len(src) || src[d] != ']' {
+			return i
+		}
+		return d + 1
+	}
+	for i < len(src) && isDecimal(rune(src[i])) {
+		i++
+	}
+	return i
+}
+
+// sheetLen returns the length of the sheet name and '!' at the start of
+// src, or 0 if there is none. Names other than plain identifiers are
+// quoted, with quotes in the name doubled.
+func sheetLen(src []byte) int {
+	i := 0
+	if len(src) > 0 && src[0] == '\'' {
+		for i = 1; i < len(src); i++ {
+			if src[i] == '\'' {
+				if i+1 < len(src) && src[i+1] == '\'' {
+					i++
+					continue
+				}
+				break
+			}
+		}
+		if i == 1 || i >= len(src) {
+			return 0
+		}
+		i++
+	} else {
+		for i < len(src) {
+			r, w := utf8.DecodeRune(src[i:])
+			if !(isLetter(r) || isDigit(r) || r == '.') {
+				break
+			}
+			i += w
+		}
+	}
+	if i == 0 || i >= len(src) || src[i] != '!' {
+		return 0
+	}
+	return i + 1
+}
+
+// cellLen returns the end of the cell address or column, with optional
+// $ signs, starting at src[i], and whether it is a column. It returns i
+// if there is none.
+func cellLen(src []byte, i int) (int, bool) {
+	j := i
+	if j < len(src) && src[j] == '$' {
+		j++
+	}
+	k := j
+	for k < len(src) && 'a' <= lower(rune(src[k])) && lower(rune(src[k])) <= 'z' {
+		k++
+	}
+	if k == j || k-j > 3 {
+		return i, false
+	}
+	m := k
+	if m < len(src) && src[m] == '$' {
+		m++
+	}
+	d := m
+	for d < len(src) && isDecimal(rune(src[d])) {
+		d++
+	}
+	switch {
+	case d == m && m == k:
+		return k, true
+	case d == m || src[m] == '0':
+		return i, false
+	}
+	return d, false
+}
+
+func isBool(lit string) bool {
+	return strings.EqualFold(lit, "TRUE") || strings.EqualFold(lit, "FALSE")
 }
 
 func lower(ch rune) rune     { return ('a' - 'A') | ch } // returns lower-case ch if ch is ASCII
@@ -106,9 +272,10 @@ func isDecimal(ch rune) bool { return '0' <= ch && ch <= '9' }
 func isHex(ch rune) bool     { return '0' <= ch && ch <= '9' || 'a' <= lower(ch) && lower(ch) <= 'f' }
 
 func (s *Scanner) digits(base int, invalid *int) (digsep int) {
+	sep := s.mode&StrictNumbers == 0
 	if base <= 10 {
 		max := rune('0' + base)
-		for isDecimal(s.ch) || s.ch == '_' {
+		for isDecimal(s.ch) || sep && s.ch == '_' {
 			ds := 1
 			if s.ch == '_' {
 				ds = 2
@@ -119,7 +286,7 @@ func (s *Scanner) digits(base int, invalid *int) (digsep int) {
 			s.next()
 		}
 	} else {
-		for isHex(s.ch) || s.ch == '_' {
+		for isHex(s.ch) || sep && s.ch == '_' {
 			ds := 1
 			if s.ch == '_' {
 				ds = 2
@@ -143,7 +310,7 @@ func (s *Scanner) scanNumber() (token.Token, string) {
 	// integer part
 	if s.ch != '.' {
 		tok = token.INT
-		if s.ch == '0' {
+		if s.ch == '0' && s.mode&StrictNumbers == 0 {
 			s.next()
 			switch lower(s.ch) {
 			case 'x':
@@ -178,7 +345,7 @@ func (s *Scanner) scanNumber() (token.Token, string) {
 	}
 
 	// exponent
-	if e := lower(s.ch); e == 'e' || e == 'p' {
+	if e := lower(s.ch); e == 'e' || e == 'p' && s.mode&StrictNumbers == 0 {
 		switch {
 		case e == 'e' && prefix != 0 && prefix != '0':
 			s.errorf(s.offset, "%q exponent requires decimal mantissa", s.ch)
@@ -280,6 +447,11 @@ func (s *Scanner) scanString() string {
 		}
 		s.next()
 		if ch == '"' {
+			if s.ch == '"' {
+				// doubled quote
+				s.next()
+				continue
+			}
 			break
 		}
 		if ch == '\\' {
@@ -291,6 +463,25 @@ func (s *Scanner) scanString() string {
 	return string(s.src[offs:s.offset])
 }
 
+// errorLits holds the error literals in upper case.
+var errorLits = []string{"#NULL!", "#DIV/0!", "#VALUE!", "#REF!", "#NAME?", "#NUM!", "#N/A", "#CALC!", "#SPILL!"}
+
+// scanError scans an error literal such as #N/A, ignoring case. It
+// reports false and consumes nothing but the '#' if none matches.
+func (s *Scanner) scanError() (string, bool) {
+	// '#' opening already consumed
+	offs := s.offset - 1
+	for _, lit := range errorLits {
+		if end := offs + len(lit); end <= len(s.src) && strings.EqualFold(string(s.src[offs:end]), lit) {
+			for s.offset < end {
+				s.next()
+			}
+			return lit, true
+		}
+	}
+	return "", false
+}
+
 func (s *Scanner) skipWhitespace() {
 	for s.ch == ' ' || s.ch == '\t' || s.ch == '\n' || s.ch == '\r' {
 		s.next()
@@ -313,31 +504,38 @@ func (s *Scanner) Scan() (pos token.Pos, tok token.Token, lit string) {
 	pos = token.Pos(s.offset + 1)
 
 	switch ch := s.ch; {
-	case isLetter(ch):
-		var ref bool
-		lit, ref = s.scanIdentifier()
-		if s.ch == '(' {
-			tok = token.IDENT//.FUNC
-		} else if ref {
-			if s.ch == ':' {
+	case isLetter(ch) || ch == '$' || ch == '\'':
+		refLen := refLen
+		if s.mode&R1C1 != 0 {
+			refLen = r1c1Len
+		}
+		if n := refLen(s.src[s.offset:]); n > 0 {
+			lit = string(s.src[s.offset : s.offset+n])
+			for end := s.offset + n; s.offset < end; {
 				s.next()
-				lit2, ref2 := s.scanIdentifier()
-				if ref2 {
-					tok = token.RNG
-					lit = lit + ":" + lit2
-				}
+			}
+			tok = token.REF
+			if strings.IndexByte(lit[sheetLen([]byte(lit)):], ':') >= 0 {
+				tok = token.RNG
+			}
+		} else if isLetter(ch) {
+			lit = s.scanIdentifier()
+			if s.ch != '(' && isBool(lit) {
+				tok = token.BOOL
 			} else {
-				tok = token.REF
+				tok = token.IDENT
 			}
 		} else {
-			tok = token.IDENT
-			fmt.Printf("prev %v\n", s.prev)
+			s.next()
+			tok = token.ILLEGAL
 		}
 	case isDecimal(ch) || ch == '.' && isDecimal(rune(s.peek())):
 		tok, lit = s.scanNumber()
 	default:
 		s.next()
 		switch ch {
+		case -1:
+			tok = token.EOF
 		case '=':
 			if s.colOffset == 0 {
 				tok = token.FRML
@@ -347,8 +545,27 @@ func (s *Scanner) Scan() (pos token.Pos, tok token.Token, lit string) {
 		case '"':
 			tok = token.STRING
 			lit = s.scanString()
+		case '#':
+			var ok bool
+			if lit, ok = s.scanError(); !ok {
+				// A1# refers to the range spilled by the formula in A1
+				if s.prev == token.REF || s.prev == token.IDENT {
+					tok = token.SPILL
+				} else {
+					tok = token.ILLEGAL
+				}
+			} else if lit == "#REF!" {
+				tok = token.ERREF
+			} else {
+				tok = token.ERR
+			}
 		case '<':
-			tok = s.switch2(token.LSS, token.LEQ)
+			if s.ch == '>' {
+				s.next()
+				tok = token.NOT
+			} else {
+				tok = s.switch2(token.LSS, token.LEQ)
+			}
 		case '>':
 			tok = s.switch2(token.GTR, token.GEQ)
 		default:
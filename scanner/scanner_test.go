@@ -12,7 +12,7 @@ func setupScanner(formula string) Scanner {
 	err := func(pos token.Position, msg string) {
 		fmt.Printf("%d %s\n", pos, msg)
 	}
-	s.Init(src, err)
+	s.Init(src, err, 0)
 	return s
 }
 
@@ -40,6 +40,68 @@ func TestScanIdent(t *testing.T) {
 	}
 }
 
+func TestScanLetter(t *testing.T) {
+	s := setupScanner("x")
+	if _, tok, lit := s.Scan(); tok != token.IDENT || lit != "x" {
+		t.Errorf("Scan Letter = %q %q want IDENT x", tok, lit)
+	}
+	s = setupScanner("A:C")
+	if _, tok, lit := s.Scan(); tok != token.RNG || lit != "A:C" {
+		t.Errorf("Scan Columns = %q %q want RNG A:C", tok, lit)
+	}
+}
+
+func TestScanDottedIdent(t *testing.T) {
+	for _, src := range []string{"STDEV.S(", "T.DIST(", "MODE.SNGL("} {
+		s := setupScanner(src)
+		_, tok, lit := s.Scan()
+		if want := src[:len(src)-1]; tok != token.IDENT || lit != want {
+			t.Errorf("Scan Ident = %q %q want IDENT %s", tok, lit, want)
+		}
+	}
+}
+
+func TestScanBool(t *testing.T) {
+	s := setupScanner("true")
+	_, tok, lit := s.Scan()
+	if tok != token.BOOL || lit != "true" {
+		t.Errorf("Scan Bool = %q %q want BOOL true", tok, lit)
+	}
+	s = setupScanner("FALSE()")
+	_, tok, lit = s.Scan()
+	if tok != token.IDENT || lit != "FALSE" {
+		t.Errorf("Scan Func = %q %q want IDENT FALSE", tok, lit)
+	}
+}
+
+func TestScanString(t *testing.T) {
+	s := setupScanner(`"say ""hi"""`)
+	_, tok, lit := s.Scan()
+	if tok != token.STRING || lit != `"say ""hi"""` {
+		t.Errorf("Scan String = %q %q want STRING %q", tok, lit, `"say ""hi"""`)
+	}
+}
+
+func TestScanError(t *testing.T) {
+	for src, want := range map[string]token.Token{"#N/A": token.ERR, "#div/0!": token.ERR, "#REF!": token.ERREF, "#CALC!": token.ERR, "#SPILL!": token.ERR, "#FOO!": token.ILLEGAL} {
+		s := setupScanner(src)
+		_, tok, _ := s.Scan()
+		if tok != want {
+			t.Errorf("Scan(%q) = %q want %q", src, tok, want)
+		}
+	}
+}
+
+func TestScanSpill(t *testing.T) {
+	s := setupScanner("D2#+@A1:A3")
+	want := []token.Token{token.REF, token.SPILL, token.ADD, token.AT, token.RNG, token.EOF}
+	for _, w := range want {
+		if _, tok, _ := s.Scan(); tok != w {
+			t.Errorf("Scan = %q want %q", tok, w)
+		}
+	}
+}
+
 func TestScanRange(t *testing.T) {
 	s := setupScanner("A1:D3")
 	_, tok, lit := s.Scan()
@@ -47,6 +109,26 @@ func TestScanRange(t *testing.T) {
 		t.Errorf("Scan Range = %q %q want RNG A1:D3", tok, lit)
 	}
 }
+func TestScanCellRef(t *testing.T) {
+	for _, src := range []string{"A10", "ab12", "XFD1048576"} {
+		s := setupScanner(src)
+		_, tok, lit := s.Scan()
+		if tok != token.REF || lit != src {
+			t.Errorf("Scan Ref = %q %q want REF %s", tok, lit, src)
+		}
+	}
+	s := setupScanner("AB10:AC200")
+	_, tok, lit := s.Scan()
+	if tok != token.RNG || lit != "AB10:AC200" {
+		t.Errorf("Scan Range = %q %q want RNG AB10:AC200", tok, lit)
+	}
+	s = setupScanner("ABCD1")
+	_, tok, lit = s.Scan()
+	if tok != token.IDENT || lit != "ABCD1" {
+		t.Errorf("Scan Ident = %q %q want IDENT ABCD1", tok, lit)
+	}
+}
+
 func TestScanOps(t *testing.T) {
 	sym := "+-*/^"
 	toks := []token.Token{token.ADD, token.SUB, token.MUL, token.QUO, token.EXP}
@@ -58,3 +140,86 @@ func TestScanOps(t *testing.T) {
 		}
 	}
 }
+
+func TestScanStrictNumbers(t *testing.T) {
+	tests := []struct {
+		src     string
+		mode    Mode
+		tok     token.Token
+		lit     string
+		nerrors int
+	}{
+		{"0x1F", 0, token.INT, "0x1F", 0},
+		{"1_000", 0, token.INT, "1_000", 0},
+		{"09", 0, token.INT, "09", 1},
+		{"0x1F", StrictNumbers, token.INT, "0", 0},
+		{"1_000", StrictNumbers, token.INT, "1", 0},
+		{"09", StrictNumbers, token.INT, "09", 0},
+		{"010.5e-1", StrictNumbers, token.FLOAT, "010.5e-1", 0},
+		{"2.5i", StrictNumbers, token.IMAG, "2.5i", 0},
+	}
+	for _, test := range tests {
+		var s Scanner
+		n := 0
+		s.Init([]byte(test.src), func(token.Position, string) { n++ }, test.mode)
+		_, tok, lit := s.Scan()
+		if tok != test.tok || lit != test.lit || n != test.nerrors {
+			t.Errorf("Scan(%q, %d) = %v %q with %d errors want %v %q with %d", test.src, test.mode, tok, lit, n, test.tok, test.lit, test.nerrors)
+		}
+	}
+}
+
+func TestScanCompareConcat(t *testing.T) {
+	s := setupScanner("<> <= < & >=")
+	for _, want := range []token.Token{token.NOT, token.LEQ, token.LSS, token.CONCAT, token.GEQ, token.EOF} {
+		if _, tok, _ := s.Scan(); tok != want {
+			t.Errorf("Scan = %q want %q", tok, want)
+		}
+	}
+}
+
+func TestScanAbsRef(t *testing.T) {
+	for src, want := range map[string]token.Token{"$A$1": token.REF, "A$1": token.REF, "$a1": token.REF, "$A$1:B$2": token.RNG, "$A:$C": token.RNG} {
+		s := setupScanner(src)
+		if _, tok, lit := s.Scan(); tok != want || lit != src {
+			t.Errorf("Scan(%q) = %q %q want %q %s", src, tok, lit, want, src)
+		}
+	}
+	s := setupScanner("$A")
+	if _, tok, _ := s.Scan(); tok != token.ILLEGAL {
+		t.Errorf("Scan($A) = %q want ILLEGAL", tok)
+	}
+}
+
+func TestScanSheetRef(t *testing.T) {
+	for src, want := range map[string]token.Token{"Data!A1": token.REF, "Sheet2!$B$2:C9": token.RNG, "'My Sheet'!A:B": token.RNG, "'it''s'!A1": token.REF} {
+		s := setupScanner(src)
+		if _, tok, lit := s.Scan(); tok != want || lit != src {
+			t.Errorf("Scan(%q) = %q %q want %q %s", src, tok, lit, want, src)
+		}
+	}
+	s := setupScanner("Data!A1+Data!x")
+	want := []token.Token{token.REF, token.ADD, token.IDENT, token.ILLEGAL, token.IDENT, token.EOF}
+	for _, w := range want {
+		if _, tok, _ := s.Scan(); tok != w {
+			t.Errorf("Scan = %q want %q", tok, w)
+		}
+	}
+}
+
+func TestScanR1C1(t *testing.T) {
+	for src, want := range map[string]token.Token{"R1C1": token.REF, "r[-1]c": token.REF, "RC[2]": token.REF, "R1C1:R5C3": token.RNG, "R[1]:R[3]": token.RNG, "Data!RC": token.REF} {
+		var s Scanner
+		s.Init([]byte(src), nil, R1C1)
+		if _, tok, lit := s.Scan(); tok != want || lit != src {
+			t.Errorf("Scan(%q) = %q %q want %q %s", src, tok, lit, want, src)
+		}
+	}
+	for src, want := range map[string]token.Token{"A1": token.IDENT, "ROUND(": token.IDENT, "R2": token.IDENT, "RC1(": token.IDENT} {
+		var s Scanner
+		s.Init([]byte(src), nil, R1C1)
+		if _, tok, _ := s.Scan(); tok != want {
+			t.Errorf("Scan(%q) = %q want %q", src, tok, want)
+		}
+	}
+}
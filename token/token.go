@@ -32,6 +32,11 @@ const (
 	QUO // /
 	EXP // ^
 
+	CONCAT // &
+
+	SPILL // # (postfix)
+	AT    // @
+
 	LAND // And
 	LOR  // Or
 
@@ -82,6 +87,11 @@ var tokens = [...]string{
 	QUO: "/",
 	EXP: "^",
 
+	CONCAT: "&",
+
+	SPILL: "#",
+	AT:    "@",
+
 	LAND: "AND",
 	LOR:  "OR",
 
@@ -112,6 +122,8 @@ var ops = map[rune]Token{
 	'*': MUL,
 	'/': QUO,
 	'^': EXP,
+	'&': CONCAT,
+	'@': AT,
 
 	'(': LPAREN,
 	'[': LBRACK,
@@ -144,8 +156,8 @@ func (tok Token) String() string {
 
 const (
 	LowestPrec  = 0
-	UnaryPrec   = 6
-	HighestPrec = 7
+	UnaryPrec   = 7
+	HighestPrec = 8
 )
 
 func (op Token) Precedence() int {
@@ -154,12 +166,16 @@ func (op Token) Precedence() int {
 		return 1
 	case LAND:
 		return 2
-	case EQL, LSS, LEQ, GTR, GEQ:
+	case EQL, NOT, LSS, LEQ, GTR, GEQ:
 		return 3
-	case ADD, SUB:
+	case CONCAT:
 		return 4
-	case MUL, QUO:
+	case ADD, SUB:
 		return 5
+	case MUL, QUO:
+		return 6
+	case EXP:
+		return 7
 	}
 	return LowestPrec
 }
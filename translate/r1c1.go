@@ -0,0 +1,47 @@
+package translate
+
+import (
+	"github.com/ajz01/calc/parser"
+	"github.com/ajz01/calc/ref"
+	"strings"
+)
+
+// ToR1C1 converts the references of formula src, in A1 notation, to
+// R1C1 notation for a formula in cell anchor, keeping its layout. A
+// formula copied down a column reads the same in R1C1 notation in every
+// cell. src may start with '='.
+func ToR1C1(src string, anchor ref.Cell) (string, error) {
+	return convert(src, 0, func(lit string) string {
+		r, err := ref.Parse(lit)
+		if err != nil {
+			return lit
+		}
+		return r.R1C1(anchor)
+	})
+}
+
+// ToA1 converts the references of formula src, in R1C1 notation, to A1
+// notation for a formula in cell anchor. References that fall off the
+// sheet become #REF!.
+func ToA1(src string, anchor ref.Cell) (string, error) {
+	return convert(src, parser.R1C1, func(lit string) string {
+		r, err := ref.ParseR1C1(lit, anchor)
+		if err != nil {
+			return "#REF!"
+		}
+		return r.String()
+	})
+}
+
+// convert parses src with mode and rewrites its references with f.
+func convert(src string, mode parser.Mode, f func(lit string) string) (string, error) {
+	var eq string
+	if strings.HasPrefix(src, "=") {
+		eq, src = "=", src[1:]
+	}
+	x, err := parser.ParseMode([]byte(src), mode)
+	if err != nil {
+		return "", err
+	}
+	return eq + Rewrite(src, x, f), nil
+}
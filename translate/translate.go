@@ -0,0 +1,171 @@
+// Package translate rewrites the references of formulas that are copied,
+// pasted or filled into other cells: relative parts of references move
+// with the formula, absolute ($) parts stay.
+package translate
+
+import (
+	"github.com/ajz01/calc/ast"
+	"github.com/ajz01/calc/ref"
+	"github.com/ajz01/calc/token"
+	"sort"
+	"strings"
+)
+
+// Ref returns r moved by rows and cols, or false if a relative part of
+// it moves off the sheet. The rows of whole columns and the columns of
+// whole rows do not move.
+func Ref(r ref.Ref, rows, cols int) (ref.Ref, bool) {
+	if r.Kind == ref.ColsRef {
+		rows = 0
+	}
+	if r.Kind == ref.RowsRef {
+		cols = 0
+	}
+	move := func(c ref.Cell, abs ref.Abs) (ref.Cell, bool) {
+		if !abs.Row {
+			c.Row += rows
+		}
+		if !abs.Col {
+			c.Col += cols
+		}
+		return c, c.IsValid()
+	}
+	from, ok1 := move(r.Range.From, r.FromAbs)
+	to, ok2 := move(r.Range.To, r.ToAbs)
+	if !ok1 || !ok2 {
+		return ref.Ref{}, false
+	}
+	// a relative corner may pass an absolute one
+	r.Range = ref.NewRange(from, to)
+	if from.Row > to.Row {
+		r.FromAbs.Row, r.ToAbs.Row = r.ToAbs.Row, r.FromAbs.Row
+	}
+	if from.Col > to.Col {
+		r.FromAbs.Col, r.ToAbs.Col = r.ToAbs.Col, r.FromAbs.Col
+	}
+	return r, true
+}
+
+// lit returns the text of reference literal lit moved by rows and cols,
+// or "#REF!" if it moves off the sheet.
+func lit(lit string, rows, cols int) string {
+	r, err := ref.Parse(lit)
+	if err != nil {
+		return lit
+	}
+	if r, ok := Ref(r, rows, cols); ok {
+		return r.String()
+	}
+	return "#REF!"
+}
+
+// Formula returns a copy of formula x as it reads when copied from cell
+// from to cell to. References that move off the sheet become #REF!.
+func Formula(x ast.Expr, from, to ref.Cell) ast.Expr {
+	return move(x, to.Row-from.Row, to.Col-from.Col)
+}
+
+func move(x ast.Expr, rows, cols int) ast.Expr {
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		if x.Kind != token.REF && x.Kind != token.RNG {
+			return x
+		}
+		v := lit(x.Value, rows, cols)
+		if v == "#REF!" {
+			return &ast.BasicLit{ValuePos: x.ValuePos, Kind: token.ERREF, Value: v}
+		}
+		return &ast.BasicLit{ValuePos: x.ValuePos, Kind: x.Kind, Value: v}
+	case *ast.ParenExpr:
+		return &ast.ParenExpr{Lparen: x.Lparen, X: move(x.X, rows, cols), Rparen: x.Rparen}
+	case *ast.CallExpr:
+		args := make([]ast.Expr, len(x.Args))
+		for i, a := range x.Args {
+			args[i] = move(a, rows, cols)
+		}
+		return &ast.CallExpr{Fun: move(x.Fun, rows, cols), Lparen: x.Lparen, Args: args, Rparen: x.Rparen}
+	case *ast.UnaryExpr:
+		y := move(x.X, rows, cols)
+		if lit, ok := y.(*ast.BasicLit); ok && x.Op == token.SPILL && lit.Kind == token.ERREF {
+			// #REF! is not a spill reference
+			return y
+		}
+		return &ast.UnaryExpr{OpPos: x.OpPos, Op: x.Op, X: y}
+	case *ast.BinaryExpr:
+		return &ast.BinaryExpr{X: move(x.X, rows, cols), OpPos: x.OpPos, Op: x.Op, Y: move(x.Y, rows, cols)}
+	case *ast.ArrayLit:
+		// array constants hold no references
+		return x
+	}
+	return x
+}
+
+// Source translates the formula src like Formula, keeping its layout.
+// src may start with '='.
+func Source(src string, from, to ref.Cell) (string, error) {
+	rows, cols := to.Row-from.Row, to.Col-from.Col
+	return convert(src, 0, func(s string) string { return lit(s, rows, cols) })
+}
+
+// Rewrite returns src, the source of formula x, with the text of each
+// cell or range reference replaced by f of it.
+func Rewrite(src string, x ast.Expr, f func(lit string) string) string {
+	var spans []span
+	ast.Inspect(x, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.BasicLit); ok && (lit.Kind == token.REF || lit.Kind == token.RNG) {
+			spans = append(spans, span{lit.ValuePos, len(lit.Value)})
+		}
+		return true
+	})
+	sort.Slice(spans, func(i, j int) bool { return spans[i].pos < spans[j].pos })
+	return splice(src, spans, func(i int, text string, end int) (string, int) {
+		repl := f(text)
+		if repl == "#REF!" && end < len(src) && src[end] == '#' {
+			// #REF! is not a spill reference
+			end++
+		}
+		return repl, end
+	})
+}
+
+// RewriteNames returns src, the source of formula x, with each name
+// replaced by f of its identifier. Names include the names of functions
+// and the names bound by LET and LAMBDA.
+func RewriteNames(src string, x ast.Expr, f func(id *ast.Ident) string) string {
+	var ids []*ast.Ident
+	ast.Inspect(x, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			ids = append(ids, id)
+		}
+		return true
+	})
+	sort.Slice(ids, func(i, j int) bool { return ids[i].NamePos < ids[j].NamePos })
+	spans := make([]span, len(ids))
+	for i, id := range ids {
+		spans[i] = span{id.NamePos, len(id.Name)}
+	}
+	return splice(src, spans, func(i int, _ string, end int) (string, int) { return f(ids[i]), end })
+}
+
+// span is the position and length of a token in the source of a formula.
+type span struct {
+	pos token.Pos
+	n   int
+}
+
+// splice returns src with the text of each span, in order of position,
+// replaced by f of its index and text. f may extend the replaced text to
+// a new end offset.
+func splice(src string, spans []span, f func(i int, text string, end int) (string, int)) string {
+	var b strings.Builder
+	last := 0
+	for i, sp := range spans {
+		start := int(sp.pos) - 1
+		repl, end := f(i, src[start:start+sp.n], start+sp.n)
+		b.WriteString(src[last:start])
+		b.WriteString(repl)
+		last = end
+	}
+	b.WriteString(src[last:])
+	return b.String()
+}
@@ -0,0 +1,124 @@
+package translate
+
+import (
+	"github.com/ajz01/calc/ast"
+	"github.com/ajz01/calc/parser"
+	"github.com/ajz01/calc/ref"
+	"strings"
+	"testing"
+)
+
+func cell(s string) ref.Cell {
+	c, err := ref.ParseCell(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		src, from, to, want string
+	}{
+		{"=A1+$B$1", "C1", "D5", "=B5+$B$1"},
+		{"SUM(A1:B2) * $C3 + D$4", "E5", "F7", "SUM(B3:C4) * $C5 + E$4"},
+		{"SUM(A:B)+SUM($A:C)", "D1", "E9", "SUM(B:C)+SUM($A:D)"},
+		{"Data!A1+'My Sheet'!$A1", "B1", "B2", "Data!A2+'My Sheet'!$A2"},
+		{"A2+B1", "C2", "C1", "A1+#REF!"},
+		{"A1#", "B2", "B1", "#REF!"},
+		{"SUM(B$1:B1)", "C1", "C4", "SUM(B$1:B4)"},
+		{"SUM(B$5:B9)", "C9", "C1", "SUM(B1:B$5)"},
+		{`"A1"&LOG10(A1)`, "A1", "B1", `"A1"&LOG10(B1)`},
+	}
+	for _, test := range tests {
+		got, err := Source(test.src, cell(test.from), cell(test.to))
+		if err != nil {
+			t.Errorf("Source(%q) %v", test.src, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Source(%q, %s, %s) = %q want %q", test.src, test.from, test.to, got, test.want)
+		}
+	}
+	if _, err := Source("=1+", cell("A1"), cell("A2")); err == nil {
+		t.Errorf("Source(=1+) succeeded want error")
+	}
+}
+
+func TestFormula(t *testing.T) {
+	x, err := parser.ParseBytes([]byte("A1+$B$1*SUM(C1:C2)-Z9#"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	y := Formula(x, cell("A1"), cell("A2"))
+	var got []string
+	ast.Inspect(y, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.BasicLit); ok {
+			got = append(got, lit.Value)
+		}
+		return true
+	})
+	want := []string{"A2", "$B$1", "C2:C3", "Z10"}
+	if len(got) != len(want) {
+		t.Fatalf("Formula refs = %v want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("Formula refs = %v want %v", got, want)
+			break
+		}
+	}
+	if lit := x.(*ast.BinaryExpr).X.(*ast.BinaryExpr).X.(*ast.BasicLit); lit.Value != "A1" {
+		t.Errorf("Formula changed its argument: %s", lit.Value)
+	}
+}
+
+func TestR1C1(t *testing.T) {
+	tests := []struct {
+		a1, anchor, r1c1 string
+	}{
+		{"=A1+$B$1", "C1", "=RC[-2]+R1C2"},
+		{"SUM(A1:A9) / Data!B$2", "B10", "SUM(R[-9]C[-1]:R[-1]C[-1]) / Data!R2C"},
+		{"SUM(A:B)+SUM($A:A)", "C5", "SUM(C[-2]:C[-1])+SUM(C1:C[-2])"},
+	}
+	for _, test := range tests {
+		got, err := ToR1C1(test.a1, cell(test.anchor))
+		if err != nil || got != test.r1c1 {
+			t.Errorf("ToR1C1(%q, %s) = %q %v want %q", test.a1, test.anchor, got, err, test.r1c1)
+		}
+		got, err = ToA1(test.r1c1, cell(test.anchor))
+		if err != nil || got != test.a1 {
+			t.Errorf("ToA1(%q, %s) = %q %v want %q", test.r1c1, test.anchor, got, err, test.a1)
+		}
+	}
+
+	// a formula filled down a column reads the same in every cell
+	for row := 2; row <= 4; row++ {
+		c := cell("B2")
+		c.Row = row
+		a1, _ := Source("=A1*2+SUM($C$1:C1)", cell("B2"), c)
+		if got, _ := ToR1C1(a1, c); got != "=R[-1]C[-1]*2+SUM(R1C3:R[-1]C[1])" {
+			t.Errorf("ToR1C1(%q, %s) = %q", a1, c, got)
+		}
+	}
+	if got, _ := ToA1("R[-1]C+1", cell("A1")); got != "#REF!+1" {
+		t.Errorf("ToA1(R[-1]C+1, A1) = %q want #REF!+1", got)
+	}
+}
+
+func TestRewriteNames(t *testing.T) {
+	src := "SUM(Rate, rate*2) + LET(x, RATE, x)"
+	x, err := parser.ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := RewriteNames(src, x, func(id *ast.Ident) string {
+		if strings.EqualFold(id.Name, "rate") {
+			return "Tax"
+		}
+		return id.Name
+	})
+	if want := "SUM(Tax, Tax*2) + LET(x, Tax, x)"; got != want {
+		t.Errorf("RewriteNames(%q) = %q want %q", src, got, want)
+	}
+}
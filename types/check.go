@@ -0,0 +1,315 @@
+package types
+
+import (
+	"fmt"
+	"github.com/ajz01/calc/ast"
+	"github.com/ajz01/calc/token"
+	"strings"
+)
+
+// An Error describes a type-checking error.
+type Error struct {
+	Pos token.Pos
+	Msg string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%d: %s", e.Pos, e.Msg)
+}
+
+// Info holds the results of type checking.
+type Info struct {
+	Types map[ast.Expr]Type
+}
+
+// TypeOf returns the type of expression x, or Invalid if not found.
+func (info *Info) TypeOf(x ast.Expr) Type {
+	if t, ok := info.Types[x]; ok {
+		return t
+	}
+	return Invalid
+}
+
+// Config configures the type checker. If Funcs is nil calls are not
+// checked and have type Any. If Error is set it is called with every
+// error found, otherwise checking continues but only the first error is
+// returned.
+type Config struct {
+	Funcs Funcs
+	Error func(err error)
+}
+
+type checker struct {
+	conf     *Config
+	info     *Info
+	firstErr error
+	locals   map[string]int // names bound by LET and LAMBDA, upper case
+}
+
+// Check type-checks the formula x and records the type of every
+// expression in info, if info is not nil. It returns the first error.
+func (conf *Config) Check(x ast.Expr, info *Info) error {
+	if info == nil {
+		info = new(Info)
+	}
+	if info.Types == nil {
+		info.Types = make(map[ast.Expr]Type)
+	}
+	check := checker{conf: conf, info: info}
+	check.expr(x)
+	return check.firstErr
+}
+
+func (check *checker) errorf(pos token.Pos, format string, args ...interface{}) {
+	err := Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+	if check.firstErr == nil {
+		check.firstErr = err
+	}
+	if check.conf.Error != nil {
+		check.conf.Error(err)
+	}
+}
+
+func (check *checker) record(x ast.Expr, t Type) Type {
+	check.info.Types[x] = t
+	return t
+}
+
+func (check *checker) expr(x ast.Expr) Type {
+	switch n := x.(type) {
+	case *ast.BadExpr:
+		return check.record(x, Invalid)
+
+	case *ast.BasicLit:
+		return check.record(x, litType(n.Kind))
+
+	case *ast.Ident, *ast.EmptyExpr:
+		return check.record(x, Any)
+
+	case *ast.ParenExpr:
+		return check.record(x, check.expr(n.X))
+
+	case *ast.UnaryExpr:
+		t := check.expr(n.X)
+		switch n.Op {
+		case token.ADD, token.SUB:
+			check.numeric(n.X, t, n.Op.String())
+			return check.record(x, arith(t, Number))
+		case token.SPILL:
+			if t != Reference && t != Any {
+				check.errorf(n.OpPos, "cannot use %s as spill anchor", t)
+			}
+			return check.record(x, Reference)
+		case token.AT:
+			if t == Reference || t == Array {
+				return check.record(x, Any)
+			}
+			return check.record(x, t)
+		}
+		check.errorf(n.OpPos, "invalid unary operator %s", n.Op)
+		return check.record(x, Invalid)
+
+	case *ast.BinaryExpr:
+		tx := check.expr(n.X)
+		ty := check.expr(n.Y)
+		switch n.Op {
+		case token.ADD, token.SUB, token.MUL, token.QUO, token.EXP:
+			check.numeric(n.X, tx, n.Op.String())
+			check.numeric(n.Y, ty, n.Op.String())
+			return check.record(x, arith(tx, ty))
+		case token.EQL, token.NOT, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			if tx == Array || ty == Array {
+				return check.record(x, Array)
+			}
+			return check.record(x, Logical)
+		case token.CONCAT:
+			if tx == Array || ty == Array {
+				return check.record(x, Array)
+			}
+			return check.record(x, Text)
+		}
+		check.errorf(n.OpPos, "invalid binary operator %s", n.Op)
+		return check.record(x, Invalid)
+
+	case *ast.CallExpr:
+		return check.record(x, check.call(n))
+
+	case *ast.ArrayLit:
+		for _, row := range n.Rows {
+			for _, e := range row {
+				check.expr(e)
+			}
+		}
+		return check.record(x, Array)
+	}
+
+	check.errorf(x.Pos(), "unexpected expression %T", x)
+	return check.record(x, Invalid)
+}
+
+func (check *checker) call(n *ast.CallExpr) Type {
+	id, ok := n.Fun.(*ast.Ident)
+	var args []Type
+	if ok && check.binds(id.Name) {
+		args = check.bindings(n, id.Name)
+	} else {
+		args = make([]Type, len(n.Args))
+		for i, a := range n.Args {
+			args[i] = check.expr(a)
+		}
+	}
+
+	switch {
+	case !ok:
+		// a call of a call or parenthesized lambda
+		if t := check.expr(n.Fun); t != Any {
+			check.errorf(n.Fun.Pos(), "cannot call non-function")
+			return Invalid
+		}
+		return Any
+	case check.locals[strings.ToUpper(id.Name)] > 0:
+		// a lambda bound by LET or LAMBDA
+		return check.record(id, Any)
+	case check.conf.Funcs == nil:
+		return Any
+	}
+	sig, ok := check.conf.Funcs.Lookup(id.Name)
+	if !ok {
+		check.errorf(id.Pos(), "unknown function %s", id.Name)
+		return Invalid
+	}
+
+	if len(args) < sig.MinArgs() {
+		check.errorf(n.Rparen, "not enough arguments in call to %s", id.Name)
+	} else if max := sig.MaxArgs(); max >= 0 && len(args) > max {
+		check.errorf(n.Args[max].Pos(), "too many arguments in call to %s", id.Name)
+	}
+
+	lifted := false
+	for i, a := range n.Args {
+		p, ok := sig.Param(i)
+		if !ok {
+			break
+		}
+		check.assign(a, args[i], p, id.Name)
+		// arrays passed for scalar parameters are lifted element by element
+		repeated := sig.Variadic && i >= len(sig.Params)-1
+		lifted = lifted || args[i] == Array && p.Type.IsScalar() && !p.Lazy && !repeated
+	}
+
+	if lifted {
+		return Array
+	}
+	return sig.Result
+}
+
+// binds reports whether fun is LET or LAMBDA, unless a local name
+// shadows it.
+func (check *checker) binds(fun string) bool {
+	name := strings.ToUpper(fun)
+	return (name == "LET" || name == "LAMBDA") && check.locals[name] == 0
+}
+
+// bindings checks the arguments of LET and LAMBDA, whose names are in
+// scope in the arguments that follow them: LET binds every other
+// argument before the last, LAMBDA every argument before the last.
+func (check *checker) bindings(n *ast.CallExpr, fun string) []Type {
+	if check.locals == nil {
+		check.locals = make(map[string]int)
+	}
+	let := strings.EqualFold(fun, "LET")
+	args := make([]Type, len(n.Args))
+	var bound []string
+	for i, a := range n.Args {
+		id, ok := a.(*ast.Ident)
+		if i == len(n.Args)-1 || let && i%2 == 1 {
+			args[i] = check.expr(a)
+			continue
+		}
+		if !ok {
+			check.errorf(a.Pos(), "%s name must be an identifier", fun)
+			args[i] = check.expr(a)
+			continue
+		}
+		args[i] = check.record(a, Any)
+		name := strings.ToUpper(id.Name)
+		check.locals[name]++
+		bound = append(bound, name)
+	}
+	for _, name := range bound {
+		check.locals[name]--
+	}
+	return args
+}
+
+// assign checks that argument x of type t can be passed as parameter p.
+func (check *checker) assign(x ast.Expr, t Type, p Param, fun string) {
+	switch p.Type {
+	case Reference:
+		if t != Reference && t != Any && t != Err {
+			check.errorf(x.Pos(), "cannot use %s as reference in argument to %s", t, fun)
+		}
+	case Array:
+		if t.IsScalar() && t != Err {
+			check.errorf(x.Pos(), "cannot use %s as array in argument to %s", t, fun)
+		}
+	case Number, Logical:
+		check.numeric(x, t, fun)
+	}
+}
+
+// numeric reports an error if x is a text constant that cannot be
+// converted to a number. Other text is only known at evaluation time.
+func (check *checker) numeric(x ast.Expr, t Type, context string) {
+	if t != Text {
+		return
+	}
+	lit, ok := unparen(x).(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return
+	}
+	s := unquote(lit.Value)
+	if _, ok := ParseNumber(s); !ok {
+		check.errorf(lit.Pos(), "cannot convert %s (text) to number in %s", lit.Value, context)
+	}
+}
+
+func litType(kind token.Token) Type {
+	switch kind {
+	case token.INT, token.FLOAT, token.IMAG:
+		return Number
+	case token.STRING:
+		return Text
+	case token.BOOL:
+		return Logical
+	case token.ERR, token.ERREF:
+		return Err
+	case token.REF, token.RNG:
+		return Reference
+	}
+	return Invalid
+}
+
+// arith returns the result type of an arithmetic operation.
+func arith(x, y Type) Type {
+	if x == Array || y == Array {
+		return Array
+	}
+	return Number
+}
+
+func unparen(x ast.Expr) ast.Expr {
+	if p, isParen := x.(*ast.ParenExpr); isParen {
+		x = unparen(p.X)
+	}
+	return x
+}
+
+// unquote strips the quotes of a string literal and collapses doubled
+// quotes.
+func unquote(lit string) string {
+	if len(lit) >= 2 && lit[0] == '"' && lit[len(lit)-1] == '"' {
+		lit = lit[1 : len(lit)-1]
+	}
+	return strings.Replace(lit, `""`, `"`, -1)
+}
@@ -0,0 +1,132 @@
+package types
+
+import (
+	"github.com/ajz01/calc/ast"
+	"github.com/ajz01/calc/parser"
+	"strings"
+	"testing"
+)
+
+type funcs map[string]*Signature
+
+func (f funcs) Lookup(name string) (*Signature, bool) {
+	sig, ok := f[strings.ToUpper(name)]
+	return sig, ok
+}
+
+var testFuncs = funcs{
+	"SUM": {
+		Params:   []Param{{Name: "number", Type: Number}},
+		Variadic: true,
+		Result:   Number,
+	},
+	"ROWS": {
+		Params: []Param{{Name: "range", Type: Reference}},
+		Result: Number,
+	},
+	"LET": {
+		Params:   []Param{{Name: "name", Type: Any, Lazy: true}},
+		Variadic: true,
+		Result:   Any,
+	},
+	"LAMBDA": {
+		Params:   []Param{{Name: "parameter", Type: Any, Lazy: true}},
+		Variadic: true,
+		Result:   Any,
+	},
+	"LEFT": {
+		Params: []Param{{Name: "text", Type: Text}, {Name: "count", Type: Number, Optional: true}},
+		Result: Text,
+	},
+}
+
+func check(t *testing.T, src string) (ast.Expr, *Info, error) {
+	x, err := parser.ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("ParseBytes(%q) %v", src, err)
+	}
+	conf := Config{Funcs: testFuncs}
+	info := &Info{}
+	err = conf.Check(x, info)
+	return x, info, err
+}
+
+func TestCheckTypes(t *testing.T) {
+	tests := []struct {
+		src  string
+		want Type
+	}{
+		{"1+2", Number},
+		{`"abc"`, Text},
+		{`"3"*2`, Number},
+		{`"50%"*2`, Number},
+		{`"$1,000"+1`, Number},
+		{`-" (1,234.5) "`, Number},
+		{"A1:D3", Reference},
+		{"1<2", Logical},
+		{"SUM(A1:D3)", Number},
+		{`LEFT("abc",2)`, Text},
+		{`LEFT("abc",)`, Text},
+		{"-(ROWS(A1:D3))", Number},
+		{`{1,2;"a",TRUE}`, Array},
+		{"{1,2}+1", Array},
+		{"SUM({1,2})", Number},
+		{`LEFT({"abc","de"},1)`, Array},
+		{"D2#", Reference},
+		{"SUM(D2#)", Number},
+		{"@A1:A3", Any},
+		{"@1", Number},
+		{"LET(x,2,f,LAMBDA(a,a*x),f(3))", Any},
+		{"LAMBDA(a,b,a+b)(1,2)", Any},
+		{"LET(sum,LAMBDA(x,x),sum(1))", Any},
+	}
+	for _, test := range tests {
+		x, info, err := check(t, test.src)
+		if err != nil {
+			t.Errorf("Check(%q) %v", test.src, err)
+			continue
+		}
+		if got := info.TypeOf(x); got != test.want {
+			t.Errorf("Check(%q) = %s want %s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		msg string
+	}{
+		{`"abc"*2`, "cannot convert"},
+		{`SUM("abc")`, "cannot convert"},
+		{`"Inf"*2`, "cannot convert"},
+		{`"NaN"+1`, "cannot convert"},
+		{`"0x10"*2`, "cannot convert"},
+		{`"1.5,0"*2`, "cannot convert"},
+		{"ROWS(1)", "as reference"},
+		{"ROWS(A1,A2)", "too many arguments"},
+		{"LEFT()", "not enough arguments"},
+		{"FOO(1)", "unknown function"},
+		{"LET(1,2,3)", "must be an identifier"},
+		{"LET(,2,3)", "must be an identifier"},
+		{"LET(f,1,f(2))+f(2)", "unknown function f"},
+		{"(1)(2)", "cannot call non-function"},
+	}
+	for _, test := range tests {
+		_, _, err := check(t, test.src)
+		if err == nil || !strings.Contains(err.Error(), test.msg) {
+			t.Errorf("Check(%q) = %v want %q", test.src, err, test.msg)
+		}
+	}
+}
+
+func TestCheckRecordsSubexpressions(t *testing.T) {
+	x, info, err := check(t, `SUM(1,"2")`)
+	if err != nil {
+		t.Fatalf("Check %v", err)
+	}
+	call := x.(*ast.CallExpr)
+	if got := info.TypeOf(call.Args[1]); got != Text {
+		t.Errorf("TypeOf(%q) = %s want text", `"2"`, got)
+	}
+}
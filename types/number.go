@@ -0,0 +1,42 @@
+package types
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParseNumber converts numeric text such as "12", " -1.5e3 ", "1,234.5",
+// "$12", "50%" or "(7)" to a number. It is the rule both the checker and
+// the evaluator use for text used as a number.
+func ParseNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	neg := false
+	if len(s) > 2 && s[0] == '(' && s[len(s)-1] == ')' {
+		neg, s = true, s[1:len(s)-1]
+	}
+	pct := strings.HasSuffix(s, "%")
+	s = strings.TrimSuffix(s, "%")
+	sign := ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = s[:1], s[1:]
+	}
+	s = strings.TrimPrefix(s, "$")
+	if s == "" || s[0] == ',' || strings.Trim(s, "0123456789,.eE+-") != "" {
+		return 0, false
+	}
+	// thousands separators only in the integer part
+	if i := strings.IndexAny(s, ".eE"); i >= 0 && strings.Contains(s[i:], ",") {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(sign+strings.Replace(s, ",", "", -1), 64)
+	if err != nil {
+		return 0, false
+	}
+	if pct {
+		f /= 100
+	}
+	if neg {
+		f = -f
+	}
+	return f, true
+}
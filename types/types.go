@@ -0,0 +1,95 @@
+package types
+
+import "strconv"
+
+// Type is the inferred type of a formula expression.
+type Type int
+
+const (
+	Invalid Type = iota
+	Number
+	Text
+	Logical
+	Err
+	Reference
+	Array
+	Any
+)
+
+var typeNames = [...]string{
+	Invalid:   "invalid",
+	Number:    "number",
+	Text:      "text",
+	Logical:   "logical",
+	Err:       "error",
+	Reference: "reference",
+	Array:     "array",
+	Any:       "any",
+}
+
+func (t Type) String() string {
+	if 0 <= t && t < Type(len(typeNames)) {
+		return typeNames[t]
+	}
+	return "type(" + strconv.Itoa(int(t)) + ")"
+}
+
+// IsScalar reports whether a value of type t holds a single value.
+func (t Type) IsScalar() bool {
+	return t == Number || t == Text || t == Logical || t == Err
+}
+
+// Param is a formal function parameter. A parameter of type Reference
+// only accepts ranges and cell references; Any accepts everything.
+// Arguments for Lazy parameters are only evaluated when the function
+// asks for them.
+type Param struct {
+	Name     string
+	Type     Type
+	Optional bool
+	Lazy     bool
+}
+
+// Signature describes the parameters and result of a function.
+// If Variadic is set the last parameter may be repeated.
+type Signature struct {
+	Params   []Param
+	Variadic bool
+	Result   Type
+}
+
+// MinArgs returns the number of required arguments.
+func (s *Signature) MinArgs() int {
+	n := 0
+	for i, p := range s.Params {
+		if !p.Optional {
+			n = i + 1
+		}
+	}
+	return n
+}
+
+// MaxArgs returns the maximum number of arguments, or -1 if unbounded.
+func (s *Signature) MaxArgs() int {
+	if s.Variadic {
+		return -1
+	}
+	return len(s.Params)
+}
+
+// Param returns the formal parameter matching argument i.
+func (s *Signature) Param(i int) (p Param, ok bool) {
+	n := len(s.Params)
+	switch {
+	case i < n:
+		return s.Params[i], true
+	case s.Variadic && n > 0:
+		return s.Params[n-1], true
+	}
+	return
+}
+
+// Funcs looks up function signatures by name, ignoring case.
+type Funcs interface {
+	Lookup(name string) (*Signature, bool)
+}
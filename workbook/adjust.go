@@ -0,0 +1,241 @@
+package workbook
+
+import (
+	"fmt"
+	"github.com/ajz01/calc/ast"
+	"github.com/ajz01/calc/parser"
+	"github.com/ajz01/calc/ref"
+	"github.com/ajz01/calc/translate"
+)
+
+// shift is an insertion of n rows or columns before index at, or a
+// deletion of -n rows or columns starting at index at.
+type shift struct {
+	cols  bool
+	at, n int
+}
+
+func (sh shift) max() int {
+	if sh.cols {
+		return ref.MaxCol
+	}
+	return ref.MaxRow
+}
+
+func (sh shift) get(c ref.Cell) int {
+	if sh.cols {
+		return c.Col
+	}
+	return c.Row
+}
+
+func (sh shift) set(c ref.Cell, i int) ref.Cell {
+	if sh.cols {
+		c.Col = i
+	} else {
+		c.Row = i
+	}
+	return c
+}
+
+// index returns where index i moves to, or false if it is deleted or
+// moves off the sheet.
+func (sh shift) index(i int) (int, bool) {
+	switch {
+	case i < sh.at:
+		return i, true
+	case sh.n > 0:
+		return i + sh.n, i+sh.n <= sh.max()
+	case i >= sh.at-sh.n:
+		return i + sh.n, true
+	}
+	return 0, false
+}
+
+// cell returns where cell c moves to, or false if it is deleted or
+// moves off the sheet.
+func (sh shift) cell(c ref.Cell) (ref.Cell, bool) {
+	i, ok := sh.index(sh.get(c))
+	return sh.set(c, i), ok
+}
+
+// rng returns range r adjusted for the shift, or false if all of its
+// cells are deleted or move off the sheet. Rows or columns inserted
+// inside r extend it and deleted ones shrink it; whole rows or columns
+// along the shift keep their extent.
+func (sh shift) rng(r ref.Range) (ref.Range, bool) {
+	from, to := sh.get(r.From), sh.get(r.To)
+	if from == 1 && to == sh.max() {
+		return r, true
+	}
+	f, okf := sh.index(from)
+	t, okt := sh.index(to)
+	switch {
+	case sh.n > 0 && !okf:
+		return ref.Range{}, false
+	case sh.n > 0 && !okt:
+		t = sh.max()
+	case sh.n < 0 && !okf && !okt:
+		return ref.Range{}, false
+	case sh.n < 0 && !okf:
+		f = sh.at
+	case sh.n < 0 && !okt:
+		t = sh.at - 1
+	}
+	return ref.Range{From: sh.set(r.From, f), To: sh.set(r.To, t)}, true
+}
+
+// lit returns the reference literal lit in a formula on sheet t adjusted
+// for a shift of the cells of sheet s, or "#REF!" if the cells it refers
+// to are deleted.
+func (sh shift) lit(s, t *Sheet, lit string) string {
+	r, err := ref.Parse(lit)
+	if err != nil || t.target(r) != s {
+		return lit
+	}
+	rng, ok := sh.rng(r.Range)
+	if !ok {
+		return "#REF!"
+	}
+	if rng == r.Range {
+		return lit
+	}
+	r.Range = rng
+	return r.String()
+}
+
+// InsertRows inserts n empty rows before row at, moving the rows below
+// down. References to the moved cells are adjusted in every formula;
+// ranges spanning the inserted rows grow to include them. Inserting
+// rows that would push cells off the sheet fails with ErrShift. If a
+// rewritten formula does not parse, the error is returned and the
+// workbook is left unchanged.
+func (s *Sheet) InsertRows(at, n int) error {
+	return s.shift(shift{at: at, n: n})
+}
+
+// DeleteRows deletes n rows starting at row at, moving the rows below
+// up. References to deleted cells become #REF!; ranges that include
+// deleted rows shrink.
+func (s *Sheet) DeleteRows(at, n int) error {
+	return s.shift(shift{at: at, n: -n})
+}
+
+// InsertCols inserts n empty columns before column at, like InsertRows.
+func (s *Sheet) InsertCols(at, n int) error {
+	return s.shift(shift{cols: true, at: at, n: n})
+}
+
+// DeleteCols deletes n columns starting at column at, like DeleteRows.
+func (s *Sheet) DeleteCols(at, n int) error {
+	return s.shift(shift{cols: true, at: at, n: -n})
+}
+
+// shift moves the cells of s and rewrites the formulas on any sheet and
+// the definitions of names that refer to them. The formulas of s, those
+// rewritten and those that use the rewritten names are computed again by
+// the next Recalc. All formulas are rewritten before any change is made,
+// so if one of them fails to parse the workbook is left unchanged.
+func (s *Sheet) shift(sh shift) error {
+	if sh.n == 0 || sh.at < 1 || sh.at > sh.max() || sh.n < 0 && sh.at-sh.n-1 > sh.max() {
+		return ErrShift
+	}
+	for c := range s.cells {
+		if _, ok := sh.cell(c); !ok && sh.n > 0 {
+			return ErrShift
+		}
+	}
+
+	w := s.wb
+	defs := make(map[*Name]formula)
+	for _, d := range w.definitions() {
+		src := translate.Rewrite(d.Formula, d.Expr, func(lit string) string {
+			if r, err := ref.Parse(lit); err == nil && r.Sheet == "" && d.sheet == nil {
+				// refers to the sheet of the formula using the name
+				return lit
+			}
+			return sh.lit(s, d.on(s), lit)
+		})
+		if src != d.Formula {
+			x, err := reparse(src)
+			if err != nil {
+				return err
+			}
+			defs[d] = formula{src, x}
+		}
+	}
+	formulas := make(map[*Cell]formula)
+	for _, t := range w.sheets {
+		for _, cell := range t.cells {
+			if cell.Expr == nil {
+				continue
+			}
+			src := translate.Rewrite(cell.Formula, cell.Expr, func(lit string) string { return sh.lit(s, t, lit) })
+			if src != cell.Formula {
+				x, err := reparse(src)
+				if err != nil {
+					return err
+				}
+				formulas[cell] = formula{src, x}
+			}
+		}
+	}
+
+	cells := make(map[ref.Cell]*Cell, len(s.cells))
+	for c, cell := range s.cells {
+		n := node{s: s, c: c}
+		w.graph.remove(n)
+		delete(w.dirty, n)
+		delete(w.volatile, n)
+		if c, ok := sh.cell(c); ok {
+			cells[c] = cell
+		}
+	}
+	s.cells = cells
+	s.anchors, s.arrays = make(map[ref.Cell]ref.Range), newIndex()
+	var names []string
+	for _, d := range w.definitions() {
+		if f, ok := defs[d]; ok {
+			d.Formula, d.Expr = f.src, f.x
+			names = append(names, d.Name)
+		}
+	}
+	for _, t := range w.sheets {
+		for _, c := range t.Cells() {
+			cell := t.cells[c]
+			if cell.Expr == nil {
+				continue
+			}
+			f, ok := formulas[cell]
+			if !ok && t != s {
+				continue
+			}
+			if ok {
+				cell.Formula, cell.Expr = f.src, f.x
+			}
+			if t == s {
+				cell.area, cell.spilled = ref.Range{From: c, To: c}, false
+			}
+			t.edit(c, cell)
+		}
+	}
+	if len(names) > 0 {
+		w.refresh(names...)
+	}
+	return nil
+}
+
+// formula is a rewritten formula and its parse.
+type formula struct {
+	src string
+	x   ast.Expr
+}
+
+// reparse parses src, a formula rewritten from one that parsed.
+func reparse(src string) (ast.Expr, error) {
+	x, err := parser.ParseBytes([]byte(src))
+	if err != nil {
+		return nil, fmt.Errorf("workbook: rewritten formula %q does not parse: %w", src, err)
+	}
+	return x, nil
+}
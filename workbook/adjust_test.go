@@ -0,0 +1,146 @@
+package workbook
+
+import (
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/ref"
+	"testing"
+)
+
+func TestInsertDelete(t *testing.T) {
+	tests := []struct {
+		op      func(s *Sheet) error
+		formula string
+		want    string
+	}{
+		{func(s *Sheet) error { return s.InsertRows(5, 1) }, "A10+A2", "A11+A2"},
+		{func(s *Sheet) error { return s.InsertRows(5, 2) }, "SUM(A1:A10)", "SUM(A1:A12)"},
+		{func(s *Sheet) error { return s.InsertRows(1, 1) }, "SUM(A:A)+SUM(A1:B2)", "SUM(A:A)+SUM(A2:B3)"},
+		{func(s *Sheet) error { return s.InsertRows(5, 1) }, "SUM(B1:B4)", "SUM(B1:B4)"},
+		{func(s *Sheet) error { return s.DeleteRows(5, 1) }, "A10 + A5", "A9 + #REF!"},
+		{func(s *Sheet) error { return s.DeleteRows(3, 4) }, "SUM(A1:A10)", "SUM(A1:A6)"},
+		{func(s *Sheet) error { return s.DeleteRows(3, 4) }, "SUM(A4:A10)", "SUM(A3:A6)"},
+		{func(s *Sheet) error { return s.DeleteRows(3, 4) }, "SUM(A1:A4)", "SUM(A1:A2)"},
+		{func(s *Sheet) error { return s.DeleteRows(3, 4) }, "SUM(A3:A6)", "SUM(#REF!)"},
+		{func(s *Sheet) error { return s.DeleteRows(2, 1) }, "SUM(A2#)", "SUM(#REF!)"},
+		{func(s *Sheet) error { return s.InsertCols(2, 1) }, "B1*C3+A1", "C1*D3+A1"},
+		{func(s *Sheet) error { return s.InsertCols(2, 1) }, "SUM(A:C)", "SUM(A:D)"},
+		{func(s *Sheet) error { return s.DeleteCols(1, 2) }, "SUM(A:C)+D2", "SUM(A:A)+B2"},
+		{func(s *Sheet) error { return s.DeleteCols(2, 1) }, "SUM(B:B)", "SUM(#REF!)"},
+		{func(s *Sheet) error { return s.InsertRows(3, 1) }, "A1048576", "#REF!"},
+	}
+	for _, test := range tests {
+		w := New()
+		s := newSheet(t, w, "Sheet1", map[string]string{"Z20": test.formula})
+		if err := test.op(s); err != nil {
+			t.Fatalf("%s: %v", test.formula, err)
+		}
+		var got string
+		for _, c := range s.Cells() {
+			got = s.Cell(c).Formula
+		}
+		if got != test.want {
+			t.Errorf("%s adjusted to %s want %s", test.formula, got, test.want)
+		}
+	}
+}
+
+func TestInsertDeleteValues(t *testing.T) {
+	w := New()
+	s := newSheet(t, w, "Sheet1", map[string]string{
+		"B1": "=SUM(A1:A3)",
+		"B2": "=A3*10",
+	})
+	for i, v := range []float64{1, 2, 3} {
+		s.Set(ref.Cell{Row: i + 1, Col: 1}, eval.Number(v))
+	}
+	w.Recalc()
+
+	if err := s.InsertRows(2, 1); err != nil {
+		t.Fatal(err)
+	}
+	s.Set(cell("A2"), eval.Number(10))
+	w.Recalc()
+	for addr, want := range map[string]eval.Value{"B1": eval.Number(16), "B3": eval.Number(30), "A4": eval.Number(3), "B2": eval.Blank{}} {
+		if v := s.Value(cell(addr)); v != want {
+			t.Errorf("after insert %s = %v want %v", addr, v, want)
+		}
+	}
+
+	if err := s.DeleteRows(4, 1); err != nil {
+		t.Fatal(err)
+	}
+	w.Recalc()
+	if got := s.Cell(cell("B3")).Formula; got != "#REF!*10" {
+		t.Errorf("B3 = %s want #REF!*10", got)
+	}
+	for addr, want := range map[string]eval.Value{"B1": eval.Number(13), "B3": eval.ErrRef} {
+		if v := s.Value(cell(addr)); v != want {
+			t.Errorf("after delete %s = %v want %v", addr, v, want)
+		}
+	}
+
+	s.Set(cell("A1048576"), eval.Number(1))
+	if err := s.InsertRows(1, 1); err != ErrShift {
+		t.Errorf("InsertRows pushing a cell off the sheet = %v want %v", err, ErrShift)
+	}
+	if err := s.DeleteCols(0, 1); err != ErrShift {
+		t.Errorf("DeleteCols(0, 1) = %v want %v", err, ErrShift)
+	}
+}
+
+func TestInsertOtherSheet(t *testing.T) {
+	w := New()
+	data := newSheet(t, w, "My Data", nil)
+	report := newSheet(t, w, "Report", map[string]string{
+		"A1": "='My Data'!A2*2",
+		"A2": "=SUM('my data'!$A$1:$A$3)+A1",
+		"A3": "=A1",
+	})
+	for i, v := range []float64{1, 2, 3} {
+		data.Set(ref.Cell{Row: i + 1, Col: 1}, eval.Number(v))
+	}
+	w.Recalc()
+	if err := data.InsertRows(2, 1); err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[string]string{"A1": "'My Data'!A3*2", "A2": "SUM('my data'!$A$1:$A$4)+A1", "A3": "A1"} {
+		if got := report.Cell(cell(addr)).Formula; got != want {
+			t.Errorf("Report!%s = %s want %s", addr, got, want)
+		}
+	}
+	data.Set(cell("A2"), eval.Number(10))
+	w.Recalc()
+	if v := report.Value(cell("A2")); v != eval.Number(20) {
+		t.Errorf("Report!A2 = %v want 20", v)
+	}
+}
+
+func TestInsertError(t *testing.T) {
+	w := New()
+	s := newSheet(t, w, "Sheet1", map[string]string{
+		"A1": "B5*2",
+		"A2": "B5",
+		"B5": "3",
+	})
+	w.Names().Define("Data", "Sheet1!$B$5")
+	w.Recalc()
+	// a formula whose text no longer matches its parse, so that the
+	// rewritten text does not parse
+	s.cells[cell("A2")].Formula = "B5+("
+	if err := s.InsertRows(1, 1); err == nil {
+		t.Fatal("InsertRows succeeded want error")
+	}
+	for addr, want := range map[string]string{"A1": "B5*2", "A2": "B5+(", "B5": "3"} {
+		if got := s.Cell(cell(addr)); got == nil || got.Formula != want {
+			t.Errorf("%s = %v want %s", addr, got, want)
+		}
+	}
+	if d := w.Names().Lookup("Data"); d.Formula != "Sheet1!$B$5" {
+		t.Errorf("Data = %s want Sheet1!$B$5", d.Formula)
+	}
+	s.Set(cell("B5"), eval.Number(4))
+	w.Recalc()
+	if v := s.Value(cell("A1")); v != eval.Number(8) {
+		t.Errorf("A1 = %v want 8", v)
+	}
+}
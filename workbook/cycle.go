@@ -0,0 +1,147 @@
+package workbook
+
+import (
+	"fmt"
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/ref"
+	"math"
+	"strings"
+)
+
+// Addr is the address of a cell of a workbook.
+type Addr struct {
+	Sheet string
+	Cell  ref.Cell
+}
+
+func (a Addr) String() string { return a.Sheet + "!" + a.Cell.String() }
+
+// CycleError reports the circular references found by Recalc. Each cycle
+// is a path of cells in which each cell refers to the next and the last
+// refers to the first.
+type CycleError struct {
+	Cycles [][]Addr
+}
+
+func (e *CycleError) Error() string {
+	cycles := make([]string, len(e.Cycles))
+	for i, cycle := range e.Cycles {
+		path := make([]string, len(cycle)+1)
+		for j, a := range cycle {
+			path[j] = a.String()
+		}
+		path[len(cycle)] = cycle[0].String()
+		cycles[i] = strings.Join(path, " -> ")
+	}
+	return fmt.Sprintf("workbook: circular reference: %s", strings.Join(cycles, "; "))
+}
+
+// cyclic reports whether comp, a component found by order, is a cycle:
+// more than one formula, or one that refers to itself.
+func (w *Workbook) cyclic(comp []node) bool {
+	if len(comp) > 1 {
+		return true
+	}
+	self := false
+	w.dependents(comp[0], func(d node) { self = self || d == comp[0] })
+	return self
+}
+
+// cyclePath returns the shortest cycle through the first formula of
+// comp, in the order in which the formulas refer to each other.
+func (w *Workbook) cyclePath(comp []node) []node {
+	in := make(map[node]bool, len(comp))
+	for _, n := range comp {
+		in[n] = true
+	}
+	start := comp[0]
+	prev := map[node]node{}
+	queue := []node{start}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		found := false
+		w.dependents(n, func(d node) {
+			if found || !in[d] {
+				return
+			}
+			if _, seen := prev[d]; !seen {
+				prev[d] = n
+				queue = append(queue, d)
+				found = d == start
+			}
+		})
+		if found {
+			break
+		}
+	}
+	// prev leads from start back along the references, so the path
+	// read backwards follows them.
+	path := []node{start}
+	for n := prev[start]; n != start; n = prev[n] {
+		path = append(path, n)
+	}
+	return path
+}
+
+// loop records the cycle path and makes the results of the formulas of
+// comp #CALC!.
+func (w *Workbook) loop(path, comp []node) {
+	w.cycles = append(w.cycles, path)
+	for _, n := range comp {
+		w.looped[n] = true
+		if cell := n.s.cells[n.c]; cell != nil && !cell.busy {
+			n.s.layout(n.c, cell, eval.ErrCalc)
+		}
+	}
+}
+
+// loopAt handles a formula that needs the result of n, which is being
+// computed: a cycle through a reference that only shows when evaluated,
+// such as to a cell in a spill range. The formulas from n up the stack
+// are the cycle; they are #CALC! unless Iterate is set, in which case
+// they see the previous result of n.
+func (w *Workbook) loopAt(n node) {
+	if w.Iterate {
+		return
+	}
+	for i := len(w.stack) - 1; i >= 0; i-- {
+		if w.stack[i] == n {
+			path := append([]node(nil), w.stack[i:]...)
+			w.loop(path, path)
+			return
+		}
+	}
+}
+
+// iterate computes the formulas of the cycle comp in turn, each seeing
+// the latest results of the others, until no result changes by more
+// than MaxChange or MaxIterations rounds have been done.
+func (w *Workbook) iterate(comp []node) {
+	for _, n := range comp {
+		n.s.cells[n.c].dirty = false
+	}
+	for i := 0; i < w.MaxIterations; i++ {
+		done := true
+		for _, n := range comp {
+			cell := n.s.cells[n.c]
+			old := cell.Value
+			n.s.compute(n.c, cell)
+			done = done && w.converged(old, cell.Value)
+		}
+		if done {
+			return
+		}
+	}
+}
+
+// converged reports whether a result changed from v to u by no more
+// than MaxChange. Results other than numbers must not change.
+func (w *Workbook) converged(v, u eval.Value) bool {
+	x, ok1 := v.(eval.Number)
+	y, ok2 := u.(eval.Number)
+	if ok1 && ok2 {
+		return math.Abs(float64(x-y)) <= w.MaxChange
+	}
+	return v == u
+}
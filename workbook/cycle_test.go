@@ -0,0 +1,70 @@
+package workbook
+
+import (
+	"github.com/ajz01/calc/eval"
+	"math"
+	"testing"
+)
+
+func TestCycle(t *testing.T) {
+	w := New()
+	s := newSheet(t, w, "Sheet1", map[string]string{
+		"A1": "=B1+1",
+		"B1": "=C1*2",
+		"C1": "=A1",
+		"D1": "=A1+1",
+		"E1": "=E1",
+		"F1": "=1",
+	})
+	err := w.Recalc()
+	cerr, ok := err.(*CycleError)
+	if !ok {
+		t.Fatalf("Recalc() = %v want *CycleError", err)
+	}
+	want := "workbook: circular reference: Sheet1!E1 -> Sheet1!E1; Sheet1!A1 -> Sheet1!B1 -> Sheet1!C1 -> Sheet1!A1"
+	if got := cerr.Error(); got != want {
+		t.Errorf("Recalc() = %q want %q", got, want)
+	}
+	for addr, v := range map[string]eval.Value{"A1": eval.ErrCalc, "B1": eval.ErrCalc, "C1": eval.ErrCalc, "D1": eval.ErrCalc, "E1": eval.ErrCalc, "F1": eval.Number(1)} {
+		if got := s.Value(cell(addr)); got != v {
+			t.Errorf("%s = %v want %v", addr, got, v)
+		}
+	}
+
+	// breaking the cycle computes the formulas again
+	s.Set(cell("C1"), eval.Number(5))
+	if err := w.Recalc(); err != nil {
+		t.Errorf("Recalc() after edit = %v", err)
+	}
+	for addr, v := range map[string]eval.Value{"A1": eval.Number(11), "B1": eval.Number(10), "D1": eval.Number(12), "E1": eval.ErrCalc} {
+		if got := s.Value(cell(addr)); got != v {
+			t.Errorf("after edit %s = %v want %v", addr, got, v)
+		}
+	}
+}
+
+func TestIterate(t *testing.T) {
+	w := New()
+	w.Iterate = true
+	// interest on the average of the opening and closing balances
+	s := newSheet(t, w, "Sheet1", map[string]string{
+		"A2": "=(A1+A3)/2*0.1",
+		"A3": "=A1+A2",
+	})
+	s.Set(cell("A1"), eval.Number(1000))
+	if err := w.Recalc(); err != nil {
+		t.Fatalf("Recalc() = %v", err)
+	}
+	v, ok := s.Value(cell("A2")).(eval.Number)
+	if want := 2000 / 19.0; !ok || math.Abs(float64(v)-want) > w.MaxChange {
+		t.Errorf("A2 = %v want %v", v, want)
+	}
+
+	// the iterations stop at MaxIterations
+	w.MaxIterations, w.MaxChange = 3, 0
+	s = newSheet(t, w, "Sheet2", map[string]string{"A1": "=A1+1"})
+	w.Recalc()
+	if v := s.Value(cell("A1")); v != eval.Number(3) {
+		t.Errorf("A1 = %v want 3", v)
+	}
+}
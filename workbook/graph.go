@@ -0,0 +1,101 @@
+package workbook
+
+import (
+	"github.com/ajz01/calc/ast"
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/ref"
+	"github.com/ajz01/calc/token"
+)
+
+// node is a cell of a workbook.
+type node struct {
+	s *Sheet
+	c ref.Cell
+}
+
+// area is a range of cells of a sheet.
+type area struct {
+	s *Sheet
+	r ref.Range
+}
+
+// graph records which formulas depend on which cells. Each reference is
+// one edge, whatever the size of its range, so that SUM(A1:A100) is one
+// edge rather than 100. The edges are kept in a spatial index, so that a
+// change to A5 finds SUM(A1:A100) without testing the ranges of formulas
+// elsewhere.
+type graph struct {
+	edges *index          // precedents of the formulas
+	precs map[node][]area // precedents by dependent
+}
+
+func newGraph() *graph {
+	return &graph{edges: newIndex(), precs: make(map[node][]area)}
+}
+
+// precedents returns the ranges that the formula x on sheet s refers to,
+// including through the definitions of the names it uses. References to
+// sheets that do not exist are left out.
+func precedents(s *Sheet, x ast.Expr) []area {
+	var areas []area
+	s.inspect(x, func(s *Sheet, n ast.Node) {
+		if lit, ok := n.(*ast.BasicLit); ok && (lit.Kind == token.REF || lit.Kind == token.RNG) {
+			if r, err := ref.Parse(lit.Value); err == nil {
+				if t := s.target(r); t != nil {
+					areas = append(areas, area{s: t, r: r.Range})
+				}
+			}
+		}
+	})
+	return areas
+}
+
+// target returns the sheet that reference r in a formula on s refers to,
+// or nil if there is no such sheet.
+func (s *Sheet) target(r ref.Ref) *Sheet {
+	if r.Sheet == "" {
+		return s
+	}
+	return s.wb.Sheet(r.Sheet)
+}
+
+// calls reports whether the formula x on sheet s calls a function for
+// which f returns true, including through the definitions of the names
+// it uses.
+func (s *Sheet) calls(x ast.Expr, f func(*eval.Func) bool) bool {
+	found := false
+	s.inspect(x, func(_ *Sheet, n ast.Node) {
+		if call, ok := n.(*ast.CallExpr); ok && !found {
+			if id, ok := call.Fun.(*ast.Ident); ok {
+				if fn, ok := s.wb.Funcs.Func(id.Name); ok && f(fn) {
+					found = true
+				}
+			}
+		}
+	})
+	return found
+}
+
+// set replaces the precedents of the formula in cell n.
+func (g *graph) set(n node, precs []area) {
+	g.remove(n)
+	for _, a := range precs {
+		g.edges.add(a, n)
+	}
+	if len(precs) > 0 {
+		g.precs[n] = precs
+	}
+}
+
+// remove removes the edges to the formula in cell n.
+func (g *graph) remove(n node) {
+	for _, a := range g.precs[n] {
+		g.edges.remove(a, n)
+	}
+	delete(g.precs, n)
+}
+
+// dependents calls f for each formula that refers to a cell of a.
+func (g *graph) dependents(a area, f func(n node)) {
+	g.edges.search(a, func(e entry) { f(e.n) })
+}
@@ -0,0 +1,217 @@
+package workbook
+
+import (
+	"fmt"
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/ref"
+	"github.com/ajz01/calc/types"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// counted registers COUNTED(x), which returns x and counts its calls by
+// the cell that made them.
+func counted(w *Workbook) map[string]int {
+	calls := make(map[string]int)
+	w.Funcs.Register(&eval.Func{
+		Name: "COUNTED",
+		Sig:  types.Signature{Params: []types.Param{{Name: "x", Type: types.Any}}, Result: types.Any},
+		Call: func(e *eval.Evaluator, args []eval.Value) eval.Value {
+			calls[e.Cell.String()]++
+			return e.Deref(args[0])
+		},
+	})
+	return calls
+}
+
+func TestIncremental(t *testing.T) {
+	w := New()
+	calls := counted(w)
+	s := newSheet(t, w, "Sheet1", map[string]string{
+		"B1": "=COUNTED(SUM(A1:A100))",
+		"B2": "=COUNTED(B1*2)",
+		"B3": "=COUNTED(A200)",
+		"C1": "=COUNTED(B2+B1)",
+	})
+	for i := 1; i <= 100; i++ {
+		s.Set(ref.Cell{Row: i, Col: 1}, eval.Number(1))
+	}
+	w.Recalc()
+	want := map[string]int{"B1": 1, "B2": 1, "B3": 1, "C1": 1}
+	for addr, n := range want {
+		if calls[addr] != n {
+			t.Errorf("%s computed %d times want %d", addr, calls[addr], n)
+		}
+	}
+
+	s.Set(cell("A5"), eval.Number(11))
+	w.Recalc()
+	want = map[string]int{"B1": 2, "B2": 2, "B3": 1, "C1": 2}
+	for addr, n := range want {
+		if calls[addr] != n {
+			t.Errorf("after A5 edit %s computed %d times want %d", addr, calls[addr], n)
+		}
+	}
+	for addr, v := range map[string]eval.Value{"B1": eval.Number(110), "B2": eval.Number(220), "C1": eval.Number(330)} {
+		if got := s.Value(cell(addr)); got != v {
+			t.Errorf("%s = %v want %v", addr, got, v)
+		}
+	}
+
+	w.Recalc()
+	if calls["B1"] != 2 {
+		t.Errorf("Recalc without edits computed B1 again")
+	}
+	s.Set(cell("A101"), eval.Number(1))
+	w.Recalc()
+	if calls["B1"] != 2 {
+		t.Errorf("edit outside A1:A100 computed B1 again")
+	}
+}
+
+func TestGraph(t *testing.T) {
+	w := New()
+	s := newSheet(t, w, "Sheet1", map[string]string{
+		"B1": "=SUM(A1:A100)+A1",
+		"B2": "=B1",
+	})
+	g := w.graph
+	if n := len(g.precs[node{s: s, c: cell("B1")}]); n != 2 {
+		t.Errorf("B1 has %d edges want 2", n)
+	}
+	var deps []string
+	g.dependents(area{s: s, r: ref.Range{From: cell("A5"), To: cell("A5")}}, func(d node) {
+		deps = append(deps, d.c.String())
+	})
+	if len(deps) != 1 || deps[0] != "B1" {
+		t.Errorf("dependents(A5) = %v want [B1]", deps)
+	}
+	s.Set(cell("B1"), eval.Number(1))
+	if n := g.edges.len(); n != 1 {
+		t.Errorf("edges of B1 not removed: %d edges want 1", n)
+	}
+}
+
+func TestVolatile(t *testing.T) {
+	w := New()
+	calls := counted(w)
+	now := time.Date(2024, time.March, 15, 12, 0, 0, 0, time.UTC)
+	w.Clock = func() time.Time { return now }
+	rand := []float64{0.5, 0.25}
+	w.Rand = func() float64 {
+		r := rand[0]
+		rand = rand[1:]
+		return r
+	}
+	s := newSheet(t, w, "Sheet1", map[string]string{
+		"A1": "=RAND()",
+		"B1": "=COUNTED(A1*2)",
+		"C1": "=NOW()-INT(NOW())",
+		"D1": "=COUNTED(1)",
+	})
+	w.Recalc()
+	now = now.Add(6 * time.Hour)
+	w.Recalc()
+	for addr, want := range map[string]eval.Value{"A1": eval.Number(0.25), "B1": eval.Number(0.5), "C1": eval.Number(0.75)} {
+		if v := s.Value(cell(addr)); v != want {
+			t.Errorf("%s = %v want %v", addr, v, want)
+		}
+	}
+	if calls["B1"] != 2 || calls["D1"] != 1 {
+		t.Errorf("B1, D1 computed %d, %d times want 2, 1", calls["B1"], calls["D1"])
+	}
+
+	s.Set(cell("A1"), eval.Number(1))
+	w.Recalc()
+	if calls["B1"] != 3 {
+		t.Errorf("B1 computed %d times want 3", calls["B1"])
+	}
+	w.Recalc()
+	if calls["B1"] != 3 {
+		t.Errorf("B1 computed again after A1 stopped being volatile")
+	}
+}
+
+func TestIndex(t *testing.T) {
+	w := New()
+	s := newSheet(t, w, "Sheet1", nil)
+	s2 := newSheet(t, w, "Sheet2", nil)
+	rng := rand.New(rand.NewSource(1))
+	randRange := func() ref.Range {
+		var r ref.Range
+		switch rng.Intn(4) {
+		case 0: // a cell
+			r.From = ref.Cell{Row: 1 + rng.Intn(200), Col: 1 + rng.Intn(20)}
+			r.To = r.From
+		case 1: // a whole column
+			col := 1 + rng.Intn(20)
+			r = ref.Range{From: ref.Cell{Row: 1, Col: col}, To: ref.Cell{Row: ref.MaxRow, Col: col}}
+		default:
+			r.From = ref.Cell{Row: 1 + rng.Intn(200), Col: 1 + rng.Intn(20)}
+			r.To = ref.Cell{Row: r.From.Row + rng.Intn(100), Col: r.From.Col + rng.Intn(10)}
+		}
+		return r
+	}
+	x := newIndex()
+	var all []entry
+	for i := 0; i < 500; i++ {
+		e := entry{a: area{s: s, r: randRange()}, n: node{s: s, c: ref.Cell{Row: i + 1, Col: 30}}}
+		if i%5 == 0 {
+			e.a.s = s2
+		}
+		x.add(e.a, e.n)
+		all = append(all, e)
+	}
+	for i := 0; i < len(all); i += 3 {
+		x.remove(all[i].a, all[i].n)
+		all[i] = entry{}
+	}
+	for i := 0; i < 200; i++ {
+		a := area{s: s, r: randRange()}
+		want := make(map[entry]bool)
+		for _, e := range all {
+			if e.a.s == a.s && e.a.r.Overlaps(a.r) {
+				want[e] = true
+			}
+		}
+		got := make(map[entry]bool)
+		x.search(a, func(e entry) {
+			if got[e] {
+				t.Errorf("search(%v) found %v twice", a.r, e.a.r)
+			}
+			got[e] = true
+		})
+		if len(got) != len(want) {
+			t.Errorf("search(%v) found %d areas want %d", a.r, len(got), len(want))
+		}
+		for e := range got {
+			if !want[e] {
+				t.Errorf("search(%v) found %v", a.r, e.a.r)
+			}
+		}
+	}
+}
+
+// BenchmarkRanges computes formulas that each refer to a range of two
+// cells, after setting them and after editing the cells they refer to.
+func BenchmarkRanges(b *testing.B) {
+	for _, n := range []int{2000, 8000} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				w := New()
+				w.Workers = 1
+				s, _ := w.AddSheet("Sheet1")
+				for row := 1; row <= n; row++ {
+					s.Set(ref.Cell{Row: row, Col: 1}, eval.Number(row))
+					s.SetFormula(ref.Cell{Row: row, Col: 2}, fmt.Sprintf("=SUM(A%d:A%d)", row, row+1))
+				}
+				w.Recalc()
+				for row := 1; row <= n; row += 2 {
+					s.Set(ref.Cell{Row: row, Col: 1}, eval.Number(-row))
+				}
+				w.Recalc()
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package workbook
+
+import "github.com/ajz01/calc/ref"
+
+// The levels of an index. The blocks of level 0 are 8 by 8 cells, and
+// each level's blocks are twice as high and wide as the last's, so a
+// block of the last level covers a whole sheet.
+const (
+	blockBits = 3
+	levels    = 18
+)
+
+// entry is an area of an index and the formula it belongs to.
+type entry struct {
+	a area
+	n node
+}
+
+// block is a block of cells of a sheet at a level of an index.
+type block struct {
+	s        *Sheet
+	level    int
+	row, col int
+}
+
+// index is a spatial index of areas, such as the ranges formulas refer
+// to. An area is stored at the first level at which it overlaps no more
+// than two blocks each way, in the buckets of the blocks it overlaps. A
+// search visits at each level the buckets of the blocks the searched
+// area overlaps, so it only tests the areas near it: the cost of a
+// search grows with the number of levels and the areas it finds, not
+// with the number of areas indexed.
+type index struct {
+	buckets map[block]map[entry]int // entries by block, counted
+	levels  [levels]map[entry]int   // entries by level, counted
+}
+
+func newIndex() *index {
+	x := &index{buckets: make(map[block]map[entry]int)}
+	for i := range x.levels {
+		x.levels[i] = make(map[entry]int)
+	}
+	return x
+}
+
+// level returns the level at which range r is stored.
+func level(r ref.Range) int {
+	for l := 0; l < levels-1; l++ {
+		b := uint(l + blockBits)
+		if r.To.Row>>b-r.From.Row>>b <= 1 && r.To.Col>>b-r.From.Col>>b <= 1 {
+			return l
+		}
+	}
+	return levels - 1
+}
+
+// blocks calls f for the blocks of level l that range r on sheet s
+// overlaps.
+func blocks(s *Sheet, r ref.Range, l int, f func(b block)) {
+	b := uint(l + blockBits)
+	for row := r.From.Row >> b; row <= r.To.Row>>b; row++ {
+		for col := r.From.Col >> b; col <= r.To.Col>>b; col++ {
+			f(block{s: s, level: l, row: row, col: col})
+		}
+	}
+}
+
+// add adds area a of the formula in cell n.
+func (x *index) add(a area, n node) {
+	e := entry{a: a, n: n}
+	l := level(a.r)
+	x.levels[l][e]++
+	blocks(a.s, a.r, l, func(b block) {
+		if x.buckets[b] == nil {
+			x.buckets[b] = make(map[entry]int)
+		}
+		x.buckets[b][e]++
+	})
+}
+
+// remove removes area a of the formula in cell n, added by add.
+func (x *index) remove(a area, n node) {
+	e := entry{a: a, n: n}
+	l := level(a.r)
+	if x.levels[l][e]--; x.levels[l][e] <= 0 {
+		delete(x.levels[l], e)
+	}
+	blocks(a.s, a.r, l, func(b block) {
+		if x.buckets[b][e]--; x.buckets[b][e] <= 0 {
+			if delete(x.buckets[b], e); len(x.buckets[b]) == 0 {
+				delete(x.buckets, b)
+			}
+		}
+	})
+}
+
+// search calls f for the entries whose areas overlap a.
+func (x *index) search(a area, f func(e entry)) {
+	for l, entries := range x.levels {
+		if len(entries) == 0 {
+			continue
+		}
+		b := uint(l + blockBits)
+		rows := a.r.To.Row>>b - a.r.From.Row>>b + 1
+		cols := a.r.To.Col>>b - a.r.From.Col>>b + 1
+		if rows > len(entries) || rows*cols > len(entries) {
+			// fewer entries than blocks to visit
+			for e := range entries {
+				if e.a.s == a.s && e.a.r.Overlaps(a.r) {
+					f(e)
+				}
+			}
+			continue
+		}
+		blocks(a.s, a.r, l, func(blk block) {
+			for e := range x.buckets[blk] {
+				if !e.a.r.Overlaps(a.r) {
+					continue
+				}
+				// an entry in several of the blocks is found in the
+				// block holding the first cell of the overlap
+				if maxInt(e.a.r.From.Row, a.r.From.Row)>>b == blk.row && maxInt(e.a.r.From.Col, a.r.From.Col)>>b == blk.col {
+					f(e)
+				}
+			}
+		})
+	}
+}
+
+// len returns the number of entries, counting each once.
+func (x *index) len() int {
+	n := 0
+	for _, entries := range x.levels {
+		n += len(entries)
+	}
+	return n
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
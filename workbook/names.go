@@ -0,0 +1,328 @@
+package workbook
+
+import (
+	"github.com/ajz01/calc/ast"
+	"github.com/ajz01/calc/parser"
+	"github.com/ajz01/calc/translate"
+	"sort"
+	"strings"
+)
+
+// Name is a defined name: a name that formulas use in place of the
+// reference, constant or formula it is defined as. The definition is
+// evaluated where the name is used, so a name defined as a formula is
+// computed again by each formula that uses it.
+type Name struct {
+	Name string
+	// Formula is the definition without the leading '=', and Expr the
+	// parsed definition.
+	Formula string
+	Expr    ast.Expr
+
+	sheet *Sheet // nil for workbook names
+}
+
+// on returns the sheet that unqualified references in the definition of
+// d refer to when it is used by a formula on sheet s: the sheet d is
+// defined for, or s for workbook names.
+func (d *Name) on(s *Sheet) *Sheet {
+	if d.sheet != nil {
+		return d.sheet
+	}
+	return s
+}
+
+// Names is the table of names defined for the workbook or for one of its
+// sheets. Names are case-insensitive. In the formulas of a sheet, a name
+// defined for the sheet hides the workbook name of the same name.
+type Names struct {
+	wb    *Workbook
+	sheet *Sheet
+	m     map[string]*Name // by upper-case name
+}
+
+func newNames(w *Workbook, s *Sheet) *Names {
+	return &Names{wb: w, sheet: s, m: make(map[string]*Name)}
+}
+
+// Names returns the names defined for the workbook.
+func (w *Workbook) Names() *Names { return w.names }
+
+// Names returns the names defined for the sheet.
+func (s *Sheet) Names() *Names { return s.names }
+
+// Define parses src, with or without a leading '=', and defines name as
+// it, replacing any definition of name in t. A name must be usable in a
+// formula as is: it cannot be a number, logical value or reference in
+// either A1 or R1C1 notation. The formulas that use name are computed
+// again by the next Recalc.
+func (t *Names) Define(name, src string) error {
+	if !validName(name) {
+		return ErrName
+	}
+	src = strings.TrimPrefix(src, "=")
+	x, err := parser.ParseBytes([]byte(src))
+	if err != nil {
+		return err
+	}
+	if d := t.m[strings.ToUpper(name)]; d != nil {
+		d.Formula, d.Expr = src, x
+	} else {
+		t.m[strings.ToUpper(name)] = &Name{Name: name, Formula: src, Expr: x, sheet: t.sheet}
+	}
+	t.wb.refresh(name)
+	return nil
+}
+
+// Lookup returns the definition of name in t, or nil if there is none.
+func (t *Names) Lookup(name string) *Name {
+	return t.m[strings.ToUpper(name)]
+}
+
+// List returns the names defined in t, sorted ignoring case.
+func (t *Names) List() []*Name {
+	names := make([]*Name, 0, len(t.m))
+	for _, d := range t.m {
+		names = append(names, d)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return strings.ToUpper(names[i].Name) < strings.ToUpper(names[j].Name)
+	})
+	return names
+}
+
+// Delete removes the definition of name from t. Formulas that use it
+// are #NAME? after the next Recalc, unless it is also defined for the
+// workbook.
+func (t *Names) Delete(name string) {
+	if t.m[strings.ToUpper(name)] == nil {
+		return
+	}
+	delete(t.m, strings.ToUpper(name))
+	t.wb.refresh(name)
+}
+
+// Rename renames the name old in t to new and rewrites the formulas and
+// definitions that use it to use new. Uses of old that refer to another
+// definition, such as a name of the same name defined for a sheet, are
+// kept. So are uses in formulas that bind old with LET or LAMBDA, and
+// calls of a function called old. Renaming a workbook name to a name
+// defined for a sheet, or a sheet name to a workbook name, would change
+// what other formulas refer to and fails with ErrNameUsed. If a renamed
+// formula does not parse, the error is returned and nothing is renamed.
+func (t *Names) Rename(old, new string) error {
+	d := t.Lookup(old)
+	if d == nil {
+		return ErrNoName
+	}
+	if !validName(new) {
+		return ErrName
+	}
+	w := t.wb
+	for _, u := range w.definitions() {
+		if u != d && strings.EqualFold(u.Name, new) && (u.sheet == t.sheet || u.sheet == nil || t.sheet == nil) {
+			return ErrNameUsed
+		}
+	}
+	formulas := make(map[*Cell]formula)
+	for _, s := range w.sheets {
+		for _, cell := range s.cells {
+			if cell.Expr == nil {
+				continue
+			}
+			f, ok, err := w.rename(s, cell.Formula, cell.Expr, d, new)
+			if err != nil {
+				return err
+			}
+			if ok {
+				formulas[cell] = f
+			}
+		}
+	}
+	defs := make(map[*Name]formula)
+	for _, u := range w.definitions() {
+		f, ok, err := w.rename(u.sheet, u.Formula, u.Expr, d, new)
+		if err != nil {
+			return err
+		}
+		if ok {
+			defs[u] = f
+		}
+	}
+	for cell, f := range formulas {
+		cell.Formula, cell.Expr = f.src, f.x
+	}
+	for u, f := range defs {
+		u.Formula, u.Expr = f.src, f.x
+	}
+	delete(t.m, strings.ToUpper(d.Name))
+	d.Name = new
+	t.m[strings.ToUpper(new)] = d
+	w.refresh(old, new)
+	return nil
+}
+
+// rename returns the formula src, x on sheet s with the uses of d
+// renamed to name, and whether any were. Workbook names are looked up
+// among the workbook names only when s is nil.
+func (w *Workbook) rename(s *Sheet, src string, x ast.Expr, d *Name, name string) (formula, bool, error) {
+	skip := w.unnamed(x)
+	src2 := translate.RewriteNames(src, x, func(id *ast.Ident) string {
+		if !skip[id] && w.resolve(s, id.Name) == d {
+			return name
+		}
+		return id.Name
+	})
+	if src2 == src {
+		return formula{}, false, nil
+	}
+	y, err := reparse(src2)
+	if err != nil {
+		return formula{}, false, err
+	}
+	return formula{src2, y}, true, nil
+}
+
+// unnamed returns the identifiers of formula x that do not refer to
+// defined names: the names of the functions it calls, and the names it
+// binds with LET or LAMBDA anywhere in the formula.
+func (w *Workbook) unnamed(x ast.Expr) map[*ast.Ident]bool {
+	bound := make(map[string]bool)
+	ast.Inspect(x, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		id, ok := call.Fun.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		var params []ast.Expr
+		switch strings.ToUpper(id.Name) {
+		case "LET":
+			for i := 0; i+1 < len(call.Args); i += 2 {
+				params = append(params, call.Args[i])
+			}
+		case "LAMBDA":
+			if len(call.Args) > 0 {
+				params = call.Args[:len(call.Args)-1]
+			}
+		}
+		for _, p := range params {
+			if p, ok := p.(*ast.Ident); ok {
+				bound[strings.ToUpper(p.Name)] = true
+			}
+		}
+		return true
+	})
+	skip := make(map[*ast.Ident]bool)
+	ast.Inspect(x, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Ident:
+			skip[n] = skip[n] || bound[strings.ToUpper(n.Name)]
+		case *ast.CallExpr:
+			if id, ok := n.Fun.(*ast.Ident); ok {
+				if _, ok := w.Funcs.Func(id.Name); ok {
+					skip[id] = true
+				}
+			}
+		}
+		return true
+	})
+	return skip
+}
+
+// validName reports whether name is an identifier in both A1 and R1C1
+// notation.
+func validName(name string) bool {
+	for _, mode := range []parser.Mode{0, parser.R1C1} {
+		x, err := parser.ParseMode([]byte(name), mode)
+		if id, ok := x.(*ast.Ident); err != nil || !ok || id.Name != name {
+			return false
+		}
+	}
+	return true
+}
+
+// resolve returns the definition that name refers to in the formulas of
+// sheet s: the name defined for s, else the workbook name. If s is nil
+// only the workbook names are searched.
+func (w *Workbook) resolve(s *Sheet, name string) *Name {
+	if s != nil {
+		if d := s.names.Lookup(name); d != nil {
+			return d
+		}
+	}
+	return w.names.Lookup(name)
+}
+
+// definitions returns the names defined for the workbook and its sheets.
+func (w *Workbook) definitions() []*Name {
+	names := w.names.List()
+	for _, s := range w.sheets {
+		names = append(names, s.names.List()...)
+	}
+	return names
+}
+
+// Name returns the definition of name for a formula on the named sheet
+// and the sheet its unqualified references refer to. It implements
+// eval.Namer.
+func (w *Workbook) Name(sheet, name string) (ast.Expr, string, bool) {
+	d := w.resolve(w.Sheet(sheet), name)
+	if d == nil {
+		return nil, "", false
+	}
+	if d.sheet != nil {
+		sheet = d.sheet.name
+	}
+	return d.Expr, sheet, true
+}
+
+// refresh updates the precedents of the formulas that use any of names,
+// directly or through the definitions of other names, and marks them
+// dirty.
+func (w *Workbook) refresh(names ...string) {
+	for _, s := range w.sheets {
+		for _, c := range s.Cells() {
+			cell := s.cells[c]
+			if cell.Expr != nil && s.uses(cell.Expr, names) {
+				s.edit(c, cell)
+			}
+		}
+	}
+}
+
+// uses reports whether the formula x on sheet s uses any of names.
+func (s *Sheet) uses(x ast.Expr, names []string) bool {
+	found := false
+	s.inspect(x, func(_ *Sheet, n ast.Node) {
+		if id, ok := n.(*ast.Ident); ok {
+			for _, name := range names {
+				found = found || strings.EqualFold(id.Name, name)
+			}
+		}
+	})
+	return found
+}
+
+// inspect calls f for the nodes of the formula x on sheet s and of the
+// definitions of the names it uses, each definition once. f is passed
+// the sheet that unqualified references in the node refer to.
+func (s *Sheet) inspect(x ast.Expr, f func(s *Sheet, n ast.Node)) {
+	seen := make(map[*Name]bool)
+	var visit func(s *Sheet, x ast.Expr)
+	visit = func(s *Sheet, x ast.Expr) {
+		ast.Inspect(x, func(n ast.Node) bool {
+			f(s, n)
+			if id, ok := n.(*ast.Ident); ok {
+				if d := s.wb.resolve(s, id.Name); d != nil && !seen[d] {
+					seen[d] = true
+					visit(d.on(s), d.Expr)
+				}
+			}
+			return true
+		})
+	}
+	visit(s, x)
+}
@@ -0,0 +1,267 @@
+package workbook
+
+import (
+	"github.com/ajz01/calc/eval"
+	"testing"
+)
+
+func TestNames(t *testing.T) {
+	w := New()
+	s := newSheet(t, w, "Sheet1", map[string]string{
+		"A1": "Price*(1+Rate)",
+		"A2": "SUM(Prices)",
+		"A3": "Total/2",
+		"A4": "Unknown",
+		"B1": "10",
+		"B2": "20",
+	})
+	t2 := newSheet(t, w, "Sheet2", map[string]string{
+		"A1": "Rate",
+		"A2": "Price",
+	})
+	for name, src := range map[string]string{
+		"Price":  "Sheet1!$B$1",
+		"Prices": "=Sheet1!B1:B2",
+		"Rate":   "0.5",
+		"Total":  "SUM(Prices)+Price",
+	} {
+		if err := w.Names().Define(name, src); err != nil {
+			t.Fatalf("Define(%s, %q) %v", name, src, err)
+		}
+	}
+	if err := t2.Names().Define("rate", "0.25"); err != nil {
+		t.Fatalf("Sheet2 Define(rate) %v", err)
+	}
+	w.Recalc()
+	want := map[string]eval.Value{
+		"Sheet1!A1": eval.Number(15),
+		"Sheet1!A2": eval.Number(30),
+		"Sheet1!A3": eval.Number(20),
+		"Sheet1!A4": eval.ErrName,
+		"Sheet2!A1": eval.Number(0.25),
+		"Sheet2!A2": eval.Number(10),
+	}
+	check := func(when string) {
+		t.Helper()
+		for addr, v := range want {
+			if got := w.Sheet(addr[:6]).Value(cell(addr[7:])); got != v {
+				t.Errorf("%s %s = %v want %v", when, addr, got, v)
+			}
+		}
+	}
+	check("after Define")
+
+	s.Set(cell("B1"), eval.Number(2))
+	w.Recalc()
+	want["Sheet1!A1"], want["Sheet1!A2"], want["Sheet1!A3"], want["Sheet2!A2"] = eval.Number(3), eval.Number(22), eval.Number(12), eval.Number(2)
+	check("after edit")
+
+	if err := w.Names().Define("Unknown", "Rate*2"); err != nil {
+		t.Fatal(err)
+	}
+	w.Names().Define("Rate", "1")
+	w.Recalc()
+	want["Sheet1!A1"], want["Sheet1!A4"] = eval.Number(4), eval.Number(2)
+	check("after redefining")
+
+	w.Names().Delete("Rate")
+	t2.Names().Delete("Rate")
+	w.Recalc()
+	want["Sheet1!A1"], want["Sheet1!A4"], want["Sheet2!A1"] = eval.ErrName, eval.ErrName, eval.ErrName
+	check("after Delete")
+
+	for _, name := range []string{"", "A1", "R1C1", "rc", "TRUE", "1x", "a b", "x+1"} {
+		if err := w.Names().Define(name, "1"); err != ErrName {
+			t.Errorf("Define(%q) = %v want %v", name, err, ErrName)
+		}
+	}
+	if err := w.Names().Define("x", "1+"); err == nil {
+		t.Errorf("Define(x, 1+) succeeded want error")
+	}
+	var got []string
+	for _, d := range w.Names().List() {
+		got = append(got, d.Name+"="+d.Formula)
+	}
+	if len(got) != 4 || got[0] != "Price=Sheet1!$B$1" || got[1] != "Prices=Sheet1!B1:B2" || got[3] != "Unknown=Rate*2" {
+		t.Errorf("List = %v", got)
+	}
+}
+
+func TestNameFormulas(t *testing.T) {
+	w := New()
+	s := newSheet(t, w, "Sheet1", map[string]string{
+		"A1": "Fact(5)",
+		"A2": "Self",
+		"A3": "Twice(3)",
+		"A4": "Here",
+	})
+	for name, src := range map[string]string{
+		"Fact":  "LAMBDA(n, IF(n<=1, 1, n*Fact(n-1)))",
+		"Self":  "Self+1",
+		"Twice": "LAMBDA(x, x*2)",
+		"Here":  "B2",
+		"Loop":  "LAMBDA(n, Loop(n+1))",
+	} {
+		if err := w.Names().Define(name, src); err != nil {
+			t.Fatalf("Define(%s, %q) %v", name, src, err)
+		}
+	}
+	s.Set(cell("B2"), eval.Number(7))
+	w.Recalc()
+	for addr, want := range map[string]eval.Value{
+		"A1": eval.Number(120),
+		"A2": eval.ErrCalc,
+		"A3": eval.Number(6),
+		"A4": eval.Number(7),
+	} {
+		if got := s.Value(cell(addr)); got != want {
+			t.Errorf("%s = %v want %v", addr, got, want)
+		}
+	}
+	s.SetFormula(cell("A5"), "Loop(1)")
+	w.Recalc()
+	if got := s.Value(cell("A5")); got != eval.ErrNum {
+		t.Errorf("Loop(1) = %v want %v", got, eval.ErrNum)
+	}
+
+	w.Names().Define("Random", "RAND()")
+	s.SetFormula(cell("C1"), "Random")
+	w.Recalc()
+	first := s.Value(cell("C1"))
+	w.Recalc()
+	if s.Value(cell("C1")) == first {
+		t.Errorf("formula using a volatile name was not computed again")
+	}
+}
+
+func TestRenameName(t *testing.T) {
+	w := New()
+	s := newSheet(t, w, "Sheet1", map[string]string{
+		"A1": "rate*2",
+		"A2": "LET(Rate, 3, Rate)+Rate",
+		"A3": "Scaled",
+		"B1": "10",
+	})
+	t2 := newSheet(t, w, "Sheet2", map[string]string{
+		"A1": "Rate+1",
+	})
+	w.Names().Define("Rate", "Sheet1!B1")
+	w.Names().Define("Scaled", "RATE*3")
+	t2.Names().Define("Rate", "100")
+	w.Recalc()
+
+	if err := w.Names().Rename("Rate", "Tax"); err != nil {
+		t.Fatalf("Rename %v", err)
+	}
+	for _, test := range []struct {
+		s       *Sheet
+		addr    string
+		formula string
+	}{
+		{s, "A1", "Tax*2"},
+		{s, "A2", "LET(Rate, 3, Rate)+Rate"},
+		{s, "A3", "Scaled"},
+		{t2, "A1", "Rate+1"},
+	} {
+		if got := test.s.Cell(cell(test.addr)).Formula; got != test.formula {
+			t.Errorf("%s!%s = %q want %q", test.s.Name(), test.addr, got, test.formula)
+		}
+	}
+	if d := w.Names().Lookup("Scaled"); d.Formula != "Tax*3" {
+		t.Errorf("Scaled = %q want Tax*3", d.Formula)
+	}
+	if w.Names().Lookup("Rate") != nil || w.Names().Lookup("tax") == nil {
+		t.Errorf("Lookup after Rename: Rate %v, Tax %v", w.Names().Lookup("Rate"), w.Names().Lookup("tax"))
+	}
+
+	s.Set(cell("B1"), eval.Number(5))
+	w.Recalc()
+	for _, test := range []struct {
+		s    *Sheet
+		addr string
+		want eval.Value
+	}{
+		{s, "A1", eval.Number(10)},
+		{s, "A2", eval.ErrName},
+		{s, "A3", eval.Number(15)},
+		{t2, "A1", eval.Number(101)},
+	} {
+		if got := test.s.Value(cell(test.addr)); got != test.want {
+			t.Errorf("%s!%s = %v want %v", test.s.Name(), test.addr, got, test.want)
+		}
+	}
+
+	if err := w.Names().Rename("Rate", "x"); err != ErrNoName {
+		t.Errorf("Rename(Rate) = %v want %v", err, ErrNoName)
+	}
+	if err := w.Names().Rename("Tax", "Scaled"); err != ErrNameUsed {
+		t.Errorf("Rename(Tax, Scaled) = %v want %v", err, ErrNameUsed)
+	}
+	if err := w.Names().Rename("Tax", "Rate"); err != ErrNameUsed {
+		t.Errorf("Rename(Tax, Rate) = %v want %v", err, ErrNameUsed)
+	}
+	if err := w.Names().Rename("Tax", "B2"); err != ErrName {
+		t.Errorf("Rename(Tax, B2) = %v want %v", err, ErrName)
+	}
+	if err := w.Names().Rename("tax", "TAX"); err != nil {
+		t.Errorf("Rename(tax, TAX) %v", err)
+	}
+	if got := s.Cell(cell("A1")).Formula; got != "TAX*2" {
+		t.Errorf("A1 = %q want TAX*2", got)
+	}
+}
+
+func TestInsertName(t *testing.T) {
+	w := New()
+	s := newSheet(t, w, "Sheet1", map[string]string{
+		"A1": "Data+Local",
+		"B3": "1",
+		"B4": "2",
+	})
+	w.Names().Define("Data", "SUM(Sheet1!$B$3:$B$4)")
+	s.Names().Define("Local", "B4")
+	w.Names().Define("Relative", "B4")
+	w.Recalc()
+	if err := s.InsertRows(2, 1); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"Data": "SUM(Sheet1!$B$4:$B$5)", "Local": "B5", "Relative": "B4"} {
+		d := w.Names().Lookup(name)
+		if d == nil {
+			d = s.Names().Lookup(name)
+		}
+		if d.Formula != want {
+			t.Errorf("%s after InsertRows = %q want %q", name, d.Formula, want)
+		}
+	}
+	s.Set(cell("B5"), eval.Number(10))
+	w.Recalc()
+	if got := s.Value(cell("A1")); got != eval.Number(21) {
+		t.Errorf("A1 = %v want 21", got)
+	}
+}
+
+func TestRenameError(t *testing.T) {
+	w := New()
+	s := newSheet(t, w, "Sheet1", map[string]string{
+		"A1": "Rate*2",
+		"A2": "Rate",
+	})
+	w.Names().Define("Rate", "0.5")
+	w.Names().Define("Scaled", "Rate*3")
+	// a formula whose text no longer matches its parse, so that the
+	// renamed text does not parse
+	s.cells[cell("A2")].Formula = "Rate+("
+	if err := w.Names().Rename("Rate", "Tax"); err == nil {
+		t.Fatal("Rename succeeded want error")
+	}
+	if w.Names().Lookup("Rate") == nil || w.Names().Lookup("Tax") != nil {
+		t.Errorf("Lookup after failed Rename: Rate %v, Tax %v", w.Names().Lookup("Rate"), w.Names().Lookup("Tax"))
+	}
+	if got := s.Cell(cell("A1")).Formula; got != "Rate*2" {
+		t.Errorf("A1 = %q want Rate*2", got)
+	}
+	if d := w.Names().Lookup("Scaled"); d.Formula != "Rate*3" {
+		t.Errorf("Scaled = %q want Rate*3", d.Formula)
+	}
+}
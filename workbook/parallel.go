@@ -0,0 +1,119 @@
+package workbook
+
+import (
+	"github.com/ajz01/calc/ast"
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/ref"
+	"runtime"
+	"sync"
+)
+
+// minParallel is the fewest formulas of a level worth spreading over
+// goroutines.
+const minParallel = 64
+
+// levels groups the components found by order into levels: each
+// component comes one level after the last of the components it depends
+// on, so the components of a level are independent of each other.
+func (w *Workbook) levels(order [][]node) [][][]node {
+	level := make(map[node]int)
+	var levels [][][]node
+	for _, comp := range order {
+		l := 0
+		for _, n := range comp {
+			if level[n] > l {
+				l = level[n]
+			}
+		}
+		if l == len(levels) {
+			levels = append(levels, nil)
+		}
+		levels[l] = append(levels[l], comp)
+		for _, n := range comp {
+			w.dependents(n, func(d node) {
+				if w.dirty[d] && level[d] < l+1 {
+					level[d] = l + 1
+				}
+			})
+		}
+	}
+	return levels
+}
+
+// run computes the components of a level. Formulas that are not part
+// of a cycle and call no Serial function are evaluated concurrently
+// against a snapshot, which reads but never computes cells: the cells
+// they depend on were computed in earlier levels. Their results are then
+// laid out in order, and the other components computed in turn, so the
+// results do not depend on how the work was scheduled.
+func (w *Workbook) run(level [][]node) {
+	workers := w.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	var par []node
+	var rest [][]node
+	for _, comp := range level {
+		n := comp[0]
+		cell := n.s.cells[n.c]
+		if workers > 1 && len(comp) == 1 && cell != nil && cell.Expr != nil && cell.dirty && !n.s.calls(cell.Expr, func(f *eval.Func) bool { return f.Serial }) && !w.cyclic(comp) {
+			par = append(par, n)
+		} else {
+			rest = append(rest, comp)
+		}
+	}
+	if len(par) < minParallel {
+		for _, n := range par {
+			rest = append(rest, []node{n})
+		}
+		par = nil
+	}
+
+	results := make([]eval.Value, len(par))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(par); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range next {
+				n := par[j]
+				results[j] = n.s.eval(snapshot{w}, n.c, n.s.cells[n.c])
+			}
+		}()
+	}
+	for j := range par {
+		next <- j
+	}
+	close(next)
+	wg.Wait()
+
+	for j, n := range par {
+		n.s.layout(n.c, n.s.cells[n.c], results[j])
+	}
+	for _, comp := range rest {
+		w.calcComp(comp)
+	}
+}
+
+// snapshot is the context formulas are evaluated against concurrently.
+// It returns the cells as they are, without computing dirty formulas.
+type snapshot struct {
+	w *Workbook
+}
+
+func (s snapshot) Value(sheet string, c ref.Cell) eval.Value {
+	sh := s.w.Sheet(sheet)
+	if sh == nil {
+		return eval.ErrRef
+	}
+	return sh.value(c, false)
+}
+
+func (s snapshot) SpillRange(sheet string, c ref.Cell) (ref.Range, bool) {
+	return s.w.spillRange(sheet, c, false)
+}
+
+func (s snapshot) Name(sheet, name string) (ast.Expr, string, bool) {
+	return s.w.Name(sheet, name)
+}
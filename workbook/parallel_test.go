@@ -0,0 +1,74 @@
+package workbook
+
+import (
+	"fmt"
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/ref"
+	"testing"
+)
+
+// chains fills a sheet with cols independent columns of rows formulas,
+// each computed from the one above and the one above to the left.
+func chains(t testing.TB, w *Workbook, rows, cols int) *Sheet {
+	s, err := w.AddSheet("Sheet1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for col := 1; col <= cols; col++ {
+		s.Set(ref.Cell{Row: 1, Col: col}, eval.Number(col))
+		for row := 2; row <= rows; row++ {
+			up := ref.Cell{Row: row - 1, Col: col}
+			left := ref.Cell{Row: row - 1, Col: col%cols + 1}
+			src := fmt.Sprintf("=SQRT(%[1]s*%[1]s+1)+LN(ABS(%[2]s)+1)+SIN(%[1]s)*COS(%[2]s)", up, left)
+			if err := s.SetFormula(ref.Cell{Row: row, Col: col}, src); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return s
+}
+
+func TestParallel(t *testing.T) {
+	serial, parallel := New(), New()
+	serial.Workers, parallel.Workers = 1, 4
+	a, b := chains(t, serial, 20, 100), chains(t, parallel, 20, 100)
+	// a spill, a cycle and a serial function among the chains
+	for _, s := range []*Sheet{a, b} {
+		s.SetFormula(cell("A30"), "=SEQUENCE(3)")
+		s.SetFormula(cell("B30"), "=SUM(A30#)+B31")
+		s.SetFormula(cell("B31"), "=B30")
+		s.SetFormula(cell("C30"), "=SUM(OFFSET(A1,0,0,20,1))")
+	}
+	serial.Recalc()
+	parallel.Recalc()
+	a.Set(cell("B1"), eval.Number(-3))
+	b.Set(cell("B1"), eval.Number(-3))
+	serial.Recalc()
+	parallel.Recalc()
+	for _, c := range a.Cells() {
+		if x, y := a.Value(c), b.Value(c); x != y {
+			t.Errorf("%s = %v with 4 workers want %v", c, y, x)
+		}
+	}
+}
+
+func BenchmarkRecalc(b *testing.B) {
+	for _, workers := range []int{1, 0} {
+		name := "serial"
+		if workers != 1 {
+			name = "parallel"
+		}
+		b.Run(name, func(b *testing.B) {
+			w := New()
+			w.Workers = workers
+			s := chains(b, w, 100, 500)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for col := 1; col <= 500; col++ {
+					s.Set(ref.Cell{Row: 1, Col: col}, eval.Number(i+col))
+				}
+				w.Recalc()
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package workbook
+
+import (
+	"github.com/ajz01/calc/ast"
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/parser"
+	"github.com/ajz01/calc/ref"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Cell is the content of a non-empty cell: a constant or a formula.
+type Cell struct {
+	// Value is the constant, or the result of the formula as of the
+	// last Recalc. An array result is the whole array.
+	Value eval.Value
+	// Formula is the formula source without the leading '=', and Expr
+	// the parsed formula. Both are empty for constants.
+	Formula string
+	Expr    ast.Expr
+
+	dirty   bool      // Value is out of date
+	busy    bool      // being evaluated
+	area    ref.Range // cells the array result covers, starting here
+	spilled bool      // the array result spills into area
+}
+
+// Sheet is a grid of cells. Only cells with content are stored, so a
+// sheet holding a few values far apart costs no more than a dense one
+// holding as many.
+type Sheet struct {
+	wb      *Workbook
+	name    string
+	cells   map[ref.Cell]*Cell
+	anchors map[ref.Cell]ref.Range // formulas with array results, by area
+	arrays  *index                 // the areas of the anchors
+	names   *Names
+}
+
+// Name returns the name of the sheet.
+func (s *Sheet) Name() string { return s.name }
+
+// Cell returns the content of cell c, or nil if c is empty.
+func (s *Sheet) Cell(c ref.Cell) *Cell { return s.cells[c] }
+
+// Cells returns the addresses of the non-empty cells in row-major order.
+func (s *Sheet) Cells() []ref.Cell {
+	cells := make([]ref.Cell, 0, len(s.cells))
+	for c := range s.cells {
+		cells = append(cells, c)
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		a, b := cells[i], cells[j]
+		return a.Row < b.Row || a.Row == b.Row && a.Col < b.Col
+	})
+	return cells
+}
+
+// Set stores the constant v in cell c. Storing nil or Blank clears c.
+func (s *Sheet) Set(c ref.Cell, v eval.Value) {
+	if _, ok := v.(eval.Blank); ok || v == nil {
+		s.Clear(c)
+		return
+	}
+	s.edit(c, &Cell{Value: v})
+}
+
+// SetFormula parses src, with or without a leading '=', and stores it as
+// the formula of cell c. The result is computed by the next Recalc.
+func (s *Sheet) SetFormula(c ref.Cell, src string) error {
+	src = strings.TrimPrefix(src, "=")
+	x, err := parser.ParseBytes([]byte(src))
+	if err != nil {
+		return err
+	}
+	s.edit(c, &Cell{Value: eval.Blank{}, Formula: src, Expr: x})
+	return nil
+}
+
+// Clear empties cell c.
+func (s *Sheet) Clear(c ref.Cell) {
+	s.edit(c, nil)
+}
+
+// edit replaces the content of cell c, updates the dependency graph and
+// marks dirty the formulas affected: the new formula, the formulas that
+// depend on c or on the array the old formula spilled, and the arrays
+// that would spill over c.
+func (s *Sheet) edit(c ref.Cell, cell *Cell) {
+	w := s.wb
+	n := node{s: s, c: c}
+	if old := s.cells[c]; old != nil && old.spilled {
+		w.changed(area{s: s, r: old.area})
+	}
+	s.unanchor(c)
+	delete(w.dirty, n)
+	w.graph.remove(n)
+	if cell == nil {
+		delete(s.cells, c)
+	} else {
+		s.cells[c] = cell
+	}
+	delete(w.volatile, n)
+	if cell != nil && cell.Expr != nil {
+		w.graph.set(n, precedents(s, cell.Expr))
+		w.mark(n, cell)
+		if s.calls(cell.Expr, func(f *eval.Func) bool { return f.Volatile }) {
+			w.volatile[n] = true
+		}
+	}
+	s.arrays.search(area{s: s, r: ref.Range{From: c, To: c}}, func(e entry) {
+		if a := s.cells[e.n.c]; !a.dirty {
+			for _, x := range w.mark(e.n, a) {
+				w.changed(x)
+			}
+		}
+	})
+	w.changed(area{s: s, r: ref.Range{From: c, To: c}})
+}
+
+// Value returns the value of cell c: the constant, the result of the
+// formula, or the element of a spilled array covering c. Empty cells
+// are Blank.
+func (s *Sheet) Value(c ref.Cell) eval.Value {
+	return s.value(c, s.wb.calc)
+}
+
+// value returns the value of cell c, first computing the formulas it
+// comes from if they are dirty and calc is set.
+func (s *Sheet) value(c ref.Cell, calc bool) eval.Value {
+	if cell := s.cells[c]; cell != nil {
+		if cell.Expr == nil {
+			return cell.Value
+		}
+		if calc {
+			s.calc(c, cell)
+		}
+		if a, ok := cell.Value.(*eval.Array); ok {
+			if !cell.spilled {
+				return eval.ErrSpill
+			}
+			return a.At(0, 0)
+		}
+		return cell.Value
+	}
+	var anchors []ref.Cell
+	s.arrays.search(area{s: s, r: ref.Range{From: c, To: c}}, func(e entry) {
+		anchors = append(anchors, e.n.c)
+	})
+	for _, at := range anchors {
+		cell := s.cells[at]
+		if calc {
+			s.calc(at, cell)
+		}
+		if a, ok := cell.Value.(*eval.Array); ok && cell.spilled && cell.area.Contains(c) {
+			return a.At(c.Row-at.Row, c.Col-at.Col)
+		}
+	}
+	return eval.Blank{}
+}
+
+// calc computes the formula in cell c if it is dirty. A formula that
+// needs its own result while being computed is a circular reference.
+func (s *Sheet) calc(c ref.Cell, cell *Cell) {
+	if cell.busy {
+		s.wb.loopAt(node{s: s, c: c})
+		return
+	}
+	if cell.dirty {
+		s.compute(c, cell)
+	}
+}
+
+// compute evaluates the formula in cell c and lays out the result.
+func (s *Sheet) compute(c ref.Cell, cell *Cell) {
+	w := s.wb
+	n := node{s: s, c: c}
+	cell.busy = true
+	w.stack = append(w.stack, n)
+	v := s.eval(w, c, cell)
+	w.stack = w.stack[:len(w.stack)-1]
+	cell.busy = false
+	if w.looped[n] {
+		v = eval.ErrCalc
+	}
+	s.layout(c, cell, v)
+}
+
+// layout stores v as the result of the formula in cell c. If the range
+// an array result spills into changes, the formulas that depend on the
+// cells it gained or lost are marked dirty.
+func (s *Sheet) layout(c ref.Cell, cell *Cell, v eval.Value) {
+	cell.Value, cell.dirty = v, false
+	old, spilled := cell.area, cell.spilled
+	cell.area, cell.spilled = ref.Range{From: c, To: c}, false
+	s.unanchor(c)
+	if a, ok := v.(*eval.Array); ok {
+		r, first := eval.Spill(c, v, func(x ref.Cell) bool { return s.cells[x] != nil })
+		cell.spilled = first != eval.ErrSpill
+		cell.area = r
+		if to := (ref.Cell{Row: c.Row + a.Rows - 1, Col: c.Col + a.Cols - 1}); to.IsValid() {
+			// a blocked array spills again once its range is cleared
+			cell.area.To = to
+		}
+		s.anchors[c] = cell.area
+		s.arrays.add(area{s: s, r: cell.area}, node{s: s, c: c})
+	}
+	if spilled && (!cell.spilled || old != cell.area) {
+		s.wb.changed(area{s: s, r: old})
+	}
+	if cell.spilled && (!spilled || old != cell.area) {
+		s.wb.changed(area{s: s, r: cell.area})
+	}
+}
+
+// unanchor removes the array result of the formula in cell c, if any,
+// from the anchors of s.
+func (s *Sheet) unanchor(c ref.Cell) {
+	if r, ok := s.anchors[c]; ok {
+		s.arrays.remove(area{s: s, r: r}, node{s: s, c: c})
+		delete(s.anchors, c)
+	}
+}
+
+// eval evaluates the formula in cell c against ctx.
+func (s *Sheet) eval(ctx eval.Context, c ref.Cell, cell *Cell) eval.Value {
+	w := s.wb
+	e := &eval.Evaluator{
+		Context:  ctx,
+		Funcs:    w.Funcs,
+		Sheet:    s.name,
+		Cell:     c,
+		Date1904: w.Date1904,
+		Clock:    func() time.Time { return w.now },
+		Rand:     w.Rand,
+	}
+	return e.Eval(cell.Expr)
+}
@@ -0,0 +1,322 @@
+// Package workbook is an in-memory spreadsheet model: a workbook of
+// named sheets whose cells hold constants or formulas. A Workbook is the
+// eval.Context its formulas are evaluated against.
+package workbook
+
+import (
+	"errors"
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/funcs"
+	"github.com/ajz01/calc/ref"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSheetName = errors.New("workbook: invalid sheet name")
+	ErrSheetUsed = errors.New("workbook: duplicate sheet name")
+	ErrShift     = errors.New("workbook: rows or columns out of range")
+	ErrName      = errors.New("workbook: invalid name")
+	ErrNameUsed  = errors.New("workbook: duplicate name")
+	ErrNoName    = errors.New("workbook: undefined name")
+)
+
+// Workbook is a list of sheets. Edits mark the formulas that depend on
+// the edited cells dirty; Recalc computes them again.
+type Workbook struct {
+	// Funcs holds the functions formulas may call.
+	Funcs *eval.Registry
+	// Date1904 selects the 1904 date system for serial dates.
+	Date1904 bool
+	// Iterate enables iterative calculation of circular references.
+	// Recalc computes the formulas of a cycle in turn, up to
+	// MaxIterations times or until no result changes by more than
+	// MaxChange. Without it the formulas of a cycle are #CALC!.
+	Iterate       bool
+	MaxIterations int
+	MaxChange     float64
+	// Clock returns the current time for functions such as NOW; if nil
+	// time.Now is used. Recalc reads it once, so all formulas see the
+	// same time.
+	Clock func() time.Time
+	// Rand returns random numbers in [0, 1) for functions such as RAND;
+	// if nil math/rand is used. A source with a fixed seed makes the
+	// results of Recalc reproducible.
+	Rand func() float64
+	// Workers limits the goroutines Recalc computes formulas on. Zero
+	// means runtime.GOMAXPROCS(0); one computes the formulas in turn.
+	Workers int
+
+	sheets   []*Sheet
+	names    *Names
+	graph    *graph
+	dirty    map[node]bool // formulas to compute
+	volatile map[node]bool // formulas that call Volatile functions
+	now      time.Time     // the time as of Recalc
+	calc     bool          // inside Recalc: dirty formulas are computed on first use
+	stack    []node        // formulas being computed
+	cycles   [][]node      // circular references found by Recalc
+	looped   map[node]bool // formulas of the cycles
+}
+
+// New returns an empty workbook with the built-in functions.
+func New() *Workbook {
+	w := &Workbook{
+		Funcs:         funcs.Default(),
+		MaxIterations: 100,
+		MaxChange:     0.001,
+		graph:         newGraph(),
+		dirty:         make(map[node]bool),
+		volatile:      make(map[node]bool),
+	}
+	w.names = newNames(w, nil)
+	return w
+}
+
+// AddSheet appends a new empty sheet. Sheet names are case-insensitive
+// and must be unique.
+func (w *Workbook) AddSheet(name string) (*Sheet, error) {
+	if name == "" || strings.ContainsAny(name, "!'[]:*?/\\") {
+		return nil, ErrSheetName
+	}
+	if w.Sheet(name) != nil {
+		return nil, ErrSheetUsed
+	}
+	s := &Sheet{wb: w, name: name, cells: make(map[ref.Cell]*Cell), anchors: make(map[ref.Cell]ref.Range), arrays: newIndex()}
+	s.names = newNames(w, s)
+	w.sheets = append(w.sheets, s)
+	return s, nil
+}
+
+// Sheet returns the sheet called name, or nil if there is none.
+func (w *Workbook) Sheet(name string) *Sheet {
+	for _, s := range w.sheets {
+		if strings.EqualFold(s.name, name) {
+			return s
+		}
+	}
+	return nil
+}
+
+// Sheets returns the sheets in order.
+func (w *Workbook) Sheets() []*Sheet {
+	return append([]*Sheet(nil), w.sheets...)
+}
+
+// Value returns the value of cell c on the named sheet. It implements
+// eval.Context; references to unknown sheets are #REF!.
+func (w *Workbook) Value(sheet string, c ref.Cell) eval.Value {
+	s := w.Sheet(sheet)
+	if s == nil {
+		return eval.ErrRef
+	}
+	return s.value(c, w.calc)
+}
+
+// SpillRange returns the range covered by the array result of the
+// formula in cell c. It implements eval.Spiller.
+func (w *Workbook) SpillRange(sheet string, c ref.Cell) (ref.Range, bool) {
+	return w.spillRange(sheet, c, w.calc)
+}
+
+// spillRange returns the spill range of the formula in cell c, first
+// computing the formula if it is dirty and calc is set.
+func (w *Workbook) spillRange(sheet string, c ref.Cell, calc bool) (ref.Range, bool) {
+	s := w.Sheet(sheet)
+	if s == nil {
+		return ref.Range{}, false
+	}
+	cell := s.cells[c]
+	if cell == nil || cell.Expr == nil {
+		return ref.Range{}, false
+	}
+	if calc {
+		s.calc(c, cell)
+	}
+	return cell.area, cell.spilled
+}
+
+// maxPasses limits the rounds of Recalc over formulas whose spill
+// ranges keep changing.
+const maxPasses = 16
+
+// Recalc computes the dirty formulas, each after the dirty formulas it
+// depends on. Formulas that depend on their own results are circular
+// references: unless Iterate is set their results are #CALC! and Recalc
+// returns a *CycleError listing the cycles.
+//
+// Formulas that refer to cells covered by a spilled array depend on the
+// formula of the array. When a spill range changes, the formulas that
+// depend on the cells it gained are computed in another round.
+//
+// Formulas that call Volatile functions, and the formulas that depend on
+// them, are computed by every Recalc.
+func (w *Workbook) Recalc() error {
+	for n := range w.volatile {
+		if cell := n.s.cells[n.c]; !cell.dirty {
+			for _, a := range w.mark(n, cell) {
+				w.changed(a)
+			}
+		}
+	}
+	w.now = time.Now()
+	if w.Clock != nil {
+		w.now = w.Clock()
+	}
+	w.calc = true
+	w.looped = make(map[node]bool)
+	defer func() {
+		w.calc = false
+		w.cycles, w.looped = nil, nil
+	}()
+	for i := 0; i < maxPasses && len(w.dirty) > 0; i++ {
+		levels := w.levels(w.order())
+		w.dirty = make(map[node]bool)
+		for _, level := range levels {
+			w.run(level)
+		}
+	}
+	if len(w.cycles) == 0 {
+		return nil
+	}
+	err := &CycleError{}
+	for _, path := range w.cycles {
+		cycle := make([]Addr, len(path))
+		for i, n := range path {
+			cycle[i] = Addr{Sheet: n.s.name, Cell: n.c}
+		}
+		err.Cycles = append(err.Cycles, cycle)
+	}
+	return err
+}
+
+// calcComp computes a component found by order.
+func (w *Workbook) calcComp(comp []node) {
+	switch {
+	case !w.cyclic(comp):
+		n := comp[0]
+		if cell := n.s.cells[n.c]; cell != nil && cell.Expr != nil {
+			n.s.calc(n.c, cell)
+		}
+	case w.Iterate:
+		w.iterate(comp)
+	default:
+		w.loop(w.cyclePath(comp), comp)
+	}
+}
+
+// order returns the dirty formulas grouped into strongly connected
+// components: a single formula, or the formulas of a cycle. Each
+// component comes after the components it depends on. The order is
+// deterministic: formulas are visited by position.
+func (w *Workbook) order() [][]node {
+	dirty := make([]node, 0, len(w.dirty))
+	for n := range w.dirty {
+		dirty = append(dirty, n)
+	}
+	w.sort(dirty)
+
+	// Tarjan's algorithm, which finds each component after the
+	// components that depend on it.
+	index := make(map[node]int)
+	low := make(map[node]int)
+	on := make(map[node]bool)
+	var stack []node
+	var comps [][]node
+	var visit func(n node)
+	visit = func(n node) {
+		index[n] = len(index)
+		low[n] = index[n]
+		stack = append(stack, n)
+		on[n] = true
+		w.dependents(n, func(d node) {
+			if !w.dirty[d] {
+				return
+			}
+			if _, seen := index[d]; !seen {
+				visit(d)
+				if low[d] < low[n] {
+					low[n] = low[d]
+				}
+			} else if on[d] && index[d] < low[n] {
+				low[n] = index[d]
+			}
+		})
+		if low[n] != index[n] {
+			return
+		}
+		var comp []node
+		for {
+			m := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			on[m] = false
+			comp = append(comp, m)
+			if m == n {
+				break
+			}
+		}
+		w.sort(comp)
+		comps = append(comps, comp)
+	}
+	for _, n := range dirty {
+		if _, seen := index[n]; !seen {
+			visit(n)
+		}
+	}
+	for i, j := 0, len(comps)-1; i < j; i, j = i+1, j-1 {
+		comps[i], comps[j] = comps[j], comps[i]
+	}
+	return comps
+}
+
+// sort sorts nodes by sheet, then in row-major order.
+func (w *Workbook) sort(nodes []node) {
+	sheet := make(map[*Sheet]int, len(w.sheets))
+	for i, s := range w.sheets {
+		sheet[s] = i
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if a.s != b.s {
+			return sheet[a.s] < sheet[b.s]
+		}
+		return a.c.Row < b.c.Row || a.c.Row == b.c.Row && a.c.Col < b.c.Col
+	})
+}
+
+// dependents calls f for the formulas that depend on cell n, including
+// through the array it spills.
+func (w *Workbook) dependents(n node, f func(d node)) {
+	w.graph.dependents(area{s: n.s, r: ref.Range{From: n.c, To: n.c}}, f)
+	if cell := n.s.cells[n.c]; cell != nil && cell.spilled {
+		w.graph.dependents(area{s: n.s, r: cell.area}, f)
+	}
+}
+
+// changed marks dirty the formulas that depend on the cells of a and,
+// transitively, the formulas that depend on them.
+func (w *Workbook) changed(a area) {
+	stack := []area{a}
+	for len(stack) > 0 {
+		a := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		w.graph.dependents(a, func(d node) {
+			if cell := d.s.cells[d.c]; cell != nil && !cell.dirty {
+				stack = append(stack, w.mark(d, cell)...)
+			}
+		})
+	}
+}
+
+// mark marks the formula in cell n dirty and returns the areas whose
+// value it determines: the cell itself and the range it spills into.
+func (w *Workbook) mark(n node, cell *Cell) []area {
+	cell.dirty = true
+	w.dirty[n] = true
+	areas := []area{{s: n.s, r: ref.Range{From: n.c, To: n.c}}}
+	if cell.spilled {
+		areas = append(areas, area{s: n.s, r: cell.area})
+	}
+	return areas
+}
@@ -0,0 +1,163 @@
+package workbook
+
+import (
+	"github.com/ajz01/calc/eval"
+	"github.com/ajz01/calc/ref"
+	"testing"
+)
+
+func cell(s string) ref.Cell {
+	c, err := ref.ParseCell(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func newSheet(t *testing.T, w *Workbook, name string, cells map[string]string) *Sheet {
+	s, err := w.AddSheet(name)
+	if err != nil {
+		t.Fatalf("AddSheet(%q) %v", name, err)
+	}
+	for addr, src := range cells {
+		if err := s.SetFormula(cell(addr), src); err != nil {
+			t.Fatalf("SetFormula(%s, %q) %v", addr, src, err)
+		}
+	}
+	return s
+}
+
+func TestRecalc(t *testing.T) {
+	w := New()
+	s := newSheet(t, w, "Sheet1", map[string]string{
+		"A3": "=A1+A2",
+		"B1": "A3*2",
+		"B2": "=SUM(A1:A3)",
+	})
+	s.Set(cell("A1"), eval.Number(1))
+	s.Set(cell("A2"), eval.Number(2))
+	if v := s.Value(cell("A3")); v != (eval.Blank{}) {
+		t.Errorf("A3 before Recalc = %v want blank", v)
+	}
+	w.Recalc()
+	for addr, want := range map[string]eval.Value{"A3": eval.Number(3), "B1": eval.Number(6), "B2": eval.Number(6), "C1": eval.Blank{}} {
+		if v := s.Value(cell(addr)); v != want {
+			t.Errorf("%s = %v want %v", addr, v, want)
+		}
+	}
+	s.Set(cell("A1"), eval.Number(10))
+	w.Recalc()
+	if v := s.Value(cell("B1")); v != eval.Number(24) {
+		t.Errorf("B1 after edit = %v want 24", v)
+	}
+	if got := s.Cell(cell("B1")).Formula; got != "A3*2" {
+		t.Errorf("B1 formula = %q want A3*2", got)
+	}
+}
+
+func TestSheets(t *testing.T) {
+	w := New()
+	newSheet(t, w, "Data", nil).Set(cell("A1"), eval.Text("x"))
+	s := newSheet(t, w, "Report", map[string]string{"A1": "=Total(1)"})
+	if _, err := w.AddSheet("DATA"); err != ErrSheetUsed {
+		t.Errorf("AddSheet(DATA) = %v want %v", err, ErrSheetUsed)
+	}
+	if _, err := w.AddSheet("a:b"); err != ErrSheetName {
+		t.Errorf("AddSheet(a:b) = %v want %v", err, ErrSheetName)
+	}
+	if v := w.Value("data", cell("A1")); v != eval.Text("x") {
+		t.Errorf("Value(data, A1) = %v want x", v)
+	}
+	if v := w.Value("Other", cell("A1")); v != eval.ErrRef {
+		t.Errorf("Value(Other, A1) = %v want #REF!", v)
+	}
+	w.Recalc()
+	if v := s.Value(cell("A1")); v != eval.ErrName {
+		t.Errorf("Report!A1 = %v want #NAME?", v)
+	}
+	if err := s.SetFormula(cell("A2"), "=1+"); err == nil {
+		t.Errorf("SetFormula(=1+) succeeded, want error")
+	}
+	if n := len(w.Sheets()); n != 2 {
+		t.Errorf("len(Sheets()) = %d want 2", n)
+	}
+}
+
+func TestSparse(t *testing.T) {
+	w := New()
+	s := newSheet(t, w, "Sheet1", map[string]string{"B1": "=SUM(A:A)", "B2": "=COUNTA(A1:XFD1)"})
+	s.Set(cell("A1"), eval.Number(1))
+	s.Set(cell("A1048576"), eval.Number(2))
+	s.Set(cell("XFD1"), eval.Number(3))
+	w.Recalc()
+	if v := s.Value(cell("B1")); v != eval.Number(3) {
+		t.Errorf("B1 = %v want 3", v)
+	}
+	if v := s.Value(cell("B2")); v != eval.Number(3) {
+		t.Errorf("B2 = %v want 3", v)
+	}
+	if got := s.Cells(); len(got) != 5 || got[0] != cell("A1") || got[4] != cell("A1048576") {
+		t.Errorf("Cells() = %v", got)
+	}
+	s.Set(cell("XFD1"), eval.Blank{})
+	if s.Cell(cell("XFD1")) != nil {
+		t.Errorf("XFD1 not cleared by Set(Blank)")
+	}
+}
+
+func TestSpill(t *testing.T) {
+	w := New()
+	s := newSheet(t, w, "Sheet1", map[string]string{
+		"A1": "=SEQUENCE(3)",
+		"B1": "=SUM(A1#)",
+		"B2": "=A3*10",
+		"C1": "=SEQUENCE(1,2)",
+	})
+	w.Recalc()
+	for addr, want := range map[string]eval.Value{"A1": eval.Number(1), "A3": eval.Number(3), "A4": eval.Blank{}, "B1": eval.Number(6), "B2": eval.Number(30), "D1": eval.Number(2)} {
+		if v := s.Value(cell(addr)); v != want {
+			t.Errorf("%s = %v want %v", addr, v, want)
+		}
+	}
+	s.Set(cell("A2"), eval.Text("x"))
+	w.Recalc()
+	for addr, want := range map[string]eval.Value{"A1": eval.ErrSpill, "A3": eval.Blank{}, "B1": eval.ErrRef, "B2": eval.Number(0)} {
+		if v := s.Value(cell(addr)); v != want {
+			t.Errorf("blocked: %s = %v want %v", addr, v, want)
+		}
+	}
+}
+
+func TestSpillRef(t *testing.T) {
+	w := New()
+	s := newSheet(t, w, "Sheet1", map[string]string{
+		"A1": "1",
+		"A2": "2",
+		"A3": "3",
+		"E1": "=SEQUENCE(3,1,10)",
+		"C1": "=A1:A3",
+		"D1": "=E1#",
+		"F1": "=OFFSET(A1,0,0,3,1)",
+		"G1": "=INDEX(A1:A3,0,1)",
+		"H1": "=A1:A1",
+	})
+	w.Recalc()
+	for addr, want := range map[string]eval.Value{
+		"C1": eval.Number(1), "C3": eval.Number(3),
+		"D1": eval.Number(10), "D3": eval.Number(12),
+		"F2": eval.Number(2), "F3": eval.Number(3),
+		"G1": eval.Number(1), "G3": eval.Number(3),
+		"H1": eval.Number(1), "H2": eval.Blank{},
+	} {
+		if v := s.Value(cell(addr)); v != want {
+			t.Errorf("%s = %v want %v", addr, v, want)
+		}
+	}
+	s.Set(cell("A2"), eval.Number(20))
+	w.Recalc()
+	for _, addr := range []string{"C2", "F2", "G2"} {
+		if v := s.Value(cell(addr)); v != eval.Number(20) {
+			t.Errorf("after edit: %s = %v want 20", addr, v)
+		}
+	}
+}